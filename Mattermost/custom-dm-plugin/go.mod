@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/mattermost/mattermost-server/v6 v6.0.0
 	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
 )
@@ -0,0 +1,173 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/pkg/errors"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// ExemptState is the exempt configuration exchanged by the exempt endpoint:
+// the default list from the plugin settings and the named lists.
+type ExemptState struct {
+    Users []string               `json:"users"` // the default list
+    Lists map[string]*ExemptList `json:"lists"` // named lists keyed by name
+}
+
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+    switch r.URL.Path {
+    case "/api/v1/exempt":
+        p.handleExempt(w, r)
+    case "/debug/vars":
+        p.handleDebugVars(w, r)
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+// handleExempt returns or replaces the exempt lists so they can be synced
+// between environments. Only system admins can use it.
+func (p *Plugin) handleExempt(w http.ResponseWriter, r *http.Request) {
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can manage exempt lists", http.StatusForbidden)
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet:
+        p.exemptMutex.RLock()
+        state := &ExemptState{Users: config.GetConfig().ExemptedUserList(), Lists: p.exemptLists}
+        data, err := json.Marshal(state)
+        p.exemptMutex.RUnlock()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        w.Write(data)
+
+    case http.MethodPut:
+        var state ExemptState
+        if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if err := state.normalize(config.GetConfig().MaxExemptUsers); err != nil {
+            http.Error(w, "Invalid exempt lists: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if err := p.replaceExemptState(&state); err != nil {
+            config.Mattermost.LogError("Failed to replace exempt lists", "error", err.Error())
+            http.Error(w, "Failed to save exempt lists", http.StatusInternalServerError)
+            return
+        }
+
+        w.WriteHeader(http.StatusOK)
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// normalize validates the state and lowercases list names and usernames, as
+// the list commands do.
+func (s *ExemptState) normalize(maxUsers int) error {
+    s.Users = canonicalUsernames(s.Users)
+    if err := validateUsernames(s.Users, maxUsers); err != nil {
+        return errors.Wrapf(err, "%s list", defaultExemptList)
+    }
+
+    lists := make(map[string]*ExemptList, len(s.Lists))
+    for key, list := range s.Lists {
+        if list == nil {
+            return errors.Errorf("list %s is empty", key)
+        }
+
+        name := strings.ToLower(strings.TrimSpace(key))
+        if name == "" || strings.ContainsAny(name, " \t\n") {
+            return errors.Errorf("invalid list name %q", key)
+        }
+        if name == defaultExemptList {
+            return errors.Errorf("the %s list is set through users", defaultExemptList)
+        }
+        if _, exists := lists[name]; exists {
+            return errors.Errorf("duplicate list %s", name)
+        }
+
+        list.Users = canonicalUsernames(list.Users)
+        if err := validateUsernames(list.Users, maxUsers); err != nil {
+            return errors.Wrapf(err, "list %s", name)
+        }
+        for _, id := range append(append([]string{}, list.Teams...), list.Channels...) {
+            if !model.IsValidId(id) {
+                return errors.Errorf("list %s: invalid team or channel ID %q", name, id)
+            }
+        }
+
+        list.Name = name
+        lists[name] = list
+    }
+    s.Lists = lists
+
+    return nil
+}
+
+// canonicalUsernames returns the usernames in canonical form.
+func canonicalUsernames(usernames []string) []string {
+    canonical := make([]string, 0, len(usernames))
+    for _, username := range usernames {
+        canonical = append(canonical, config.CanonicalUsername(username))
+    }
+    return canonical
+}
+
+// validateUsernames checks that the usernames are well formed, unique and
+// within the size limit.
+func validateUsernames(usernames []string, maxUsers int) error {
+    if maxUsers > 0 && len(usernames) > maxUsers {
+        return errors.Errorf("%d users exceed the maximum of %d", len(usernames), maxUsers)
+    }
+
+    seen := make(map[string]bool, len(usernames))
+    for _, username := range usernames {
+        if !model.IsValidUsername(strings.ToLower(username)) {
+            return errors.Errorf("invalid username %q", username)
+        }
+        if seen[strings.ToLower(username)] {
+            return errors.Errorf("duplicate username %s", username)
+        }
+        seen[strings.ToLower(username)] = true
+    }
+
+    return nil
+}
+
+// replaceExemptState saves a validated state as the default and named lists.
+func (p *Plugin) replaceExemptState(state *ExemptState) error {
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    previous := p.exemptLists
+    p.exemptLists = state.Lists
+    if err := p.saveExemptLists(); err != nil {
+        p.exemptLists = previous
+        return err
+    }
+
+    conf := config.GetConfig()
+    conf.ExemptedUsers = strings.Join(state.Users, ",")
+    if appErr := p.API.SavePluginConfig(conf.ToMap()); appErr != nil {
+        return appErr
+    }
+
+    return nil
+}
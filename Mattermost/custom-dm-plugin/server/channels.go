@@ -0,0 +1,68 @@
+package main
+
+import (
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// Members loaded for a new group message; group messages have at most 8
+const groupChannelMemberLimit = 20
+
+// ChannelHasBeenCreated warns the participants of a new group message when
+// some of them may not be able to post in it.
+func (p *Plugin) ChannelHasBeenCreated(c *plugin.Context, channel *model.Channel) {
+    conf := config.GetConfig()
+    if !conf.Enabled || !conf.WarnNewGroupChannels || channel.Type != model.ChannelTypeGroup {
+        return
+    }
+
+    if p.pauseRemaining() > 0 {
+        return
+    }
+
+    // Exempt creators set up conversations on purpose, e.g. support staff
+    if channel.CreatorId != "" {
+        if creator, appErr := p.API.GetUser(channel.CreatorId); appErr == nil {
+            if decision, err := p.decide(creator, channel.Id); err == nil && decision.Exempt {
+                return
+            }
+        }
+    }
+
+    members, appErr := p.API.GetChannelMembers(channel.Id, 0, groupChannelMemberLimit)
+    if appErr != nil {
+        p.API.LogError("Failed to get group message members", "channel_id", channel.Id, "error", appErr.Error())
+        return
+    }
+
+    restricted := []string{}
+    for _, member := range members {
+        user, appErr := p.API.GetUser(member.UserId)
+        if appErr != nil || user.IsBot {
+            continue
+        }
+        decision, err := p.decide(user, channel.Id)
+        if err != nil {
+            p.API.LogError("Failed to evaluate DM policy", "user_id", user.Id, "error", err.Error())
+            continue
+        }
+        if decision.Blocked {
+            restricted = append(restricted, "@"+user.Username)
+        }
+    }
+
+    if len(restricted) == 0 {
+        return
+    }
+
+    for _, member := range members {
+        p.API.SendEphemeralPost(member.UserId, &model.Post{
+            ChannelId: channel.Id,
+            Message:   translate(p.userLocale(member.UserId), "channel.restricted_warning", strings.Join(restricted, ", ")),
+        })
+    }
+}
@@ -1,8 +1,14 @@
 package config
 
 import (
+    "fmt"
+    "regexp"
+    "strconv"
     "strings"
+    "text/template"
+    "time"
 
+    "github.com/mattermost/mattermost-server/v6/model"
     "github.com/mattermost/mattermost-server/v6/plugin"
     "github.com/pkg/errors"
 )
@@ -14,8 +20,64 @@ type Configuration struct {
     AdminOnly        bool   // If true, only admins can send DMs. If false, anyone not in BlockedDomains can send DMs.
     ExemptedUsers    string // Comma-separated list of usernames to exempt from restrictions (e.g., user1,user2)
     RejectionMessage string
+    BlockedKeywords  string // Comma-separated list of phrases that may not appear in DMs (case-insensitive)
+    BlockedPatterns  string // Newline-separated list of regular expressions that may not match DMs
+
+    KeywordRejectionMessage string
+    CommandTrigger          string // Slash command trigger word without the leading slash
+    MaxExemptUsers          int    // Maximum number of users in each exempt list; 0 means unlimited
+    FailMode                string // FailOpen or FailClosed: whether DMs are allowed when the policy cannot be evaluated
+    CommandRateLimit        int    // Maximum slash commands per user per minute; 0 means unlimited
+    NewUserGraceDays        int    // Users are exempt for this many days after their account is created; 0 disables it
+    ExemptAttributes        string // Comma-separated key=value pairs of admin-controlled user attributes; users matching any pair are exempt
+    AllowReplies            bool   // If true, blocked users can reply in DMs started by users who may send DMs
+    RejectionNoticeWindow   int    // Minutes during which a user is told about rejections once per channel; 0 notifies every time
+    WarnNewGroupChannels    bool   // If true, participants of a new group message are warned when some of them are restricted
+    SuggestedChannel        string // Channel suggested in rejection messages, as team-name/channel-name or a channel ID
+    SuggestionMessage       string // Template of the suggestion, e.g. "Please post in ~{{.Channel}} instead."
+
+    blockedKeywords    []string
+    blockedPatterns    []*regexp.Regexp
+    exemptAttributes   []attributeRule
+    suggestedChannel   *model.Channel
+    suggestionTemplate *template.Template
+}
+
+// suggestionData is the data available to the SuggestionMessage template
+type suggestionData struct {
+    Channel     string // channel name, e.g. support
+    DisplayName string // channel display name, e.g. Support
 }
 
+// DefaultSuggestionMessage is the suggestion template used when none is configured
+const DefaultSuggestionMessage = "Please post in ~{{.Channel}} instead."
+
+// attributeRule is one key=value pair of ExemptAttributes
+type attributeRule struct {
+    key   string
+    value string
+}
+
+// Keys of the user attributes ExemptAttributes can match. Only attributes
+// users cannot change themselves are supported: profile props can be edited
+// by every user through the API, so exempting by them would let anyone
+// exempt themselves.
+const (
+    AttributeRole        = "role"         // one of the user's roles, e.g. a custom role
+    AttributeAuthService = "auth_service" // the sign-in method, e.g. saml or ldap
+)
+
+const (
+    // FailOpen allows DMs when the policy cannot be evaluated
+    FailOpen = "open"
+
+    // FailClosed rejects DMs when the policy cannot be evaluated
+    FailClosed = "closed"
+)
+
+// DefaultCommandTrigger is the slash command trigger used when none is configured
+const DefaultCommandTrigger = "custom-dm"
+
 var Mattermost plugin.API
 var configuration *Configuration
 
@@ -29,31 +91,418 @@ func SetConfig(config *Configuration) {
 
 func (c *Configuration) ProcessConfiguration() error {
     c.BlockedDomains = strings.TrimSpace(c.BlockedDomains)
-    c.ExemptedUsers = strings.TrimSpace(c.ExemptedUsers)
+    c.ExemptedUsers = strings.Join(c.ExemptedUserList(), ",")
     c.RejectionMessage = strings.TrimSpace(c.RejectionMessage)
 
     if c.RejectionMessage == "" {
         c.RejectionMessage = "You are not allowed to send direct messages."
     }
 
+    c.FailMode = strings.ToLower(strings.TrimSpace(c.FailMode))
+    if c.FailMode == "" {
+        c.FailMode = FailOpen
+    }
+
+    if c.MaxExemptUsers < 0 {
+        c.MaxExemptUsers = 0
+    }
+
+    if c.CommandRateLimit < 0 {
+        c.CommandRateLimit = 0
+    }
+
+    if c.NewUserGraceDays < 0 {
+        c.NewUserGraceDays = 0
+    }
+
+    if c.RejectionNoticeWindow < 0 {
+        c.RejectionNoticeWindow = 0
+    }
+
+    c.CommandTrigger = strings.TrimPrefix(strings.TrimSpace(c.CommandTrigger), "/")
+    if c.CommandTrigger == "" {
+        c.CommandTrigger = DefaultCommandTrigger
+    }
+
+    c.KeywordRejectionMessage = strings.TrimSpace(c.KeywordRejectionMessage)
+    if c.KeywordRejectionMessage == "" {
+        c.KeywordRejectionMessage = "Your message contains content that is not allowed in direct messages."
+    }
+
+    c.blockedKeywords = nil
+    for _, keyword := range strings.Split(c.BlockedKeywords, ",") {
+        keyword = strings.ToLower(strings.TrimSpace(keyword))
+        if keyword != "" {
+            c.blockedKeywords = append(c.blockedKeywords, keyword)
+        }
+    }
+
+    c.exemptAttributes = nil
+    for _, pair := range strings.Split(c.ExemptAttributes, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        parts := strings.SplitN(pair, "=", 2)
+        if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+            return errors.Errorf("invalid exempt attribute %q, expected key=value", pair)
+        }
+        key := strings.ToLower(strings.TrimSpace(parts[0]))
+        if key != AttributeRole && key != AttributeAuthService {
+            return errors.Errorf("invalid exempt attribute %q, only %s and %s can be used since users can change their other profile attributes", pair, AttributeRole, AttributeAuthService)
+        }
+        c.exemptAttributes = append(c.exemptAttributes, attributeRule{
+            key:   key,
+            value: strings.TrimSpace(parts[1]),
+        })
+    }
+
+    c.blockedPatterns = nil
+    for _, pattern := range strings.Split(c.BlockedPatterns, "\n") {
+        pattern = strings.TrimSpace(pattern)
+        if pattern == "" {
+            continue
+        }
+        compiled, err := regexp.Compile(pattern)
+        if err != nil {
+            return errors.Wrapf(err, "invalid blocked pattern %q", pattern)
+        }
+        c.blockedPatterns = append(c.blockedPatterns, compiled)
+    }
+
+    c.SuggestionMessage = strings.TrimSpace(c.SuggestionMessage)
+    if c.SuggestionMessage == "" {
+        c.SuggestionMessage = DefaultSuggestionMessage
+    }
+    suggestionTemplate, err := template.New("suggestion").Parse(c.SuggestionMessage)
+    if err != nil {
+        return errors.Wrap(err, "invalid suggestion message")
+    }
+    c.suggestionTemplate = suggestionTemplate
+
+    c.SuggestedChannel = strings.TrimPrefix(strings.TrimSpace(c.SuggestedChannel), "~")
+    c.suggestedChannel = nil
+    if c.SuggestedChannel != "" && Mattermost != nil {
+        channel, err := lookupChannel(c.SuggestedChannel)
+        if err != nil {
+            return errors.Wrapf(err, "suggested channel %q not found", c.SuggestedChannel)
+        }
+        c.suggestedChannel = channel
+    }
+
     return nil
 }
 
+// lookupChannel resolves a team-name/channel-name reference or a channel ID.
+func lookupChannel(reference string) (*model.Channel, error) {
+    if parts := strings.SplitN(reference, "/", 2); len(parts) == 2 {
+        channel, appErr := Mattermost.GetChannelByNameForTeamName(parts[0], parts[1], false)
+        if appErr != nil {
+            return nil, appErr
+        }
+        return channel, nil
+    }
+
+    channel, appErr := Mattermost.GetChannel(reference)
+    if appErr != nil {
+        return nil, appErr
+    }
+    return channel, nil
+}
+
+// SuggestedChannelInfo returns the channel suggested in rejection messages, or
+// nil when none is configured.
+func (c *Configuration) SuggestedChannelInfo() *model.Channel {
+    return c.suggestedChannel
+}
+
+// Suggestion renders SuggestionMessage for the suggested channel.
+func (c *Configuration) Suggestion() string {
+    if c.suggestedChannel == nil || c.suggestionTemplate == nil {
+        return ""
+    }
+
+    var out strings.Builder
+    if err := c.suggestionTemplate.Execute(&out, suggestionData{
+        Channel:     c.suggestedChannel.Name,
+        DisplayName: c.suggestedChannel.DisplayName,
+    }); err != nil {
+        return ""
+    }
+    return strings.TrimSpace(out.String())
+}
+
 func (c *Configuration) IsValid() error {
-    if c.BlockedDomains == "" && !c.AdminOnly {
-        return errors.New("either blocked domains must be specified or admin only mode must be enabled")
+    if c.FailMode != FailOpen && c.FailMode != FailClosed {
+        return errors.Errorf("fail mode must be %q or %q", FailOpen, FailClosed)
+    }
+
+    if strings.ContainsAny(c.CommandTrigger, " \t\n") {
+        return errors.New("command trigger must not contain spaces")
+    }
+
+    if c.BlockedDomains == "" && !c.AdminOnly && len(c.blockedKeywords) == 0 && len(c.blockedPatterns) == 0 {
+        return errors.New("either blocked domains, blocked keywords or patterns must be specified, or admin only mode must be enabled")
     }
 
     return nil
 }
 
+// Keys used by ToMap and FromMap. Every Configuration setting has a key here
+// so the two stay in sync.
+const (
+    keyEnabled                 = "enabled"
+    keyBlockedDomains          = "blockedDomains"
+    keyAdminsExempt            = "adminsExempt"
+    keyAdminOnly               = "adminOnly"
+    keyExemptedUsers           = "exemptedUsers"
+    keyRejectionMessage        = "rejectionMessage"
+    keyBlockedKeywords         = "blockedKeywords"
+    keyBlockedPatterns         = "blockedPatterns"
+    keyKeywordRejectionMessage = "keywordRejectionMessage"
+    keyCommandTrigger          = "commandTrigger"
+    keyMaxExemptUsers          = "maxExemptUsers"
+    keyFailMode                = "failMode"
+    keyCommandRateLimit        = "commandRateLimit"
+    keyNewUserGraceDays        = "newUserGraceDays"
+    keyExemptAttributes        = "exemptAttributes"
+    keyAllowReplies            = "allowReplies"
+    keyRejectionNoticeWindow   = "rejectionNoticeWindow"
+    keyWarnNewGroupChannels    = "warnNewGroupChannels"
+    keySuggestedChannel        = "suggestedChannel"
+    keySuggestionMessage       = "suggestionMessage"
+)
+
 func (c *Configuration) ToMap() map[string]interface{} {
     return map[string]interface{}{
-        "enabled":          c.Enabled,
-        "blockedDomains":   c.BlockedDomains,
-        "adminsExempt":     c.AdminsExempt,
-        "adminOnly":        c.AdminOnly,
-        "exemptedUsers":    c.ExemptedUsers,
-        "rejectionMessage": c.RejectionMessage,
+        keyEnabled:          c.Enabled,
+        keyBlockedDomains:   c.BlockedDomains,
+        keyAdminsExempt:     c.AdminsExempt,
+        keyAdminOnly:        c.AdminOnly,
+        keyExemptedUsers:    c.ExemptedUsers,
+        keyRejectionMessage: c.RejectionMessage,
+        keyBlockedKeywords:  c.BlockedKeywords,
+        keyBlockedPatterns:  c.BlockedPatterns,
+
+        keyKeywordRejectionMessage: c.KeywordRejectionMessage,
+        keyCommandTrigger:          c.CommandTrigger,
+        keyMaxExemptUsers:          c.MaxExemptUsers,
+        keyFailMode:                c.FailMode,
+        keyCommandRateLimit:        c.CommandRateLimit,
+        keyNewUserGraceDays:        c.NewUserGraceDays,
+        keyExemptAttributes:        c.ExemptAttributes,
+        keyAllowReplies:            c.AllowReplies,
+        keyRejectionNoticeWindow:   c.RejectionNoticeWindow,
+        keyWarnNewGroupChannels:    c.WarnNewGroupChannels,
+        keySuggestedChannel:        c.SuggestedChannel,
+        keySuggestionMessage:       c.SuggestionMessage,
+    }
+}
+
+// FromMap is the inverse of ToMap. Keys are matched case-insensitively, since
+// the server lowercases plugin setting keys when it stores them, and numbers
+// may be float64 after a JSON round trip. Missing keys keep their zero value.
+func FromMap(settings map[string]interface{}) (*Configuration, error) {
+    values := make(map[string]interface{}, len(settings))
+    for key, value := range settings {
+        values[strings.ToLower(key)] = value
+    }
+
+    c := &Configuration{}
+    var err error
+    if c.Enabled, err = boolSetting(values, keyEnabled); err != nil {
+        return nil, err
+    }
+    if c.BlockedDomains, err = stringSetting(values, keyBlockedDomains); err != nil {
+        return nil, err
+    }
+    if c.AdminsExempt, err = boolSetting(values, keyAdminsExempt); err != nil {
+        return nil, err
+    }
+    if c.AdminOnly, err = boolSetting(values, keyAdminOnly); err != nil {
+        return nil, err
+    }
+    if c.ExemptedUsers, err = stringSetting(values, keyExemptedUsers); err != nil {
+        return nil, err
+    }
+    if c.RejectionMessage, err = stringSetting(values, keyRejectionMessage); err != nil {
+        return nil, err
+    }
+    if c.BlockedKeywords, err = stringSetting(values, keyBlockedKeywords); err != nil {
+        return nil, err
+    }
+    if c.BlockedPatterns, err = stringSetting(values, keyBlockedPatterns); err != nil {
+        return nil, err
+    }
+    if c.KeywordRejectionMessage, err = stringSetting(values, keyKeywordRejectionMessage); err != nil {
+        return nil, err
+    }
+    if c.CommandTrigger, err = stringSetting(values, keyCommandTrigger); err != nil {
+        return nil, err
+    }
+    if c.MaxExemptUsers, err = intSetting(values, keyMaxExemptUsers); err != nil {
+        return nil, err
+    }
+    if c.FailMode, err = stringSetting(values, keyFailMode); err != nil {
+        return nil, err
+    }
+    if c.CommandRateLimit, err = intSetting(values, keyCommandRateLimit); err != nil {
+        return nil, err
+    }
+    if c.NewUserGraceDays, err = intSetting(values, keyNewUserGraceDays); err != nil {
+        return nil, err
+    }
+    if c.ExemptAttributes, err = stringSetting(values, keyExemptAttributes); err != nil {
+        return nil, err
+    }
+    if c.AllowReplies, err = boolSetting(values, keyAllowReplies); err != nil {
+        return nil, err
+    }
+    if c.RejectionNoticeWindow, err = intSetting(values, keyRejectionNoticeWindow); err != nil {
+        return nil, err
+    }
+    if c.WarnNewGroupChannels, err = boolSetting(values, keyWarnNewGroupChannels); err != nil {
+        return nil, err
+    }
+    if c.SuggestedChannel, err = stringSetting(values, keySuggestedChannel); err != nil {
+        return nil, err
+    }
+    if c.SuggestionMessage, err = stringSetting(values, keySuggestionMessage); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+func boolSetting(values map[string]interface{}, key string) (bool, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return false, nil
+    case bool:
+        return value, nil
+    case string:
+        parsed, err := strconv.ParseBool(value)
+        if err != nil {
+            return false, errors.Wrapf(err, "invalid value for %s", key)
+        }
+        return parsed, nil
+    default:
+        return false, errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+func stringSetting(values map[string]interface{}, key string) (string, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return "", nil
+    case string:
+        return value, nil
+    default:
+        return "", errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+func intSetting(values map[string]interface{}, key string) (int, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return 0, nil
+    case int:
+        return value, nil
+    case int64:
+        return int(value), nil
+    case float64:
+        return int(value), nil
+    case string:
+        parsed, err := strconv.Atoi(value)
+        if err != nil {
+            return 0, errors.Wrapf(err, "invalid value for %s", key)
+        }
+        return parsed, nil
+    default:
+        return 0, errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+// BlockedContentRule returns the keyword or pattern that the message violates,
+// or an empty string when the message is allowed.
+func (c *Configuration) BlockedContentRule(message string) string {
+    lower := strings.ToLower(message)
+    for _, keyword := range c.blockedKeywords {
+        if strings.Contains(lower, keyword) {
+            return keyword
+        }
+    }
+
+    for _, pattern := range c.blockedPatterns {
+        if pattern.MatchString(message) {
+            return pattern.String()
+        }
+    }
+
+    return ""
+}
+
+// ContentRulesSummary describes the configured content rules.
+func (c *Configuration) ContentRulesSummary() string {
+    if len(c.blockedKeywords) == 0 && len(c.blockedPatterns) == 0 {
+        return "none"
+    }
+    return fmt.Sprintf("%d keyword(s), %d pattern(s) checked for non-exempt users", len(c.blockedKeywords), len(c.blockedPatterns))
+}
+
+// CanonicalUsername returns the form usernames are stored and compared in:
+// trimmed, without a leading @ and lowercased, as Mattermost usernames are.
+func CanonicalUsername(username string) string {
+    return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// ExemptedUserList returns the non-empty entries of ExemptedUsers in
+// canonical form.
+func (c *Configuration) ExemptedUserList() []string {
+    users := []string{}
+    for _, user := range strings.Split(c.ExemptedUsers, ",") {
+        user = CanonicalUsername(user)
+        if user != "" {
+            users = append(users, user)
+        }
+    }
+    return users
+}
+
+// ExemptLimitReached reports whether a list of the given size cannot accept
+// another user.
+func (c *Configuration) ExemptLimitReached(size int) bool {
+    return c.MaxExemptUsers > 0 && size >= c.MaxExemptUsers
+}
+
+// InNewUserGrace reports whether an account created at createAt (milliseconds
+// since epoch) is still within the new user grace period.
+func (c *Configuration) InNewUserGrace(createAt int64, now time.Time) bool {
+    if c.NewUserGraceDays <= 0 || createAt <= 0 {
+        return false
+    }
+    graceEnd := time.Unix(0, createAt*int64(time.Millisecond)).AddDate(0, 0, c.NewUserGraceDays)
+    return now.Before(graceEnd)
+}
+
+// ExemptAttribute returns the key=value pair of ExemptAttributes matched by
+// the user, or an empty string when none matches. Only the user's roles and
+// sign-in method are checked, never their profile props. Values are compared
+// case-insensitively.
+func (c *Configuration) ExemptAttribute(user *model.User) string {
+    for _, rule := range c.exemptAttributes {
+        var values []string
+        switch rule.key {
+        case AttributeRole:
+            values = strings.Fields(user.Roles)
+        case AttributeAuthService:
+            values = []string{user.AuthService}
+        }
+        for _, value := range values {
+            if value != "" && strings.EqualFold(value, rule.value) {
+                return rule.key + "=" + rule.value
+            }
+        }
     }
+    return ""
 }
@@ -0,0 +1,109 @@
+package config
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+// filledConfiguration returns a configuration with every exported field set
+// to a distinct non-zero value, so a dropped field fails the comparison.
+func filledConfiguration(t *testing.T) *Configuration {
+    c := &Configuration{}
+    value := reflect.ValueOf(c).Elem()
+    for i := 0; i < value.NumField(); i++ {
+        field := value.Type().Field(i)
+        if field.PkgPath != "" {
+            continue
+        }
+        switch field.Type.Kind() {
+        case reflect.Bool:
+            value.Field(i).SetBool(true)
+        case reflect.String:
+            value.Field(i).SetString("value of " + field.Name)
+        case reflect.Int:
+            value.Field(i).SetInt(int64(i + 1))
+        default:
+            t.Fatalf("no test value for %s of type %s", field.Name, field.Type)
+        }
+    }
+    return c
+}
+
+func TestToMapCoversEveryField(t *testing.T) {
+    settings := filledConfiguration(t).ToMap()
+
+    exported := 0
+    configType := reflect.TypeOf(Configuration{})
+    for i := 0; i < configType.NumField(); i++ {
+        if configType.Field(i).PkgPath == "" {
+            exported++
+        }
+    }
+    assert.Len(t, settings, exported)
+}
+
+func TestFromMapRoundTrip(t *testing.T) {
+    original := filledConfiguration(t)
+
+    restored, err := FromMap(original.ToMap())
+    require.NoError(t, err)
+    assert.Equal(t, original, restored)
+}
+
+func TestFromMapRoundTripThroughStoredSettings(t *testing.T) {
+    original := filledConfiguration(t)
+
+    // The server stores plugin settings as JSON with lowercased keys
+    stored := make(map[string]interface{})
+    for key, value := range original.ToMap() {
+        stored[strings.ToLower(key)] = value
+    }
+    data, err := json.Marshal(stored)
+    require.NoError(t, err)
+    var loaded map[string]interface{}
+    require.NoError(t, json.Unmarshal(data, &loaded))
+
+    restored, err := FromMap(loaded)
+    require.NoError(t, err)
+    assert.Equal(t, original, restored)
+}
+
+func TestFromMapRejectsWrongTypes(t *testing.T) {
+    for _, settings := range []map[string]interface{}{
+        {keyEnabled: "maybe"},
+        {keyEnabled: 1},
+        {keyBlockedDomains: true},
+        {keyMaxExemptUsers: "many"},
+        {keyMaxExemptUsers: []string{"1"}},
+    } {
+        _, err := FromMap(settings)
+        assert.Error(t, err, settings)
+    }
+}
+
+func TestFromMapParsesStrings(t *testing.T) {
+    c, err := FromMap(map[string]interface{}{keyEnabled: "true", keyMaxExemptUsers: "12"})
+    require.NoError(t, err)
+    assert.True(t, c.Enabled)
+    assert.Equal(t, 12, c.MaxExemptUsers)
+}
+
+func TestProcessConfigurationDefaultsToFailOpen(t *testing.T) {
+    c := &Configuration{AdminOnly: true}
+    require.NoError(t, c.ProcessConfiguration())
+    assert.Equal(t, FailOpen, c.FailMode)
+
+    c = &Configuration{AdminOnly: true, FailMode: " Closed "}
+    require.NoError(t, c.ProcessConfiguration())
+    assert.Equal(t, FailClosed, c.FailMode)
+    assert.NoError(t, c.IsValid())
+
+    c = &Configuration{AdminOnly: true, FailMode: "sometimes"}
+    require.NoError(t, c.ProcessConfiguration())
+    assert.Error(t, c.IsValid())
+}
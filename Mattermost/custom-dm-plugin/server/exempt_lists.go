@@ -0,0 +1,607 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+const (
+    // Key for storing named exempt lists in KV store
+    exemptListsKey = "exempt_lists"
+
+    // Name of the built-in list backed by the ExemptedUsers setting
+    defaultExemptList = "default"
+)
+
+// ExemptList is a named set of exempted usernames together with the policy
+// conditions that decide where it applies. A list without conditions applies
+// to every direct and group message.
+type ExemptList struct {
+    Name     string   `json:"name"`
+    Users    []string `json:"users"`
+    Teams    []string `json:"teams"`    // team IDs whose members the list applies to
+    Channels []string `json:"channels"` // channel IDs the list applies to
+}
+
+func (l *ExemptList) hasUser(username string) bool {
+    username = config.CanonicalUsername(username)
+    for _, user := range l.Users {
+        if config.CanonicalUsername(user) == username {
+            return true
+        }
+    }
+    return false
+}
+
+func (l *ExemptList) hasConditions() bool {
+    return len(l.Teams) > 0 || len(l.Channels) > 0
+}
+
+func (p *Plugin) loadExemptLists() error {
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    p.exemptLists = make(map[string]*ExemptList)
+
+    data, appErr := p.API.KVGet(exemptListsKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    if data != nil {
+        if err := json.Unmarshal(data, &p.exemptLists); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// saveExemptLists persists the named lists. Callers must hold exemptMutex.
+func (p *Plugin) saveExemptLists() error {
+    data, err := json.Marshal(p.exemptLists)
+    if err != nil {
+        return err
+    }
+
+    if appErr := p.API.KVSet(exemptListsKey, data); appErr != nil {
+        return appErr
+    }
+
+    return nil
+}
+
+// exemptingList returns the name of a named list applicable to the channel
+// that exempts the user, or an empty string. Team memberships are only looked
+// up when a matching list is restricted to teams.
+func (p *Plugin) exemptingList(user *model.User, channelID string) string {
+    p.rlockExempt()
+    defer p.exemptMutex.RUnlock()
+
+    var userTeams map[string]bool
+    for name, list := range p.exemptLists {
+        if !list.hasUser(user.Username) {
+            continue
+        }
+
+        if !list.hasConditions() || contains(list.Channels, channelID) {
+            return name
+        }
+
+        if len(list.Teams) == 0 {
+            continue
+        }
+
+        if userTeams == nil {
+            userTeams = make(map[string]bool)
+            teams, err := p.API.GetTeamsForUser(user.Id)
+            if err != nil {
+                p.API.LogError("Failed to get teams", "error", err.Error())
+                continue
+            }
+            for _, team := range teams {
+                userTeams[team.Id] = true
+            }
+        }
+
+        for _, teamID := range list.Teams {
+            if userTeams[teamID] {
+                return name
+            }
+        }
+    }
+
+    return ""
+}
+
+// listsContaining describes the named lists that contain the user and where
+// each applies.
+func (p *Plugin) listsContaining(username string) []string {
+    p.exemptMutex.RLock()
+    defer p.exemptMutex.RUnlock()
+
+    lists := []string{}
+    for name, list := range p.exemptLists {
+        if list.hasUser(username) {
+            lists = append(lists, fmt.Sprintf("%s (applies %s)", name, p.describeConditions(list)))
+        }
+    }
+    sort.Strings(lists)
+    return lists
+}
+
+func (p *Plugin) listsCommand() *model.CommandResponse {
+    p.exemptMutex.RLock()
+    defer p.exemptMutex.RUnlock()
+
+    names := make([]string, 0, len(p.exemptLists))
+    for name := range p.exemptLists {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var text strings.Builder
+    text.WriteString("Exempt lists:\n")
+    text.WriteString(fmt.Sprintf("* %s (plugin settings, applies everywhere)\n", defaultExemptList))
+
+    for _, name := range names {
+        list := p.exemptLists[name]
+        text.WriteString(fmt.Sprintf("* %s (%d users, applies %s)\n", name, len(list.Users), p.describeConditions(list)))
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        text.String(),
+    }
+}
+
+func (p *Plugin) describeConditions(list *ExemptList) string {
+    if !list.hasConditions() {
+        return "everywhere"
+    }
+
+    conditions := []string{}
+    for _, teamID := range list.Teams {
+        name := teamID
+        if team, err := p.API.GetTeam(teamID); err == nil {
+            name = team.Name
+        }
+        conditions = append(conditions, "team:"+name)
+    }
+    for _, channelID := range list.Channels {
+        conditions = append(conditions, "channel:"+channelID)
+    }
+
+    return "to " + strings.Join(conditions, ", ")
+}
+
+func (p *Plugin) createListCommand(name string) *model.CommandResponse {
+    name = strings.ToLower(name)
+    if name == defaultExemptList {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("The %s list is managed through the plugin settings.", defaultExemptList),
+        }
+    }
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    if _, exists := p.exemptLists[name]; exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s already exists.", name),
+        }
+    }
+
+    p.exemptLists[name] = &ExemptList{Name: name, Users: []string{}}
+
+    if err := p.saveExemptLists(); err != nil {
+        delete(p.exemptLists, name)
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Created exempt list %s. It applies everywhere until a policy is set with `/%s list-policy`.", name, config.GetConfig().CommandTrigger),
+    }
+}
+
+func (p *Plugin) deleteListCommand(name string) *model.CommandResponse {
+    name = strings.ToLower(name)
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    delete(p.exemptLists, name)
+
+    if err := p.saveExemptLists(); err != nil {
+        p.exemptLists[name] = list
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Deleted exempt list %s.", name),
+    }
+}
+
+// listPolicyCommand replaces the conditions of a named list. Each condition is
+// either team:<team-name> or channel:<channel-id>; no conditions makes the
+// list apply everywhere.
+func (p *Plugin) listPolicyCommand(name string, conditions []string) *model.CommandResponse {
+    name = strings.ToLower(name)
+
+    teams := []string{}
+    channels := []string{}
+    for _, condition := range conditions {
+        parts := strings.SplitN(condition, ":", 2)
+        if len(parts) != 2 || parts[1] == "" {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        fmt.Sprintf("Invalid condition %s. Use team:<team-name> or channel:<channel-id>.", condition),
+            }
+        }
+
+        kind, value := parts[0], parts[1]
+        switch kind {
+        case "team":
+            team, appErr := p.API.GetTeamByName(value)
+            if appErr != nil {
+                return &model.CommandResponse{
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                    Text:        fmt.Sprintf("Team %s not found.", value),
+                }
+            }
+            teams = append(teams, team.Id)
+        case "channel":
+            if _, appErr := p.API.GetChannel(value); appErr != nil {
+                return &model.CommandResponse{
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                    Text:        fmt.Sprintf("Channel %s not found.", value),
+                }
+            }
+            channels = append(channels, value)
+        default:
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        fmt.Sprintf("Invalid condition %s. Use team:<team-name> or channel:<channel-id>.", condition),
+            }
+        }
+    }
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    oldTeams, oldChannels := list.Teams, list.Channels
+    list.Teams, list.Channels = teams, channels
+
+    if err := p.saveExemptLists(); err != nil {
+        list.Teams, list.Channels = oldTeams, oldChannels
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Exempt list %s now applies %s.", name, p.describeConditions(list)),
+    }
+}
+
+func (p *Plugin) exemptUserInListCommand(name, username string) *model.CommandResponse {
+    name = strings.ToLower(name)
+    username = config.CanonicalUsername(username)
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    if list.hasUser(username) {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("User %s is already in exempt list %s.", username, name),
+        }
+    }
+
+    if conf := config.GetConfig(); conf.ExemptLimitReached(len(list.Users)) {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Cannot exempt %s: exempt list %s already has the maximum of %d users.", username, name, conf.MaxExemptUsers),
+        }
+    }
+
+    list.Users = append(list.Users, username)
+
+    if err := p.saveExemptLists(); err != nil {
+        list.Users = list.Users[:len(list.Users)-1]
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("User %s added to exempt list %s.", username, name),
+    }
+}
+
+// splitListArgument splits the arguments of unexempt into usernames and the
+// list they apply to. The last argument names the list when there are
+// several and it is the default list or an existing named list.
+func (p *Plugin) splitListArgument(args []string) ([]string, string) {
+    if len(args) < 2 {
+        return args, ""
+    }
+
+    last := strings.ToLower(args[len(args)-1])
+    if last == defaultExemptList {
+        return args[:len(args)-1], last
+    }
+
+    p.exemptMutex.RLock()
+    _, exists := p.exemptLists[last]
+    p.exemptMutex.RUnlock()
+    if exists {
+        return args[:len(args)-1], last
+    }
+    return args, ""
+}
+
+// removeExemptUsers returns the users left after removing usernames, the
+// usernames removed and those that were not in the list, all compared in
+// canonical form.
+func removeExemptUsers(users, usernames []string) (kept, removed, missing []string) {
+    remove := make(map[string]bool, len(usernames))
+    for _, username := range usernames {
+        if username = config.CanonicalUsername(username); username != "" {
+            remove[username] = true
+        }
+    }
+
+    kept = []string{}
+    present := make(map[string]bool)
+    for _, user := range users {
+        canonical := config.CanonicalUsername(user)
+        if remove[canonical] {
+            if !present[canonical] {
+                removed = append(removed, canonical)
+            }
+            present[canonical] = true
+            continue
+        }
+        kept = append(kept, user)
+    }
+
+    for _, username := range usernames {
+        username = config.CanonicalUsername(username)
+        if username != "" && !present[username] && !contains(missing, username) {
+            missing = append(missing, username)
+        }
+    }
+
+    return kept, removed, missing
+}
+
+// unexemptResultText reports which users were removed from the list and which
+// were not in it.
+func unexemptResultText(removed, missing []string, listName string) string {
+    switch {
+    case len(removed) == 1 && len(missing) == 0:
+        return fmt.Sprintf("User %s removed from %s.", removed[0], listName)
+    case len(removed) == 0 && len(missing) == 1:
+        return fmt.Sprintf("User %s is not in %s.", missing[0], listName)
+    }
+
+    text := fmt.Sprintf("Removed %d user(s) from %s", len(removed), listName)
+    if len(removed) > 0 {
+        text += ": " + strings.Join(removed, ", ")
+    }
+    text += "."
+    if len(missing) > 0 {
+        text += fmt.Sprintf(" Not in the list: %s.", strings.Join(missing, ", "))
+    }
+    return text
+}
+
+// unexemptUsersInListCommand removes users from a named list and saves the
+// lists once.
+func (p *Plugin) unexemptUsersInListCommand(name string, usernames []string) *model.CommandResponse {
+    name = strings.ToLower(name)
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    newUsers, removed, missing := removeExemptUsers(list.Users, usernames)
+    listName := "exempt list " + name
+
+    if len(removed) == 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        unexemptResultText(removed, missing, listName),
+        }
+    }
+
+    oldUsers := list.Users
+    list.Users = newUsers
+
+    if err := p.saveExemptLists(); err != nil {
+        list.Users = oldUsers
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        unexemptResultText(removed, missing, listName),
+    }
+}
+
+// clearExemptCommand empties the default list. Without confirmed it only
+// reports how many users would be removed.
+func (p *Plugin) clearExemptCommand(confirmed bool) *model.CommandResponse {
+    conf := config.GetConfig()
+    users := conf.ExemptedUserList()
+    if len(users) == 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        "No users are currently exempted.",
+        }
+    }
+
+    if !confirmed {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("This removes all %d users from the exempted list. Run `/%s clear-exempt --confirm` to proceed.", len(users), conf.CommandTrigger),
+        }
+    }
+
+    conf.ExemptedUsers = ""
+
+    if err := p.API.SavePluginConfig(conf.ToMap()); err != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save configuration: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Removed all %d users from the exempted list.", len(users)),
+    }
+}
+
+// clearExemptInListCommand empties a named list. Without confirmed it only
+// reports how many users would be removed.
+func (p *Plugin) clearExemptInListCommand(name string, confirmed bool) *model.CommandResponse {
+    name = strings.ToLower(name)
+
+    p.exemptMutex.Lock()
+    defer p.exemptMutex.Unlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    if len(list.Users) == 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s is empty.", name),
+        }
+    }
+
+    if !confirmed {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("This removes all %d users from exempt list %s. Run `/%s clear-exempt %s --confirm` to proceed.", len(list.Users), name, config.GetConfig().CommandTrigger, name),
+        }
+    }
+
+    oldUsers := list.Users
+    list.Users = []string{}
+
+    if err := p.saveExemptLists(); err != nil {
+        list.Users = oldUsers
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save exempt lists: %v", err),
+        }
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Removed all %d users from exempt list %s.", len(oldUsers), name),
+    }
+}
+
+func (p *Plugin) listExemptInListCommand(name string) *model.CommandResponse {
+    name = strings.ToLower(name)
+
+    p.exemptMutex.RLock()
+    defer p.exemptMutex.RUnlock()
+
+    list, exists := p.exemptLists[name]
+    if !exists {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Exempt list %s does not exist.", name),
+        }
+    }
+
+    if len(list.Users) == 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("No users are in exempt list %s.", name),
+        }
+    }
+
+    text := fmt.Sprintf("Users in exempt list %s (%s, applies %s):\n", name, exemptSizeText(len(list.Users), config.GetConfig().MaxExemptUsers), p.describeConditions(list))
+    for _, user := range list.Users {
+        text += fmt.Sprintf("* %s\n", user)
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        text,
+    }
+}
+
+func contains(slice []string, item string) bool {
+    for _, s := range slice {
+        if s == item {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// expectAnyCommandCalls lets every API call a command can make succeed with
+// an empty result or fail with not found, so fuzzed commands reach their
+// parsers whatever they ask for. The caller is a system admin, so every
+// subcommand is reachable.
+func expectAnyCommandCalls(api *testAPI) {
+    notFound := model.NewAppError("fuzz", "not_found", nil, "", 404)
+
+    api.On("GetUser", mock.Anything).Return(&model.User{Id: "fuzzer", Username: "fuzzer", Roles: model.SystemUserRoleId}, nil).Maybe()
+    api.On("GetUserByUsername", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("HasPermissionTo", mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("HasPermissionToTeam", mock.Anything, mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("HasPermissionToChannel", mock.Anything, mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("GetTeamsForUser", mock.Anything).Return([]*model.Team{}, nil).Maybe()
+    api.On("GetTeam", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetTeamByName", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetChannel", mock.Anything).Return(&model.Channel{Id: "channel", Type: model.ChannelTypeDirect}, nil).Maybe()
+    api.On("GetChannelMembers", mock.Anything, mock.Anything, mock.Anything).Return(model.ChannelMembers{}, nil).Maybe()
+    api.On("GetPostsForChannel", mock.Anything, mock.Anything, mock.Anything).Return(model.NewPostList(), nil).Maybe()
+    api.On("SendEphemeralPost", mock.Anything, mock.Anything).Return(&model.Post{}).Maybe()
+    api.On("SavePluginConfig", mock.Anything).Return(nil).Maybe()
+    api.On("KVSetWithExpiry", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func FuzzExecuteCommand(f *testing.F) {
+    f.Add("/custom-dm exempt alice")
+    f.Add("/custom-dm list-exempt --json")
+
+    f.Fuzz(func(t *testing.T, command string) {
+        api := newTestAPI(t)
+        expectAnyCommandCalls(api)
+        p := newTestPlugin(t, api, &config.Configuration{Enabled: true})
+
+        response, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{
+            UserId:    "fuzzer",
+            ChannelId: "channel",
+            TeamId:    "team",
+            Command:   command,
+        })
+        assert.True(t, response != nil || appErr != nil, "command %q got no response", command)
+    })
+}
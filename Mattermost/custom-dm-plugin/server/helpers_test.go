@@ -0,0 +1,100 @@
+package main
+
+import (
+    "bytes"
+    "sync"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin/plugintest"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// testAPI is a plugintest.API mock backed by an in-memory KV store. Log
+// calls are accepted without expectations; other calls need them.
+type testAPI struct {
+    *plugintest.API
+
+    kvMutex sync.Mutex
+    kv      map[string][]byte
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+    api := &testAPI{API: &plugintest.API{}, kv: make(map[string][]byte)}
+    t.Cleanup(func() { api.AssertExpectations(t) })
+    return api
+}
+
+func (a *testAPI) KVGet(key string) ([]byte, *model.AppError) {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    return a.kv[key], nil
+}
+
+func (a *testAPI) KVSet(key string, value []byte) *model.AppError {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    a.kv[key] = append([]byte(nil), value...)
+    return nil
+}
+
+func (a *testAPI) KVSetWithExpiry(key string, value []byte, expireInSeconds int64) *model.AppError {
+    return a.KVSet(key, value)
+}
+
+func (a *testAPI) KVDelete(key string) *model.AppError {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    delete(a.kv, key)
+    return nil
+}
+
+func (a *testAPI) KVCompareAndSet(key string, oldValue, newValue []byte) (bool, *model.AppError) {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+
+    current, exists := a.kv[key]
+    if (oldValue == nil && exists) || (oldValue != nil && !bytes.Equal(current, oldValue)) {
+        return false, nil
+    }
+    a.kv[key] = append([]byte(nil), newValue...)
+    return true, nil
+}
+
+func (a *testAPI) LogDebug(string, ...interface{}) {}
+func (a *testAPI) LogInfo(string, ...interface{})  {}
+func (a *testAPI) LogWarn(string, ...interface{})  {}
+func (a *testAPI) LogError(string, ...interface{}) {}
+
+// newTestPlugin returns a plugin using api with the processed settings.
+func newTestPlugin(t *testing.T, api *testAPI, settings *config.Configuration) *Plugin {
+    config.Mattermost = api
+    require.NoError(t, settings.ProcessConfiguration())
+    config.SetConfig(settings)
+    t.Cleanup(func() {
+        config.SetConfig(nil)
+        config.Mattermost = nil
+    })
+
+    p := &Plugin{exemptLists: make(map[string]*ExemptList)}
+    p.SetAPI(api)
+    return p
+}
+
+// expectRegularUser sets up the permission checks of a user who is not an
+// admin of the system or of any team.
+func expectRegularUser(api *testAPI, userID string) {
+    api.On("HasPermissionTo", userID, model.PermissionManageSystem).Return(false)
+    api.On("GetTeamsForUser", userID).Return([]*model.Team{}, nil)
+}
+
+// expectDirectChannel sets up the direct channel between two users.
+func expectDirectChannel(api *testAPI, channelID, userID, otherID string) {
+    api.On("GetChannel", channelID).Return(&model.Channel{
+        Id:   channelID,
+        Name: model.GetDMNameFromIds(userID, otherID),
+        Type: model.ChannelTypeDirect,
+    }, nil)
+}
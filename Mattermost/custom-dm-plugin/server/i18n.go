@@ -0,0 +1,84 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+const (
+    // Locale used when a string has no translation for the user's locale
+    defaultLocale = "en"
+)
+
+// catalog holds the user-facing strings keyed by locale and message ID.
+// Translations are added by creating another locale entry with the same IDs.
+var catalog = map[string]map[string]string{
+    "en": {
+        "help": `Custom DM Plugin Commands:
+* /%[1]s help - Show this help text
+* /%[1]s export-exempt [--json] - Export current exempted users to exempt-users.txt
+* /%[1]s import-exempt [filename] [--json] - Import exempted users from a file
+* /%[1]s exempt [username] [list] - Add a user to an exempt list (default list if omitted)
+* /%[1]s unexempt [username ...] [list] - Remove one or more users from an exempt list (default list if omitted)
+* /%[1]s clear-exempt [list] [--confirm] - Remove all users from an exempt list (default list if omitted)
+* /%[1]s list-exempt [list] - List all users in an exempt list (default list if omitted)
+* /%[1]s lists - Show all named exempt lists and where they apply
+* /%[1]s create-list [name] - Create a named exempt list
+* /%[1]s delete-list [name] - Delete a named exempt list
+* /%[1]s list-policy [name] [team:team-name|channel:channel-id ...] - Restrict where a named list applies (no conditions applies everywhere)
+* /%[1]s policy [@username] - Explain why a user is or isn't restricted
+* /%[1]s pause [duration] - Pause DM restrictions for a duration such as 30m or 2h
+* /%[1]s resume - Resume DM restrictions before the pause expires
+* /%[1]s status - Show whether DM restrictions are enforced or paused
+
+Note: Only administrators can use these commands.
+
+`,
+        "status.paused":   "Status: DM restrictions are paused for another %s.",
+        "status.enforced": "Status: DM restrictions are being enforced.",
+
+        "channel.restricted_warning": "Messaging in this group message may be limited: %s may not be able to post here because of the direct message policy.",
+    },
+}
+
+// translate returns the message for the locale, falling back to the base
+// language (e.g. "pt" for "pt-BR") and then to English. Arguments are
+// formatted into the message.
+func translate(locale, id string, args ...interface{}) string {
+    message, ok := lookupMessage(locale, id)
+    if !ok {
+        if i := strings.IndexAny(locale, "-_"); i > 0 {
+            message, ok = lookupMessage(locale[:i], id)
+        }
+    }
+    if !ok {
+        message, ok = lookupMessage(defaultLocale, id)
+    }
+    if !ok {
+        return id
+    }
+
+    if len(args) > 0 {
+        return fmt.Sprintf(message, args...)
+    }
+    return message
+}
+
+func lookupMessage(locale, id string) (string, bool) {
+    messages, ok := catalog[strings.ToLower(locale)]
+    if !ok {
+        return "", false
+    }
+    message, ok := messages[id]
+    return message, ok
+}
+
+// userLocale returns the locale configured for the user, or the default
+// locale when the user cannot be loaded.
+func (p *Plugin) userLocale(userID string) string {
+    user, appErr := p.API.GetUser(userID)
+    if appErr != nil || user.Locale == "" {
+        return defaultLocale
+    }
+    return user.Locale
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// rejectionNotices remembers when each user was last told in a channel that
+// their message was rejected. The zero value is ready to use.
+type rejectionNotices struct {
+    mu          sync.Mutex
+    notified    map[string]time.Time // map[userID/channelID]time of the last notice
+    lastCleanup time.Time
+}
+
+// allow reports whether the user should be told about a rejection in the
+// channel, recording the notice if so. A window of 0 allows every notice.
+func (n *rejectionNotices) allow(userID, channelID string, window time.Duration, now time.Time) bool {
+    if window <= 0 {
+        return true
+    }
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    if n.notified == nil {
+        n.notified = make(map[string]time.Time)
+    }
+
+    // Drop expired entries at most once per window so the map cannot grow
+    // with every channel a user was ever blocked in
+    if now.Sub(n.lastCleanup) >= window {
+        for key, at := range n.notified {
+            if now.Sub(at) >= window {
+                delete(n.notified, key)
+            }
+        }
+        n.lastCleanup = now
+    }
+
+    key := userID + "/" + channelID
+    if at, ok := n.notified[key]; ok && now.Sub(at) < window {
+        return false
+    }
+
+    n.notified[key] = now
+    return true
+}
+
+// withSuggestion appends the rendered SuggestionMessage to a rejection
+// message when a channel is suggested and the sender can access it.
+func (p *Plugin) withSuggestion(userID, message string) string {
+    conf := config.GetConfig()
+    channel := conf.SuggestedChannelInfo()
+    if channel == nil {
+        return message
+    }
+
+    canAccess := false
+    if channel.Type == model.ChannelTypeOpen {
+        canAccess = p.API.HasPermissionToTeam(userID, channel.TeamId, model.PermissionReadPublicChannel)
+    } else {
+        canAccess = p.API.HasPermissionToChannel(userID, channel.Id, model.PermissionReadChannel)
+    }
+    if !canAccess {
+        return message
+    }
+
+    if suggestion := conf.Suggestion(); suggestion != "" {
+        return message + " " + suggestion
+    }
+    return message
+}
+
+// notifyRejection tells the author that their post was rejected, unless they
+// were already told in the same channel within RejectionNoticeWindow.
+func (p *Plugin) notifyRejection(post *model.Post, message string) {
+    atomic.AddInt64(&p.vars.postsRejected, 1)
+
+    window := time.Duration(config.GetConfig().RejectionNoticeWindow) * time.Minute
+    if !p.rejectionNotices.allow(post.UserId, post.ChannelId, window, time.Now()) {
+        atomic.AddInt64(&p.vars.noticesSuppressed, 1)
+        return
+    }
+    atomic.AddInt64(&p.vars.noticesSent, 1)
+
+    p.API.SendEphemeralPost(post.UserId, &model.Post{
+        ChannelId: post.ChannelId,
+        Message:   message,
+    })
+}
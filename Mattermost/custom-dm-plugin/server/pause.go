@@ -0,0 +1,117 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing the enforcement pause expiry (unix milliseconds) in KV store
+    pausedUntilKey = "paused_until"
+)
+
+func (p *Plugin) loadPauseState() error {
+    data, appErr := p.API.KVGet(pausedUntilKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    p.pauseMutex.Lock()
+    defer p.pauseMutex.Unlock()
+
+    p.pausedUntil = time.Time{}
+    if data == nil {
+        return nil
+    }
+
+    millis, err := strconv.ParseInt(string(data), 10, 64)
+    if err != nil {
+        return err
+    }
+    p.pausedUntil = time.Unix(0, millis*int64(time.Millisecond))
+
+    return nil
+}
+
+// pauseRemaining returns how long enforcement stays paused, or zero when
+// enforcement is active.
+func (p *Plugin) pauseRemaining() time.Duration {
+    p.pauseMutex.RLock()
+    defer p.pauseMutex.RUnlock()
+
+    remaining := time.Until(p.pausedUntil)
+    if remaining < 0 {
+        return 0
+    }
+    return remaining
+}
+
+func (p *Plugin) pauseCommand(durationArg string) *model.CommandResponse {
+    duration, err := time.ParseDuration(durationArg)
+    if err != nil || duration <= 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Invalid duration %s. Use values such as 30m or 2h.", durationArg),
+        }
+    }
+
+    until := time.Now().Add(duration)
+    millis := until.UnixNano() / int64(time.Millisecond)
+    if appErr := p.API.KVSet(pausedUntilKey, []byte(strconv.FormatInt(millis, 10))); appErr != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save pause state: %v", appErr),
+        }
+    }
+
+    p.pauseMutex.Lock()
+    p.pausedUntil = until
+    p.pauseMutex.Unlock()
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("DM restrictions paused for %s (until %s).", duration, until.UTC().Format(time.RFC1123)),
+    }
+}
+
+func (p *Plugin) resumeCommand() *model.CommandResponse {
+    if p.pauseRemaining() == 0 {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        "DM restrictions are not paused.",
+        }
+    }
+
+    if appErr := p.API.KVDelete(pausedUntilKey); appErr != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to save pause state: %v", appErr),
+        }
+    }
+
+    p.pauseMutex.Lock()
+    p.pausedUntil = time.Time{}
+    p.pauseMutex.Unlock()
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        "DM restrictions resumed.",
+    }
+}
+
+func (p *Plugin) statusCommand(locale string) *model.CommandResponse {
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        p.statusText(locale),
+    }
+}
+
+func (p *Plugin) statusText(locale string) string {
+    if remaining := p.pauseRemaining(); remaining > 0 {
+        return translate(locale, "status.paused", remaining.Round(time.Second))
+    }
+    return translate(locale, "status.enforced")
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
     "fmt"
     "io/ioutil"
     "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
     "github.com/mattermost/mattermost-server/v6/model"
     "github.com/mattermost/mattermost-server/v6/plugin"
     "github.com/pkg/errors"
@@ -13,6 +20,17 @@ import (
 
 type Plugin struct {
     plugin.MattermostPlugin
+    exemptLists map[string]*ExemptList // map[listName]list, excluding the default list
+    exemptMutex sync.RWMutex
+    pausedUntil time.Time // enforcement is paused until this time
+    pauseMutex  sync.RWMutex
+
+    registeredTrigger string     // trigger of the currently registered slash command
+    commandLock       sync.Mutex // guards registeredTrigger
+
+    commandLimiter   commandLimiter
+    rejectionNotices rejectionNotices
+    vars             debugVars
 }
 
 func (p *Plugin) OnActivate() error {
@@ -22,45 +40,68 @@ func (p *Plugin) OnActivate() error {
         return err
     }
 
+    if err := p.loadExemptLists(); err != nil {
+        return errors.Wrap(err, "failed to load exempt lists")
+    }
+
+    if err := p.loadPauseState(); err != nil {
+        return errors.Wrap(err, "failed to load pause state")
+    }
+
+    // Losing rate limits or notice times is not worth failing activation
+    if err := p.loadRuntimeState(); err != nil {
+        p.API.LogWarn("Failed to restore runtime state", "error", err.Error())
+    }
+
+    return nil
+}
+
+// OnDeactivate saves the in-memory rate limits and rejection notice times so
+// they survive a restart. The plugin runs no background goroutines.
+func (p *Plugin) OnDeactivate() error {
+    if err := p.saveRuntimeState(); err != nil {
+        p.API.LogError("Failed to save runtime state", "error", err.Error())
+    }
     return nil
 }
 
 func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
     split := strings.Fields(args.Command)
+    if len(split) == 0 {
+        return p.helpCommand(p.userLocale(args.UserId)), nil
+    }
     command := split[0]
     parameters := []string{}
     if len(split) > 1 {
         parameters = split[1:]
     }
 
-    if command != "/custom-dm" {
+    if command != "/"+config.GetConfig().CommandTrigger {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
             Text:        fmt.Sprintf("Unknown command: %s", command),
         }, nil
     }
 
+    atomic.AddInt64(&p.vars.commandsRun, 1)
+    if ok, wait := p.commandLimiter.allow(args.UserId, config.GetConfig().CommandRateLimit, time.Now()); !ok {
+        atomic.AddInt64(&p.vars.commandsLimited, 1)
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("You are sending commands too quickly. Try again in %d seconds.", cooldownSeconds(wait)),
+        }, nil
+    }
+
+    parameters, asJSON := extractFlag(parameters, "--json")
     if len(parameters) == 0 {
-        return p.helpCommand(), nil
+        return p.helpCommand(p.userLocale(args.UserId)), nil
     }
 
-    isAdmin := false
-    teams, err := p.API.GetTeamsForUser(args.UserId)
+    isAdmin, err := p.isAdmin(args.UserId)
     if err != nil {
         return nil, model.NewAppError("ExecuteCommand", "Failed to get teams", nil, err.Error(), 500)
     }
 
-    for _, team := range teams {
-        member, err := p.API.GetTeamMember(team.Id, args.UserId)
-        if err != nil {
-            continue
-        }
-        if member.SchemeAdmin {
-            isAdmin = true
-            break
-        }
-    }
-
     if !isAdmin {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
@@ -70,9 +111,9 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 
     switch parameters[0] {
     case "help":
-        return p.helpCommand(), nil
+        return p.helpCommand(p.userLocale(args.UserId)), nil
     case "export-exempt":
-        return p.exportExemptCommand(), nil
+        return p.exportExemptCommand(asJSON), nil
     case "import-exempt":
         if len(parameters) < 2 {
             return &model.CommandResponse{
@@ -80,7 +121,7 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
                 Text:        "Please provide a filename to import from.",
             }, nil
         }
-        return p.importExemptCommand(parameters[1]), nil
+        return p.importExemptCommand(parameters[1], asJSON), nil
     case "exempt":
         if len(parameters) < 2 {
             return &model.CommandResponse{
@@ -88,6 +129,9 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
                 Text:        "Please provide a username to exempt.",
             }, nil
         }
+        if len(parameters) > 2 && !strings.EqualFold(parameters[2], defaultExemptList) {
+            return p.exemptUserInListCommand(parameters[2], parameters[1]), nil
+        }
         return p.exemptUserCommand(parameters[1]), nil
     case "unexempt":
         if len(parameters) < 2 {
@@ -96,39 +140,122 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
                 Text:        "Please provide a username to unexempt.",
             }, nil
         }
-        return p.unexemptUserCommand(parameters[1]), nil
+        usernames, list := p.splitListArgument(parameters[1:])
+        if list != "" && list != defaultExemptList {
+            return p.unexemptUsersInListCommand(list, usernames), nil
+        }
+        return p.unexemptUsersCommand(usernames), nil
+    case "clear-exempt":
+        parameters, confirmed := extractFlag(parameters, "--confirm")
+        if len(parameters) > 1 && !strings.EqualFold(parameters[1], defaultExemptList) {
+            return p.clearExemptInListCommand(parameters[1], confirmed), nil
+        }
+        return p.clearExemptCommand(confirmed), nil
     case "list-exempt":
+        if len(parameters) > 1 && !strings.EqualFold(parameters[1], defaultExemptList) {
+            return p.listExemptInListCommand(parameters[1]), nil
+        }
         return p.listExemptCommand(), nil
+    case "pause":
+        if len(parameters) < 2 {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        "Please provide a duration to pause for, e.g. 2h.",
+            }, nil
+        }
+        return p.pauseCommand(parameters[1]), nil
+    case "resume":
+        return p.resumeCommand(), nil
+    case "status":
+        return p.statusCommand(p.userLocale(args.UserId)), nil
+    case "policy":
+        if len(parameters) < 2 {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        "Please provide a username to explain the policy for.",
+            }, nil
+        }
+        return p.policyCommand(parameters[1]), nil
+    case "lists":
+        return p.listsCommand(), nil
+    case "create-list":
+        if len(parameters) < 2 {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        "Please provide a name for the exempt list.",
+            }, nil
+        }
+        return p.createListCommand(parameters[1]), nil
+    case "delete-list":
+        if len(parameters) < 2 {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        "Please provide the name of the exempt list to delete.",
+            }, nil
+        }
+        return p.deleteListCommand(parameters[1]), nil
+    case "list-policy":
+        if len(parameters) < 2 {
+            return &model.CommandResponse{
+                ResponseType: model.CommandResponseTypeEphemeral,
+                Text:        "Please provide the name of the exempt list and its conditions.",
+            }, nil
+        }
+        return p.listPolicyCommand(parameters[1], parameters[2:]), nil
     default:
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
-            Text:        fmt.Sprintf("Unknown subcommand: %s. Use '/custom-dm help' for usage.", parameters[0]),
+            Text:        fmt.Sprintf("Unknown subcommand: %s. Use '/%s help' for usage.", parameters[0], config.GetConfig().CommandTrigger),
         }, nil
     }
 }
 
-func (p *Plugin) helpCommand() *model.CommandResponse {
-    text := `Custom DM Plugin Commands:
-* /custom-dm help - Show this help text
-* /custom-dm export-exempt - Export current exempted users to exempt-users.txt
-* /custom-dm import-exempt [filename] - Import exempted users from a file
-* /custom-dm exempt [username] - Add a user to exempted list
-* /custom-dm unexempt [username] - Remove a user from exempted list
-* /custom-dm list-exempt - List all currently exempted users
-
-Note: Only administrators can use these commands.`
-
+func (p *Plugin) helpCommand(locale string) *model.CommandResponse {
     return &model.CommandResponse{
         ResponseType: model.CommandResponseTypeEphemeral,
-        Text:        text,
+        Text:        translate(locale, "help", config.GetConfig().CommandTrigger) + p.statusText(locale),
     }
 }
 
-func (p *Plugin) exportExemptCommand() *model.CommandResponse {
+// ExportResult describes the outcome of export-exempt for programmatic use.
+type ExportResult struct {
+    File   string   `json:"file"`
+    Count  int      `json:"count"`
+    Users  []string `json:"users"`
+    Errors []string `json:"errors"`
+}
+
+// ImportResult describes the outcome of import-exempt for programmatic use.
+type ImportResult struct {
+    File     string   `json:"file"`
+    Imported int      `json:"imported"`
+    Users    []string `json:"users"`
+    Skipped  []string `json:"skipped"` // blank or duplicate entries
+    Errors   []string `json:"errors"`
+}
+
+func (p *Plugin) exportExemptCommand(asJSON bool) *model.CommandResponse {
     conf := config.GetConfig()
     filename := "exempt-users.txt"
-    
+
+    result := &ExportResult{File: filename, Users: []string{}, Errors: []string{}}
+    for _, user := range strings.Split(conf.ExemptedUsers, ",") {
+        user = strings.TrimSpace(user)
+        if user != "" {
+            result.Users = append(result.Users, user)
+        }
+    }
+    result.Count = len(result.Users)
+
     err := ioutil.WriteFile(filename, []byte(conf.ExemptedUsers), 0644)
+    if err != nil {
+        result.Errors = append(result.Errors, err.Error())
+    }
+
+    if asJSON {
+        return jsonResponse(result)
+    }
+
     if err != nil {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
@@ -142,52 +269,134 @@ func (p *Plugin) exportExemptCommand() *model.CommandResponse {
     }
 }
 
-func (p *Plugin) importExemptCommand(filename string) *model.CommandResponse {
+func (p *Plugin) importExemptCommand(filename string, asJSON bool) *model.CommandResponse {
+    result := &ImportResult{File: filename, Users: []string{}, Skipped: []string{}, Errors: []string{}}
+
     data, err := ioutil.ReadFile(filename)
     if err != nil {
+        result.Errors = append(result.Errors, err.Error())
+        if asJSON {
+            return jsonResponse(result)
+        }
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
             Text:        fmt.Sprintf("Failed to read file: %v", err),
         }
     }
 
+    seen := make(map[string]bool)
+    entries := strings.FieldsFunc(string(data), func(r rune) bool {
+        return r == ',' || r == '\n' || r == '\r'
+    })
+    for _, user := range entries {
+        user = config.CanonicalUsername(user)
+        if user == "" || seen[user] {
+            result.Skipped = append(result.Skipped, user)
+            continue
+        }
+        seen[user] = true
+        result.Users = append(result.Users, user)
+    }
+    result.Imported = len(result.Users)
+
     conf := config.GetConfig()
-    conf.ExemptedUsers = string(data)
+    if conf.MaxExemptUsers > 0 && len(result.Users) > conf.MaxExemptUsers {
+        err := errors.Errorf("file contains %d users, which exceeds the maximum of %d", len(result.Users), conf.MaxExemptUsers)
+        result.Imported = 0
+        result.Errors = append(result.Errors, err.Error())
+        if asJSON {
+            return jsonResponse(result)
+        }
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Import rejected: %v.", err),
+        }
+    }
+
+    conf.ExemptedUsers = strings.Join(result.Users, ",")
 
     if err := p.API.SavePluginConfig(conf.ToMap()); err != nil {
+        result.Imported = 0
+        result.Errors = append(result.Errors, err.Error())
+        if asJSON {
+            return jsonResponse(result)
+        }
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
             Text:        fmt.Sprintf("Failed to save configuration: %v", err),
         }
     }
 
+    if asJSON {
+        return jsonResponse(result)
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        fmt.Sprintf("Exempted users imported successfully (%d imported, %d skipped).", result.Imported, len(result.Skipped)),
+    }
+}
+
+// jsonResponse renders a result object as the command response text.
+func jsonResponse(result interface{}) *model.CommandResponse {
+    data, err := json.MarshalIndent(result, "", "  ")
+    if err != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to encode result: %v", err),
+        }
+    }
+
     return &model.CommandResponse{
         ResponseType: model.CommandResponseTypeEphemeral,
-        Text:        "Exempted users imported successfully.",
+        Text:        string(data),
     }
 }
 
+// extractFlag removes every occurrence of flag from parameters and reports
+// whether it was present.
+func extractFlag(parameters []string, flag string) ([]string, bool) {
+    remaining := []string{}
+    found := false
+    for _, parameter := range parameters {
+        if parameter == flag {
+            found = true
+            continue
+        }
+        remaining = append(remaining, parameter)
+    }
+    return remaining, found
+}
+
 func (p *Plugin) exemptUserCommand(username string) *model.CommandResponse {
+    username = config.CanonicalUsername(username)
+    if username == "" {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        "Please provide a username to exempt.",
+        }
+    }
+
     conf := config.GetConfig()
-    users := strings.Split(conf.ExemptedUsers, ",")
-    
+
     // Check if user already exists
-    for _, user := range users {
-        if strings.EqualFold(strings.TrimSpace(user), username) {
-            return &model.CommandResponse{
-                ResponseType: model.CommandResponseTypeEphemeral,
-                Text:        fmt.Sprintf("User %s is already exempted.", username),
-            }
+    if p.isUserExempted(username) {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("User %s is already exempted.", username),
         }
     }
 
-    // Add the new user
-    if conf.ExemptedUsers == "" {
-        conf.ExemptedUsers = username
-    } else {
-        conf.ExemptedUsers += "," + username
+    if conf.ExemptLimitReached(len(conf.ExemptedUserList())) {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Cannot exempt %s: the exempt list already has the maximum of %d users.", username, conf.MaxExemptUsers),
+        }
     }
 
+    // Add the new user, rewriting the list in canonical form
+    conf.ExemptedUsers = strings.Join(append(conf.ExemptedUserList(), username), ",")
+
     if err := p.API.SavePluginConfig(conf.ToMap()); err != nil {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
@@ -201,25 +410,17 @@ func (p *Plugin) exemptUserCommand(username string) *model.CommandResponse {
     }
 }
 
-func (p *Plugin) unexemptUserCommand(username string) *model.CommandResponse {
+// unexemptUsersCommand removes users from the default list and saves the
+// settings once.
+func (p *Plugin) unexemptUsersCommand(usernames []string) *model.CommandResponse {
     conf := config.GetConfig()
-    users := strings.Split(conf.ExemptedUsers, ",")
-    newUsers := []string{}
-    found := false
+    newUsers, removed, missing := removeExemptUsers(conf.ExemptedUserList(), usernames)
+    listName := "the exempted list"
 
-    for _, user := range users {
-        user = strings.TrimSpace(user)
-        if !strings.EqualFold(user, username) && user != "" {
-            newUsers = append(newUsers, user)
-        } else {
-            found = true
-        }
-    }
-
-    if !found {
+    if len(removed) == 0 {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
-            Text:        fmt.Sprintf("User %s is not in the exempted list.", username),
+            Text:        unexemptResultText(removed, missing, listName),
         }
     }
 
@@ -234,26 +435,23 @@ func (p *Plugin) unexemptUserCommand(username string) *model.CommandResponse {
 
     return &model.CommandResponse{
         ResponseType: model.CommandResponseTypeEphemeral,
-        Text:        fmt.Sprintf("User %s removed from exempted list.", username),
+        Text:        unexemptResultText(removed, missing, listName),
     }
 }
 
 func (p *Plugin) listExemptCommand() *model.CommandResponse {
     conf := config.GetConfig()
-    if conf.ExemptedUsers == "" {
+    users := conf.ExemptedUserList()
+    if len(users) == 0 {
         return &model.CommandResponse{
             ResponseType: model.CommandResponseTypeEphemeral,
             Text:        "No users are currently exempted.",
         }
     }
 
-    users := strings.Split(conf.ExemptedUsers, ",")
-    text := "Currently exempted users:\n"
+    text := fmt.Sprintf("Currently exempted users (%s):\n", exemptSizeText(len(users), conf.MaxExemptUsers))
     for _, user := range users {
-        user = strings.TrimSpace(user)
-        if user != "" {
-            text += fmt.Sprintf("* %s\n", user)
-        }
+        text += fmt.Sprintf("* %s\n", user)
     }
 
     return &model.CommandResponse{
@@ -262,6 +460,14 @@ func (p *Plugin) listExemptCommand() *model.CommandResponse {
     }
 }
 
+// exemptSizeText describes the size of an exempt list against the cap.
+func exemptSizeText(size, max int) string {
+    if max > 0 {
+        return fmt.Sprintf("%d of %d", size, max)
+    }
+    return fmt.Sprintf("%d", size)
+}
+
 func (p *Plugin) OnConfigurationChange() error {
     if config.Mattermost != nil {
         var configuration config.Configuration
@@ -282,36 +488,66 @@ func (p *Plugin) OnConfigurationChange() error {
         }
 
         config.SetConfig(&configuration)
+
+        if err := p.registerCommand(configuration.CommandTrigger); err != nil {
+            config.Mattermost.LogError("Error in RegisterCommand: " + err.Error())
+            return errors.Wrap(err, "failed to register command")
+        }
+    }
+    return nil
+}
+
+// registerCommand registers the slash command under trigger, removing the
+// previously registered trigger when it changed.
+func (p *Plugin) registerCommand(trigger string) error {
+    p.commandLock.Lock()
+    defer p.commandLock.Unlock()
+
+    if trigger == p.registeredTrigger {
+        return nil
     }
+
+    if p.registeredTrigger != "" {
+        if err := p.API.UnregisterCommand("", p.registeredTrigger); err != nil {
+            p.API.LogWarn("Failed to unregister previous command", "trigger", p.registeredTrigger, "error", err.Error())
+        }
+    }
+
+    if err := p.API.RegisterCommand(&model.Command{
+        Trigger:          trigger,
+        AutoComplete:     true,
+        AutoCompleteDesc: "Manage direct message restrictions",
+        AutoCompleteHint: "[help|exempt|unexempt|clear-exempt|list-exempt|lists|pause|resume|status]",
+    }); err != nil {
+        return err
+    }
+
+    p.registeredTrigger = trigger
     return nil
 }
 
-func (p *Plugin) isEmailDomainBlocked(email string) bool {
+// blockedEmailDomain returns the blocked domain matching the email, or an
+// empty string when the domain is not blocked.
+func (p *Plugin) blockedEmailDomain(email string) string {
     conf := config.GetConfig()
     if conf.BlockedDomains == "" {
-        return false
+        return ""
     }
 
     domains := strings.Split(conf.BlockedDomains, ",")
     for _, domain := range domains {
         domain = strings.TrimSpace(domain)
         if domain != "" && strings.HasSuffix(strings.ToLower(email), strings.ToLower(domain)) {
-            return true
+            return domain
         }
     }
-    return false
+    return ""
 }
 
 func (p *Plugin) isUserExempted(username string) bool {
-    conf := config.GetConfig()
-    if conf.ExemptedUsers == "" {
-        return false
-    }
-
-    users := strings.Split(conf.ExemptedUsers, ",")
-    for _, user := range users {
-        user = strings.TrimSpace(user)
-        if user != "" && strings.EqualFold(username, user) {
+    username = config.CanonicalUsername(username)
+    for _, user := range config.GetConfig().ExemptedUserList() {
+        if user == username {
             return true
         }
     }
@@ -324,6 +560,11 @@ func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*mode
         return nil, ""
     }
 
+    // Enforcement is paused until the pause expires
+    if p.pauseRemaining() > 0 {
+        return nil, ""
+    }
+
     channel, err := p.API.GetChannel(post.ChannelId)
     if err != nil {
         p.API.LogError("Failed to get channel", "error", err.Error())
@@ -333,6 +574,13 @@ func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*mode
     if channel.Type != model.ChannelTypeDirect && channel.Type != model.ChannelTypeGroup {
         return nil, ""
     }
+    atomic.AddInt64(&p.vars.postsChecked, 1)
+
+    // Notes to self are never restricted
+    if isSelfDM(channel, post.UserId) {
+        atomic.AddInt64(&p.vars.postsExempt, 1)
+        return nil, ""
+    }
 
     user, err := p.API.GetUser(post.UserId)
     if err != nil {
@@ -340,55 +588,64 @@ func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*mode
         return nil, ""
     }
 
-    // Check if user is in the exempted list
-    if p.isUserExempted(user.Username) {
+    decision, decideErr := p.decide(user, channel.Id)
+    if decideErr != nil {
+        if conf.FailMode == config.FailClosed {
+            p.API.LogError("Failed to evaluate DM policy, rejecting message", "user_id", user.Id, "fail_mode", conf.FailMode, "error", decideErr.Error())
+            message := p.withSuggestion(user.Id, conf.RejectionMessage)
+            p.notifyRejection(post, message)
+            return nil, message
+        }
+
+        p.API.LogError("Failed to evaluate DM policy, allowing message", "user_id", user.Id, "fail_mode", conf.FailMode, "error", decideErr.Error())
         return nil, ""
     }
 
-    isAdmin := false
-    teams, err := p.API.GetTeamsForUser(user.Id)
-    if err != nil {
-        p.API.LogError("Failed to get teams", "error", err.Error())
+    if decision.Exempt {
+        atomic.AddInt64(&p.vars.postsExempt, 1)
         return nil, ""
     }
 
-    for _, team := range teams {
-        member, err := p.API.GetTeamMember(team.Id, user.Id)
+    // Reject messages containing blocked keywords or matching blocked patterns
+    if rule := conf.BlockedContentRule(post.Message); rule != "" {
+        hash := sha256.Sum256([]byte(post.Message))
+        ruleHash := sha256.Sum256([]byte(rule))
+        p.API.LogInfo("Blocked direct message with restricted content",
+            "user_id", user.Id,
+            "channel_id", channel.Id,
+            "rule_sha256", hex.EncodeToString(ruleHash[:]),
+            "message_sha256", hex.EncodeToString(hash[:]),
+        )
+        message := p.withSuggestion(user.Id, conf.KeywordRejectionMessage)
+        p.notifyRejection(post, message)
+        return nil, message
+    }
+
+    // Blocked users may still reply in conversations started by others
+    if decision.Blocked && conf.AllowReplies {
+        reply, err := p.isReply(channel.Id, user.Id)
         if err != nil {
-            continue
-        }
-        if member.SchemeAdmin {
-            isAdmin = true
-            break
+            p.API.LogError("Failed to check for earlier messages", "channel_id", channel.Id, "error", err.Error())
+        } else if reply {
+            return nil, ""
         }
     }
 
-    // If user is admin and admins are exempt, allow the message
-    if isAdmin && conf.AdminsExempt {
-        return nil, ""
-    }
-
-    // In AdminOnly mode, only admins can send DMs
-    if conf.AdminOnly && !isAdmin {
-        p.API.SendEphemeralPost(post.UserId, &model.Post{
-            ChannelId: post.ChannelId,
-            Message:   conf.RejectionMessage,
-        })
-        return nil, conf.RejectionMessage
-    }
-
-    // If not in AdminOnly mode, check if the user's email domain is blocked
-    if !conf.AdminOnly && p.isEmailDomainBlocked(user.Email) {
-        p.API.SendEphemeralPost(post.UserId, &model.Post{
-            ChannelId: post.ChannelId,
-            Message:   conf.RejectionMessage,
-        })
-        return nil, conf.RejectionMessage
+    if decision.Blocked {
+        message := p.withSuggestion(user.Id, conf.RejectionMessage)
+        p.notifyRejection(post, message)
+        return nil, message
     }
 
     return nil, ""
 }
 
+// isSelfDM reports whether the channel is the direct message of the user
+// with themselves.
+func isSelfDM(channel *model.Channel, userID string) bool {
+    return channel.Type == model.ChannelTypeDirect && channel.Name == model.GetDMNameFromIds(userID, userID)
+}
+
 func main() {
     plugin.ClientMain(&Plugin{})
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+    "fmt"
+    "sync"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// commandAPI records which slash command triggers are registered.
+type commandAPI struct {
+    *testAPI
+
+    mutex      sync.Mutex
+    registered map[string]bool
+}
+
+func (a *commandAPI) RegisterCommand(command *model.Command) error {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+    a.registered[command.Trigger] = true
+    return nil
+}
+
+func (a *commandAPI) UnregisterCommand(teamID, trigger string) error {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+    delete(a.registered, trigger)
+    return nil
+}
+
+func TestConcurrentRegisterCommandLeavesOneTrigger(t *testing.T) {
+    api := &commandAPI{testAPI: newTestAPI(t), registered: make(map[string]bool)}
+    p := &Plugin{}
+    p.SetAPI(api)
+
+    var wg sync.WaitGroup
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            assert.NoError(t, p.registerCommand(fmt.Sprintf("dm-%d", i%4)))
+        }(i)
+    }
+    wg.Wait()
+
+    assert.Equal(t, map[string]bool{p.registeredTrigger: true}, api.registered)
+}
+
+func TestFailModeWhenTeamsLookupFails(t *testing.T) {
+    for _, tc := range []struct {
+        failMode string
+        rejected bool
+    }{
+        {config.FailOpen, false},
+        {config.FailClosed, true},
+    } {
+        t.Run(tc.failMode, func(t *testing.T) {
+            api := newTestAPI(t)
+            expectDirectChannel(api, "dm", "user1", "user2")
+            api.On("GetUser", "user1").Return(&model.User{Id: "user1", Username: "alice"}, nil)
+            api.On("HasPermissionTo", "user1", model.PermissionManageSystem).Return(false)
+            api.On("GetTeamsForUser", "user1").Return(nil, model.NewAppError("GetTeamsForUser", "app.team.get_all.app_error", nil, "", 500))
+            if tc.rejected {
+                api.On("SendEphemeralPost", "user1", mock.Anything).Return(&model.Post{})
+            }
+            p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true, FailMode: tc.failMode})
+
+            _, rejection := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user1", ChannelId: "dm", Message: "hi"})
+
+            if tc.rejected {
+                assert.Equal(t, config.GetConfig().RejectionMessage, rejection)
+            } else {
+                assert.Empty(t, rejection)
+            }
+        })
+    }
+}
+
+// channelPosts returns a post list of the posts, newest first.
+func channelPosts(posts ...*model.Post) *model.PostList {
+    list := model.NewPostList()
+    for _, post := range posts {
+        list.AddPost(post)
+        list.AddOrder(post.Id)
+    }
+    return list
+}
+
+func TestAllowRepliesToAdmins(t *testing.T) {
+    adminPost := &model.Post{Id: "p1", UserId: "admin", ChannelId: "dm", Message: "can you check this?"}
+    ownPost := &model.Post{Id: "p2", UserId: "user1", ChannelId: "dm", Message: "hello"}
+    joinPost := &model.Post{Id: "p3", UserId: "admin", ChannelId: "dm", Type: model.PostTypeJoinChannel}
+
+    for _, tc := range []struct {
+        name         string
+        allowReplies bool
+        posts        *model.PostList
+        allowed      bool
+    }{
+        {"reply to an admin", true, channelPosts(ownPost, adminPost), true},
+        {"new conversation", true, channelPosts(ownPost), false},
+        {"only system messages by the admin", true, channelPosts(joinPost), false},
+        {"replies not allowed", false, channelPosts(adminPost), false},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            api := newTestAPI(t)
+            expectDirectChannel(api, "dm", "user1", "admin")
+            expectRegularUser(api, "user1")
+            api.On("GetUser", "user1").Return(&model.User{Id: "user1", Username: "alice"}, nil)
+            api.On("GetUser", "admin").Return(&model.User{Id: "admin", Username: "boss"}, nil).Maybe()
+            api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true).Maybe()
+            api.On("GetPostsForChannel", "dm", 0, replyLookback).Return(tc.posts, nil).Maybe()
+            api.On("SendEphemeralPost", "user1", mock.Anything).Return(&model.Post{}).Maybe()
+            p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true, AllowReplies: tc.allowReplies})
+
+            _, rejection := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user1", ChannelId: "dm", Message: "done"})
+
+            if tc.allowed {
+                assert.Empty(t, rejection)
+            } else {
+                assert.Equal(t, config.GetConfig().RejectionMessage, rejection)
+            }
+        })
+    }
+}
+
+func TestExemptUsernameIsTrimmedAndCanonical(t *testing.T) {
+    api := newTestAPI(t)
+    expectDirectChannel(api, "dm", "user1", "user2")
+    api.On("GetUser", "user1").Return(&model.User{Id: "user1", Username: "alice"}, nil)
+    api.On("HasPermissionTo", "user1", model.PermissionManageSystem).Return(false).Maybe()
+    api.On("GetTeamsForUser", "user1").Return([]*model.Team{}, nil).Maybe()
+    var saved map[string]interface{}
+    api.On("SavePluginConfig", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+        saved = args.Get(0).(map[string]interface{})
+    })
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true})
+
+    response := p.exemptUserCommand("  @Alice \t")
+
+    assert.Equal(t, "User alice added to exempted list.", response.Text)
+    assert.Equal(t, "alice", saved["exemptedUsers"])
+    assert.True(t, p.isUserExempted(" ALICE "))
+
+    _, rejection := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user1", ChannelId: "dm", Message: "hi"})
+    assert.Empty(t, rejection)
+
+    response = p.exemptUserCommand("alice ")
+    assert.Equal(t, "User alice is already exempted.", response.Text)
+}
+
+func TestExemptBlankUsernameIsRejected(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true})
+
+    response := p.exemptUserCommand(" \t ")
+
+    assert.Equal(t, "Please provide a username to exempt.", response.Text)
+    assert.Empty(t, config.GetConfig().ExemptedUserList())
+}
+
+func TestHandEditedExemptListMatchesTrimmedNames(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true, ExemptedUsers: " Alice ,, @Bob "})
+
+    assert.Equal(t, []string{"alice", "bob"}, config.GetConfig().ExemptedUserList())
+    assert.True(t, p.isUserExempted("alice"))
+    assert.True(t, p.isUserExempted("bob"))
+    assert.False(t, p.isUserExempted(""))
+}
+
+func TestSelfDMIsAlwaysAllowed(t *testing.T) {
+    api := newTestAPI(t)
+    expectDirectChannel(api, "notes", "user1", "user1")
+    p := newTestPlugin(t, api, &config.Configuration{
+        Enabled:         true,
+        AdminOnly:       true,
+        BlockedDomains:  "example.com",
+        BlockedKeywords: "secret",
+        FailMode:        config.FailClosed,
+    })
+
+    // No user or permission lookups are expected: the channel alone decides
+    _, rejection := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user1", ChannelId: "notes", Message: "secret reminder"})
+
+    assert.Empty(t, rejection)
+}
+
+func TestIsSelfDM(t *testing.T) {
+    self := &model.Channel{Type: model.ChannelTypeDirect, Name: model.GetDMNameFromIds("user1", "user1")}
+    other := &model.Channel{Type: model.ChannelTypeDirect, Name: model.GetDMNameFromIds("user1", "user2")}
+    group := &model.Channel{Type: model.ChannelTypeGroup, Name: model.GetDMNameFromIds("user1", "user1")}
+
+    assert.True(t, isSelfDM(self, "user1"))
+    assert.False(t, isSelfDM(self, "user2"))
+    assert.False(t, isSelfDM(other, "user1"))
+    assert.False(t, isSelfDM(group, "user1"))
+}
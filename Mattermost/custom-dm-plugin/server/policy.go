@@ -0,0 +1,213 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+// Decision is the outcome of the DM policy for a user in a channel, before
+// message content is considered.
+type Decision struct {
+    Exempt  bool   // user bypasses all restrictions, including content checks
+    Blocked bool   // user may not send DMs
+    Reason  string // explanation of the deciding rule
+}
+
+// decide applies the DM policy rules in enforcement order. An empty
+// channelID evaluates named exempt lists as if outside any listed channel.
+func (p *Plugin) decide(user *model.User, channelID string) (*Decision, error) {
+    conf := config.GetConfig()
+
+    // Check if user is in the exempted list
+    if p.isUserExempted(user.Username) {
+        return &Decision{Exempt: true, Reason: fmt.Sprintf("listed in the %s exempt list", defaultExemptList)}, nil
+    }
+
+    // Check if user is in a named exempt list that applies to this channel
+    if list := p.exemptingList(user, channelID); list != "" {
+        return &Decision{Exempt: true, Reason: fmt.Sprintf("listed in exempt list %s", list)}, nil
+    }
+
+    // Check if a role or the sign-in method of the user is exempt
+    if attribute := conf.ExemptAttribute(user); attribute != "" {
+        return &Decision{Exempt: true, Reason: fmt.Sprintf("attribute %s is exempt", attribute)}, nil
+    }
+
+    // New users can DM anyone during their onboarding window
+    if conf.InNewUserGrace(user.CreateAt, time.Now()) {
+        return &Decision{Exempt: true, Reason: fmt.Sprintf("account is less than %d days old", conf.NewUserGraceDays)}, nil
+    }
+
+    isAdmin, err := p.isAdmin(user.Id)
+    if err != nil {
+        return nil, err
+    }
+
+    // If user is admin and admins are exempt, allow the message
+    if isAdmin && conf.AdminsExempt {
+        return &Decision{Exempt: true, Reason: "admins are exempt"}, nil
+    }
+
+    // In AdminOnly mode, only admins can send DMs
+    if conf.AdminOnly && !isAdmin {
+        return &Decision{Blocked: true, Reason: "admin only mode is enabled and the user is not an admin"}, nil
+    }
+
+    // If not in AdminOnly mode, check if the user's email domain is blocked
+    if !conf.AdminOnly {
+        if domain := p.blockedEmailDomain(user.Email); domain != "" {
+            return &Decision{Blocked: true, Reason: fmt.Sprintf("email domain %s is blocked", domain)}, nil
+        }
+    }
+
+    return &Decision{Reason: "no restriction matches the user"}, nil
+}
+
+// Recent posts searched for an earlier message by an unrestricted user
+const replyLookback = 200
+
+// isReply reports whether a user other than userID who may send DMs has
+// posted in the channel, so that userID is replying to a conversation they
+// did not start.
+func (p *Plugin) isReply(channelID, userID string) (bool, error) {
+    posts, appErr := p.API.GetPostsForChannel(channelID, 0, replyLookback)
+    if appErr != nil {
+        return false, appErr
+    }
+
+    checked := make(map[string]bool)
+    for _, postID := range posts.Order {
+        post := posts.Posts[postID]
+        if post == nil || post.Type != "" || post.UserId == userID || checked[post.UserId] {
+            continue
+        }
+        checked[post.UserId] = true
+
+        author, appErr := p.API.GetUser(post.UserId)
+        if appErr != nil {
+            continue
+        }
+
+        decision, err := p.decide(author, channelID)
+        if err != nil {
+            return false, err
+        }
+        if !decision.Blocked {
+            return true, nil
+        }
+    }
+
+    return false, nil
+}
+
+// isAdmin reports whether the user is a system admin or may manage any of
+// their teams. Permissions are used instead of roles so custom roles count.
+func (p *Plugin) isAdmin(userID string) (bool, error) {
+    if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return true, nil
+    }
+
+    teams, err := p.API.GetTeamsForUser(userID)
+    if err != nil {
+        return false, err
+    }
+
+    for _, team := range teams {
+        if p.API.HasPermissionToTeam(userID, team.Id, model.PermissionManageTeam) {
+            return true, nil
+        }
+    }
+
+    return false, nil
+}
+
+// policyCommand explains why a user is or isn't restricted.
+func (p *Plugin) policyCommand(username string) *model.CommandResponse {
+    username = strings.TrimPrefix(username, "@")
+    user, appErr := p.API.GetUserByUsername(username)
+    if appErr != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("User %s not found.", username),
+        }
+    }
+
+    conf := config.GetConfig()
+    decision, err := p.decide(user, "")
+    if err != nil {
+        return &model.CommandResponse{
+            ResponseType: model.CommandResponseTypeEphemeral,
+            Text:        fmt.Sprintf("Failed to evaluate policy: %v", err),
+        }
+    }
+    isAdmin, _ := p.isAdmin(user.Id)
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Effective DM policy for @%s:\n", user.Username))
+    text.WriteString(fmt.Sprintf("* Plugin enabled: %s\n", yesNo(conf.Enabled)))
+    text.WriteString(fmt.Sprintf("* Enforcement paused: %s\n", yesNo(p.pauseRemaining() > 0)))
+    text.WriteString(fmt.Sprintf("* Admin only mode: %s\n", yesNo(conf.AdminOnly)))
+    text.WriteString(fmt.Sprintf("* Admin: %s (admins exempt: %s)\n", yesNo(isAdmin), yesNo(conf.AdminsExempt)))
+    text.WriteString(fmt.Sprintf("* %s exempt list: %s\n", defaultExemptList, listedText(p.isUserExempted(user.Username))))
+
+    lists := p.listsContaining(user.Username)
+    if len(lists) == 0 {
+        text.WriteString("* Named exempt lists: none\n")
+    } else {
+        text.WriteString(fmt.Sprintf("* Named exempt lists: %s\n", strings.Join(lists, "; ")))
+    }
+
+    if attribute := conf.ExemptAttribute(user); attribute != "" {
+        text.WriteString(fmt.Sprintf("* Exempt attribute: %s\n", attribute))
+    }
+
+    if conf.NewUserGraceDays > 0 {
+        text.WriteString(fmt.Sprintf("* New user grace period (%d days): %s\n", conf.NewUserGraceDays, yesNo(conf.InNewUserGrace(user.CreateAt, time.Now()))))
+    }
+
+    if domain := p.blockedEmailDomain(user.Email); domain != "" {
+        text.WriteString(fmt.Sprintf("* Email domain: blocked by %s\n", domain))
+    } else {
+        text.WriteString("* Email domain: not blocked\n")
+    }
+
+    text.WriteString(fmt.Sprintf("* Replies to unrestricted users allowed: %s\n", yesNo(conf.AllowReplies)))
+    text.WriteString(fmt.Sprintf("* Content rules: %s\n", conf.ContentRulesSummary()))
+
+    switch {
+    case !conf.Enabled:
+        text.WriteString("\nResult: allowed - the plugin is disabled")
+    case p.pauseRemaining() > 0:
+        text.WriteString("\nResult: allowed - enforcement is paused")
+    case decision.Exempt:
+        text.WriteString(fmt.Sprintf("\nResult: exempt - %s", decision.Reason))
+    case decision.Blocked:
+        text.WriteString(fmt.Sprintf("\nResult: blocked - %s", decision.Reason))
+    default:
+        text.WriteString(fmt.Sprintf("\nResult: allowed unless a content rule matches - %s", decision.Reason))
+    }
+
+    return &model.CommandResponse{
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Text:        text.String(),
+    }
+}
+
+func yesNo(value bool) string {
+    if value {
+        return "yes"
+    }
+    return "no"
+}
+
+func listedText(listed bool) string {
+    if listed {
+        return "listed"
+    }
+    return "not listed"
+}
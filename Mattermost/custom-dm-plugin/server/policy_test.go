@@ -0,0 +1,106 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+func TestExemptAttributesIgnoreProfileProps(t *testing.T) {
+    api := newTestAPI(t)
+    expectRegularUser(api, "user1")
+    p := newTestPlugin(t, api, &config.Configuration{
+        Enabled:          true,
+        AdminOnly:        true,
+        ExemptAttributes: "role=support_agent,auth_service=saml",
+    })
+
+    // Props can be set by the user through PATCH /users/me/patch
+    user := &model.User{
+        Id:       "user1",
+        Username: "mallory",
+        Roles:    "system_user",
+        Props:    model.StringMap{"role": "support_agent", "auth_service": "saml"},
+    }
+    decision, err := p.decide(user, "")
+    require.NoError(t, err)
+    assert.False(t, decision.Exempt)
+    assert.True(t, decision.Blocked)
+}
+
+func TestExemptAttributesMatchRolesAndAuthService(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t), &config.Configuration{
+        Enabled:          true,
+        AdminOnly:        true,
+        ExemptAttributes: "role=Support_Agent, auth_service=saml",
+    })
+
+    for _, user := range []*model.User{
+        {Id: "user1", Roles: "system_user support_agent"},
+        {Id: "user2", Roles: "system_user", AuthService: model.UserAuthServiceSaml},
+    } {
+        decision, err := p.decide(user, "")
+        require.NoError(t, err)
+        assert.True(t, decision.Exempt, user.Id)
+    }
+}
+
+func TestExemptAttributesRejectProfileKeys(t *testing.T) {
+    for _, attributes := range []string{"department=support", "role=agent,position=lead", "nokey"} {
+        settings := &config.Configuration{ExemptAttributes: attributes}
+        assert.Error(t, settings.ProcessConfiguration(), attributes)
+    }
+}
+
+func TestIsAdminUsesPermissions(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("HasPermissionTo", "custom-admin", model.PermissionManageSystem).Return(true)
+    api.On("HasPermissionTo", "team-admin", model.PermissionManageSystem).Return(false)
+    api.On("GetTeamsForUser", "team-admin").Return([]*model.Team{{Id: "team1"}, {Id: "team2"}}, nil)
+    api.On("HasPermissionToTeam", "team-admin", "team1", model.PermissionManageTeam).Return(false)
+    api.On("HasPermissionToTeam", "team-admin", "team2", model.PermissionManageTeam).Return(true)
+    expectRegularUser(api, "lookalike")
+    api.On("HasPermissionTo", "no-teams", model.PermissionManageSystem).Return(false)
+    api.On("GetTeamsForUser", "no-teams").Return(nil, model.NewAppError("GetTeamsForUser", "app.team.get_all.app_error", nil, "", 500))
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true})
+
+    for userID, expected := range map[string]bool{"custom-admin": true, "team-admin": true, "lookalike": false} {
+        admin, err := p.isAdmin(userID)
+        require.NoError(t, err)
+        assert.Equal(t, expected, admin, userID)
+    }
+
+    _, err := p.isAdmin("no-teams")
+    assert.Error(t, err)
+}
+
+func TestAdminOnlyAllowsCustomRoleAdmins(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("HasPermissionTo", "custom-admin", model.PermissionManageSystem).Return(true)
+    expectRegularUser(api, "lookalike")
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true})
+
+    decision, err := p.decide(&model.User{Id: "custom-admin", Roles: "system_user ops_admin"}, "")
+    require.NoError(t, err)
+    assert.False(t, decision.Blocked)
+
+    // Role names are not trusted, only the permissions they grant
+    decision, err = p.decide(&model.User{Id: "lookalike", Roles: "system_user system_admin_assistant"}, "")
+    require.NoError(t, err)
+    assert.True(t, decision.Blocked)
+}
+
+func TestCommandsRequireAdminPermissions(t *testing.T) {
+    api := newTestAPI(t)
+    expectRegularUser(api, "lookalike")
+    p := newTestPlugin(t, api, &config.Configuration{Enabled: true, AdminOnly: true})
+
+    response, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "lookalike", Command: "/custom-dm exempt alice"})
+    require.Nil(t, appErr)
+    assert.Equal(t, "Only administrators can use these commands.", response.Text)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// commandRateWindow is the period over which CommandRateLimit is counted
+const commandRateWindow = time.Minute
+
+// commandLimiter counts slash command invocations per user in fixed windows.
+// The zero value is ready to use.
+type commandLimiter struct {
+    mu          sync.Mutex
+    windows     map[string]*commandWindow // map[userID]current window
+    lastCleanup time.Time
+}
+
+type commandWindow struct {
+    start time.Time
+    count int
+}
+
+// allow records an invocation by userID and reports whether it is within
+// limit invocations per window. When it is not, it also returns how long the
+// user must wait. A limit of 0 disables limiting.
+func (l *commandLimiter) allow(userID string, limit int, now time.Time) (bool, time.Duration) {
+    if limit <= 0 {
+        return true, 0
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if l.windows == nil {
+        l.windows = make(map[string]*commandWindow)
+    }
+
+    // Drop expired windows at most once per window so the map cannot grow
+    // with every user who ever ran a command
+    if now.Sub(l.lastCleanup) >= commandRateWindow {
+        for id, window := range l.windows {
+            if now.Sub(window.start) >= commandRateWindow {
+                delete(l.windows, id)
+            }
+        }
+        l.lastCleanup = now
+    }
+
+    window, ok := l.windows[userID]
+    if !ok || now.Sub(window.start) >= commandRateWindow {
+        l.windows[userID] = &commandWindow{start: now, count: 1}
+        return true, 0
+    }
+
+    if window.count >= limit {
+        return false, commandRateWindow - now.Sub(window.start)
+    }
+
+    window.count++
+    return true, 0
+}
+
+// cooldownSeconds rounds a wait up to whole seconds for display.
+func cooldownSeconds(wait time.Duration) int {
+    return int((wait + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+    "encoding/json"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing in-memory state across plugin restarts in KV store
+    runtimeStateKey = "runtime_state"
+
+    // Saved state older than this is discarded
+    runtimeStateExpiry = time.Hour
+)
+
+// runtimeState is the in-memory state saved on deactivation so a restart
+// neither resets rate limits nor repeats rejection notices.
+type runtimeState struct {
+    CommandWindows   map[string]savedWindow `json:"command_windows"`   // map[userID]window
+    RejectionNotices map[string]int64       `json:"rejection_notices"` // map[userID/channelID]milliseconds
+}
+
+type savedWindow struct {
+    Start int64 `json:"start"` // milliseconds since epoch
+    Count int   `json:"count"`
+}
+
+func (l *commandLimiter) snapshot() map[string]savedWindow {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    windows := make(map[string]savedWindow, len(l.windows))
+    for userID, window := range l.windows {
+        windows[userID] = savedWindow{Start: model.GetMillisForTime(window.start), Count: window.count}
+    }
+    return windows
+}
+
+// restore loads saved windows that have not expired yet.
+func (l *commandLimiter) restore(windows map[string]savedWindow, now time.Time) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.windows = make(map[string]*commandWindow, len(windows))
+    for userID, saved := range windows {
+        start := model.GetTimeForMillis(saved.Start)
+        if now.Sub(start) < commandRateWindow {
+            l.windows[userID] = &commandWindow{start: start, count: saved.Count}
+        }
+    }
+}
+
+func (n *rejectionNotices) snapshot() map[string]int64 {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    notified := make(map[string]int64, len(n.notified))
+    for key, at := range n.notified {
+        notified[key] = model.GetMillisForTime(at)
+    }
+    return notified
+}
+
+// restore loads saved notice times. Expired ones are dropped by the next
+// cleanup in allow.
+func (n *rejectionNotices) restore(notified map[string]int64) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    n.notified = make(map[string]time.Time, len(notified))
+    for key, millis := range notified {
+        n.notified[key] = model.GetTimeForMillis(millis)
+    }
+}
+
+// saveRuntimeState persists the in-memory state for the next activation.
+func (p *Plugin) saveRuntimeState() error {
+    data, err := json.Marshal(&runtimeState{
+        CommandWindows:   p.commandLimiter.snapshot(),
+        RejectionNotices: p.rejectionNotices.snapshot(),
+    })
+    if err != nil {
+        return err
+    }
+
+    if appErr := p.API.KVSetWithExpiry(runtimeStateKey, data, int64(runtimeStateExpiry/time.Second)); appErr != nil {
+        return appErr
+    }
+
+    return nil
+}
+
+// loadRuntimeState restores the state saved by the previous deactivation.
+func (p *Plugin) loadRuntimeState() error {
+    data, appErr := p.API.KVGet(runtimeStateKey)
+    if appErr != nil {
+        return appErr
+    }
+    if data == nil {
+        return nil
+    }
+
+    var state runtimeState
+    if err := json.Unmarshal(data, &state); err != nil {
+        return err
+    }
+
+    p.commandLimiter.restore(state.CommandWindows, time.Now())
+    p.rejectionNotices.restore(state.RejectionNotices)
+
+    return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "runtime"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-dm/server/config"
+)
+
+func TestRuntimeStateSurvivesRestart(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api, &config.Configuration{AdminOnly: true})
+
+    now := time.Now()
+    allowed, _ := p.commandLimiter.allow("user1", 1, now)
+    require.True(t, allowed)
+    require.True(t, p.rejectionNotices.allow("user1", "channel1", time.Hour, now))
+
+    require.NoError(t, p.OnDeactivate())
+
+    restarted := newTestPlugin(t, api, &config.Configuration{AdminOnly: true})
+    require.NoError(t, restarted.loadRuntimeState())
+
+    allowed, wait := restarted.commandLimiter.allow("user1", 1, now)
+    assert.False(t, allowed, "the rate limit window should survive the restart")
+    assert.Positive(t, wait)
+    assert.False(t, restarted.rejectionNotices.allow("user1", "channel1", time.Hour, now), "the notice should not be repeated")
+    assert.True(t, restarted.rejectionNotices.allow("user2", "channel1", time.Hour, now))
+}
+
+func TestExpiredRuntimeStateIsDropped(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api, &config.Configuration{AdminOnly: true})
+
+    past := time.Now().Add(-2 * commandRateWindow)
+    allowed, _ := p.commandLimiter.allow("user1", 1, past)
+    require.True(t, allowed)
+    require.NoError(t, p.saveRuntimeState())
+
+    restarted := newTestPlugin(t, api, &config.Configuration{AdminOnly: true})
+    require.NoError(t, restarted.loadRuntimeState())
+
+    allowed, _ = restarted.commandLimiter.allow("user1", 1, time.Now())
+    assert.True(t, allowed)
+}
+
+func TestActivateDeactivateCyclesDoNotLeakGoroutines(t *testing.T) {
+    api := &commandAPI{testAPI: newTestAPI(t), registered: make(map[string]bool)}
+    api.On("LoadPluginConfiguration", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+        args.Get(0).(*config.Configuration).AdminOnly = true
+    })
+    t.Cleanup(func() {
+        config.SetConfig(nil)
+        config.Mattermost = nil
+    })
+
+    cycle := func() {
+        p := &Plugin{exemptLists: make(map[string]*ExemptList)}
+        p.SetAPI(api)
+        require.NoError(t, p.OnActivate())
+        require.NoError(t, p.OnDeactivate())
+    }
+
+    // Warm up so lazily started runtime goroutines are not counted
+    cycle()
+    runtime.GC()
+    before := runtime.NumGoroutine()
+
+    for i := 0; i < 50; i++ {
+        cycle()
+    }
+
+    // Give exiting goroutines a moment to finish
+    after := runtime.NumGoroutine()
+    for deadline := time.Now().Add(time.Second); after > before && time.Now().Before(deadline); after = runtime.NumGoroutine() {
+        time.Sleep(10 * time.Millisecond)
+    }
+    assert.LessOrEqual(t, after, before)
+    assert.Contains(t, api.registered, config.DefaultCommandTrigger)
+}
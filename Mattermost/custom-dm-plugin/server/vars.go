@@ -0,0 +1,120 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sync/atomic"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Lock waits longer than this are counted as contended
+const contendedLockWait = 100 * time.Microsecond
+
+// debugVars counts internal events since the plugin was activated. All
+// fields are updated with atomics so collecting them never takes a lock. The
+// zero value is ready to use.
+type debugVars struct {
+    postsChecked         int64
+    postsExempt          int64
+    postsRejected        int64
+    commandsRun          int64
+    commandsLimited      int64
+    noticesSent          int64
+    noticesSuppressed    int64
+    exemptLockAcquired   int64
+    exemptLockContended  int64
+    exemptLockWaitMicros int64
+}
+
+// CacheVars reports how often a cache answered without doing the work.
+type CacheVars struct {
+    Hits    int64   `json:"hits"`
+    Misses  int64   `json:"misses"`
+    HitRate float64 `json:"hit_rate"` // hits / (hits + misses), 0 when unused
+}
+
+// LockVars reports how long callers waited for a lock.
+type LockVars struct {
+    Acquired   int64 `json:"acquired"`
+    Contended  int64 `json:"contended"`   // acquisitions that waited longer than 100µs
+    WaitMicros int64 `json:"wait_micros"` // total time spent waiting
+}
+
+// DebugVars is the response of the debug vars endpoint.
+type DebugVars struct {
+    Counters map[string]int64     `json:"counters"`
+    Caches   map[string]CacheVars `json:"caches"`
+    Locks    map[string]LockVars  `json:"locks"`
+}
+
+func newCacheVars(hits, misses int64) CacheVars {
+    vars := CacheVars{Hits: hits, Misses: misses}
+    if hits+misses > 0 {
+        vars.HitRate = float64(hits) / float64(hits+misses)
+    }
+    return vars
+}
+
+// rlockExempt read-locks exemptMutex and records how long it waited.
+func (p *Plugin) rlockExempt() {
+    start := time.Now()
+    p.exemptMutex.RLock()
+    recordLockWait(time.Since(start), &p.vars.exemptLockAcquired, &p.vars.exemptLockContended, &p.vars.exemptLockWaitMicros)
+}
+
+func recordLockWait(wait time.Duration, acquired, contended, waitMicros *int64) {
+    atomic.AddInt64(acquired, 1)
+    if wait > contendedLockWait {
+        atomic.AddInt64(contended, 1)
+    }
+    atomic.AddInt64(waitMicros, wait.Microseconds())
+}
+
+// snapshot reads the counters without locking.
+func (v *debugVars) snapshot() *DebugVars {
+    load := func(counter *int64) int64 {
+        return atomic.LoadInt64(counter)
+    }
+
+    return &DebugVars{
+        Counters: map[string]int64{
+            "posts_checked":    load(&v.postsChecked),
+            "posts_exempt":     load(&v.postsExempt),
+            "posts_rejected":   load(&v.postsRejected),
+            "commands_run":     load(&v.commandsRun),
+            "commands_limited": load(&v.commandsLimited),
+        },
+        Caches: map[string]CacheVars{
+            // A hit is a rejection notice suppressed because the author
+            // was already told
+            "rejection_notices": newCacheVars(load(&v.noticesSuppressed), load(&v.noticesSent)),
+        },
+        Locks: map[string]LockVars{
+            "exempt_lists": {
+                Acquired:   load(&v.exemptLockAcquired),
+                Contended:  load(&v.exemptLockContended),
+                WaitMicros: load(&v.exemptLockWaitMicros),
+            },
+        },
+    }
+}
+
+// handleDebugVars returns the internal counters. Only system admins can read
+// them.
+func (p *Plugin) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can read plugin vars", http.StatusForbidden)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(p.vars.snapshot())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Users loaded per API call when adding a channel's members to a group
+const addChannelPageSize = 200
+
+// channelMemberIDs returns the IDs of the active, non-bot members of a
+// channel, loading them page by page.
+func (p *Plugin) channelMemberIDs(channelID string) ([]string, error) {
+    userIDs := []string{}
+    for page := 0; ; page++ {
+        users, appErr := p.API.GetUsersInChannel(channelID, model.ChannelSortByUsername, page, addChannelPageSize)
+        if appErr != nil {
+            return nil, appErr
+        }
+        for _, user := range users {
+            if !user.IsBot && user.DeleteAt == 0 {
+                userIDs = append(userIDs, user.Id)
+            }
+        }
+        if len(users) < addChannelPageSize {
+            break
+        }
+    }
+    return userIDs, nil
+}
+
+// addGroupMembers appends the user IDs that are not yet members of a group
+// and persists the change once. It returns how many were added and the
+// memberships removed from mutually exclusive groups.
+func (p *Plugin) addGroupMembers(groupName string, userIDs []string, actorID string) (int, []ExclusiveRemoval, error) {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return 0, nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    added := []string{}
+    for _, userID := range userIDs {
+        if !contains(members, userID) && !contains(added, userID) {
+            added = append(added, userID)
+        }
+    }
+    if len(added) == 0 {
+        p.groupMutex.Unlock()
+        return 0, nil, nil
+    }
+
+    p.groups[groupName] = append(members, added...)
+    p.touchGroup(groupName)
+    p.events.append(memberEvents(EventMemberAdded, groupName, actorID, added))
+    p.recordJoins(groupName, added)
+    removals := p.enforceExclusive(groupName, added, actorID)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return len(added), p.resolveRemovals(removals), p.saveGroupState()
+}
+
+// addChannelCommand adds the members of a channel, the current one unless a
+// channel is given, to a group. The caller must be able to read the channel so
+// private channel rosters are not revealed.
+func (p *Plugin) addChannelCommand(args *model.CommandArgs, trigger string, params []string, quiet bool) *model.CommandResponse {
+    if len(params) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name: `/%s addchannel group_name [~channel]`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := params[0]
+
+    channel, appErr := p.API.GetChannel(args.ChannelId)
+    if len(params) > 1 {
+        channel, appErr = p.API.GetChannelByName(args.TeamId, strings.TrimPrefix(params[1], "~"), false)
+    }
+    if appErr != nil || !p.API.HasPermissionToChannel(args.UserId, channel.Id, model.PermissionReadChannel) {
+        channelName := args.ChannelId
+        if len(params) > 1 {
+            channelName = "~" + strings.TrimPrefix(params[1], "~")
+        }
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Channel %s not found", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    userIDs, err := p.channelMemberIDs(channel.Id)
+    if err != nil {
+        p.API.LogError("Failed to load channel members", "channel_id", channel.Id, "error", err.Error())
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Failed to load the members of ~%s", channel.Name),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    added, removals, err := p.addGroupMembers(groupName, userIDs, args.UserId)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    text := fmt.Sprintf("Added %d members of ~%s to group %s, %d were already members", added, channel.Name, groupName, len(userIDs)-added)
+    if len(removals) > 0 {
+        text += "\n" + exclusiveRemovalText(removals)
+    }
+    return &model.CommandResponse{
+        Text: successText(quiet, text),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
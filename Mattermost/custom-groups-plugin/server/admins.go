@@ -0,0 +1,80 @@
+package main
+
+import (
+    "net/http"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// Reply to users who may not manage groups while management is restricted
+const managementRestrictedText = "Only administrators can manage groups"
+
+// managementCommands are the subcommands that create or delete groups or
+// change their members, restricted by RestrictManagementToAdmins.
+var managementCommands = map[string]bool{
+    "create":      true,
+    "add":         true,
+    "addchannel":  true,
+    "remove":      true,
+    "delete":      true,
+    "restore":     true,
+    "trash":       true,
+    "from-post":   true,
+    "import":      true,
+    "merge":       true,
+    "dynamic":     true,
+    "rename-bulk": true,
+    "dedupe":      true,
+    "schedule":    true,
+}
+
+// isAdmin reports whether the user is a system admin or may manage any of
+// their teams. Permissions are used instead of roles so custom roles count.
+func (p *Plugin) isAdmin(userID string) (bool, error) {
+    if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return true, nil
+    }
+
+    teams, appErr := p.API.GetTeamsForUser(userID)
+    if appErr != nil {
+        return false, appErr
+    }
+
+    for _, team := range teams {
+        if p.API.HasPermissionToTeam(userID, team.Id, model.PermissionManageTeam) {
+            return true, nil
+        }
+    }
+
+    return false, nil
+}
+
+// canManageGroups reports whether the user may create, delete and change
+// groups. Everyone may unless RestrictManagementToAdmins is enabled.
+func (p *Plugin) canManageGroups(userID string) bool {
+    if !config.GetConfig().RestrictManagementToAdmins {
+        return true
+    }
+    if userID == "" {
+        return false
+    }
+
+    admin, err := p.isAdmin(userID)
+    if err != nil {
+        p.API.LogWarn("Failed to check whether the user is an admin", "user_id", userID, "error", err.Error())
+        return false
+    }
+    return admin
+}
+
+// requireManager rejects REST requests by users who may not manage groups.
+// It reports whether the request may continue.
+func (p *Plugin) requireManager(w http.ResponseWriter, r *http.Request) bool {
+    if !p.canManageGroups(r.Header.Get("Mattermost-User-Id")) {
+        http.Error(w, managementRestrictedText, http.StatusForbidden)
+        return false
+    }
+    return true
+}
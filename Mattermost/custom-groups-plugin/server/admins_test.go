@@ -0,0 +1,99 @@
+package main
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// expectAdminChecks sets up a system admin through a custom role, a team
+// admin through a custom team role, a user whose role name only looks like
+// an admin's, and a user whose teams cannot be loaded.
+func expectAdminChecks(api *testAPI) {
+    api.On("HasPermissionTo", "custom-admin", model.PermissionManageSystem).Return(true).Maybe()
+    api.On("HasPermissionTo", "team-admin", model.PermissionManageSystem).Return(false).Maybe()
+    api.On("GetTeamsForUser", "team-admin").Return([]*model.Team{{Id: "team1"}, {Id: "team2"}}, nil).Maybe()
+    api.On("HasPermissionToTeam", "team-admin", "team1", model.PermissionManageTeam).Return(false).Maybe()
+    api.On("HasPermissionToTeam", "team-admin", "team2", model.PermissionManageTeam).Return(true).Maybe()
+    api.On("HasPermissionTo", "lookalike", model.PermissionManageSystem).Return(false).Maybe()
+    api.On("GetTeamsForUser", "lookalike").Return([]*model.Team{{Id: "team1"}}, nil).Maybe()
+    api.On("HasPermissionToTeam", "lookalike", "team1", model.PermissionManageTeam).Return(false).Maybe()
+    api.On("HasPermissionTo", "no-teams", model.PermissionManageSystem).Return(false).Maybe()
+    api.On("GetTeamsForUser", "no-teams").Return(nil, model.NewAppError("GetTeamsForUser", "app.team.get_all.app_error", nil, "", http.StatusInternalServerError)).Maybe()
+}
+
+func restrictManagement(t *testing.T) {
+    settings := config.DefaultConfiguration()
+    settings.RestrictManagementToAdmins = true
+    require.NoError(t, settings.ProcessConfiguration())
+    config.SetConfig(settings)
+}
+
+func TestIsAdminUsesPermissions(t *testing.T) {
+    api := newTestAPI(t)
+    expectAdminChecks(api)
+    p := newTestPlugin(t, api)
+
+    for userID, expected := range map[string]bool{"custom-admin": true, "team-admin": true, "lookalike": false} {
+        admin, err := p.isAdmin(userID)
+        require.NoError(t, err)
+        assert.Equal(t, expected, admin, userID)
+    }
+
+    _, err := p.isAdmin("no-teams")
+    assert.Error(t, err)
+}
+
+func TestCanManageGroups(t *testing.T) {
+    api := newTestAPI(t)
+    expectAdminChecks(api)
+    p := newTestPlugin(t, api)
+
+    assert.True(t, p.canManageGroups("lookalike"), "management is open by default")
+
+    restrictManagement(t)
+    for userID, expected := range map[string]bool{"custom-admin": true, "team-admin": true, "lookalike": false, "no-teams": false, "": false} {
+        assert.Equal(t, expected, p.canManageGroups(userID), userID)
+    }
+}
+
+func TestRestrictedManagementRejectsNonAdmins(t *testing.T) {
+    api := newTestAPI(t)
+    expectAdminChecks(api)
+    expectUsers(api,
+        &model.User{Id: "custom-admin", Roles: "system_user ops_admin"},
+        &model.User{Id: "lookalike", Roles: "system_user system_admin_assistant"},
+    )
+    p := newTestPlugin(t, api)
+    restrictManagement(t)
+
+    response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "lookalike", Command: "/group create ops"})
+    assert.Equal(t, managementRestrictedText, response.Text)
+    w := serveRequest(p, http.MethodPost, "/api/v4/groups", "lookalike", `{"name": "ops"}`)
+    assert.Equal(t, http.StatusForbidden, w.Code)
+    assert.NotContains(t, p.groups, "ops")
+
+    for _, command := range []string{
+        "/group dynamic oncall ~town-square all",
+        "/group dynamic oncall none",
+        "/group rename-bulk team- squad-",
+        "/group dedupe ops",
+        "/group schedule ops @alice mon-fri",
+        "/group trash restore ops",
+    } {
+        response, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "lookalike", TeamId: "team", Command: command})
+        assert.Equal(t, managementRestrictedText, response.Text, command)
+    }
+    assert.Empty(t, p.dynamicGroups)
+
+    response, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "custom-admin", Command: "/group create ops"})
+    assert.Contains(t, response.Text, "Created group ops")
+    w = serveRequest(p, http.MethodPost, "/api/v4/groups", "custom-admin", `{"name": "qa"}`)
+    assert.Equal(t, http.StatusCreated, w.Code)
+}
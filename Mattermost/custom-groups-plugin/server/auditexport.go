@@ -0,0 +1,189 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Date format of the audit export range options
+const auditDateFormat = "2006-01-02"
+
+// AuditRecord is one membership event in an audit export, with user IDs
+// resolved to usernames where they still exist.
+type AuditRecord struct {
+    Timestamp string `json:"timestamp"` // RFC 3339, UTC
+    Action    string `json:"action"`    // EventMemberAdded or EventMemberRemoved
+    Group     string `json:"group"`
+    User      string `json:"user"`
+    Actor     string `json:"actor"` // empty if unknown
+}
+
+// between returns copies of the events logged within [from, to), both in
+// milliseconds since epoch; zero leaves that end open.
+func (l *eventLog) between(from, to int64) []GroupEvent {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    events := []GroupEvent{}
+    for _, event := range l.events {
+        if (from != 0 && event.Timestamp < from) || (to != 0 && event.Timestamp >= to) {
+            continue
+        }
+        events = append(events, *event)
+    }
+    return events
+}
+
+// auditRecords resolves the events for export.
+func (p *Plugin) auditRecords(events []GroupEvent) []*AuditRecord {
+    usernames := map[string]string{}
+    username := func(userID string) string {
+        if userID == "" {
+            return ""
+        }
+        if name, ok := usernames[userID]; ok {
+            return name
+        }
+        name := userID
+        if user, appErr := p.API.GetUser(userID); appErr == nil {
+            name = user.Username
+        }
+        usernames[userID] = name
+        return name
+    }
+
+    records := make([]*AuditRecord, 0, len(events))
+    for _, event := range events {
+        records = append(records, &AuditRecord{
+            Timestamp: time.Unix(0, event.Timestamp*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+            Action:    event.Type,
+            Group:     event.Group,
+            User:      username(event.User),
+            Actor:     username(event.Actor),
+        })
+    }
+    return records
+}
+
+func encodeAuditCSV(records []*AuditRecord) ([]byte, error) {
+    var out strings.Builder
+    writer := csv.NewWriter(&out)
+
+    if err := writer.Write([]string{"timestamp", "action", "group", "user", "actor"}); err != nil {
+        return nil, err
+    }
+    for _, record := range records {
+        if err := writer.Write([]string{record.Timestamp, record.Action, record.Group, record.User, record.Actor}); err != nil {
+            return nil, err
+        }
+    }
+
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return nil, err
+    }
+
+    return []byte(out.String()), nil
+}
+
+// parseAuditDate parses a range option; an empty value leaves that end open.
+// With endOfDay the returned time is the start of the following day, so the
+// whole day is included.
+func parseAuditDate(value string, endOfDay bool) (int64, error) {
+    if value == "" {
+        return 0, nil
+    }
+    date, err := time.Parse(auditDateFormat, value)
+    if err != nil {
+        return 0, err
+    }
+    if endOfDay {
+        date = date.AddDate(0, 0, 1)
+    }
+    return model.GetMillisForTime(date), nil
+}
+
+// auditExportCommand sends the membership event log, optionally limited to a
+// date range, as a JSON or CSV file in a direct message from the bot. Only
+// system admins can use it.
+func (p *Plugin) auditExportCommand(userID, trigger string, args []string) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can export the audit log",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    args, asCSV := extractFlag(args, "--csv")
+    args, fromValue, _ := extractOption(args, "--from")
+    _, toValue, _ := extractOption(args, "--to")
+
+    from, fromErr := parseAuditDate(fromValue, false)
+    to, toErr := parseAuditDate(toValue, true)
+    if fromErr != nil || toErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please give dates as YYYY-MM-DD: `/%s audit-export [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--csv]`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    records := p.auditRecords(p.events.between(from, to))
+
+    var data []byte
+    var err error
+    extension := "json"
+    if asCSV {
+        extension = "csv"
+        data, err = encodeAuditCSV(records)
+    } else {
+        data, err = json.MarshalIndent(records, "", "  ")
+    }
+    if err != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Failed to encode the audit log: %v", err),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    channel, appErr := p.API.GetDirectChannel(userID, p.botID)
+    if appErr != nil {
+        p.API.LogError("Failed to open a direct channel for the audit export", "error", appErr.Error())
+        return &model.CommandResponse{
+            Text: "Failed to send the audit log",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    filename := fmt.Sprintf("group-audit-%s.%s", time.Now().UTC().Format("20060102-150405"), extension)
+    fileInfo, appErr := p.API.UploadFile(data, channel.Id, filename)
+    if appErr != nil {
+        p.API.LogError("Failed to upload the audit export", "error", appErr.Error())
+        return &model.CommandResponse{
+            Text: "Failed to send the audit log",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if _, appErr := p.API.CreatePost(&model.Post{
+        UserId:    p.botID,
+        ChannelId: channel.Id,
+        Message:   fmt.Sprintf("Group membership audit log: %d events", len(records)),
+        FileIds:   []string{fileInfo.Id},
+    }); appErr != nil {
+        p.API.LogError("Failed to post the audit export", "error", appErr.Error())
+        return &model.CommandResponse{
+            Text: "Failed to send the audit log",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("Exported %d events. @%s sent you the file in a direct message.", len(records), botUsername),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// backupVersion is the format version written by the backup endpoint.
+// Version 1 backups hold only the groups and their metadata; restoring one
+// leaves the rest of the state as it is.
+const backupVersion = 2
+
+// Backup is the plugin state returned by the backup endpoint and accepted by
+// the restore endpoint. The membership event log and the idempotency records
+// are not part of it.
+type Backup struct {
+    Version       int                       `json:"version"`
+    Groups        map[string][]string       `json:"groups"`         // map[groupName][]userIDs
+    Metadata      map[string]*GroupMetadata `json:"metadata"`       // map[groupName]metadata
+    DynamicGroups map[string]*DynamicGroup  `json:"dynamic_groups"` // map[groupName]dynamic group; since version 2
+    ExclusiveSets map[string][]string       `json:"exclusive_sets"` // map[setName][]groupNames; since version 2
+    Trash         map[string]*DeletedGroup  `json:"trash"`          // map[groupName]deleted group; since version 2
+    Snoozes       map[string]int64          `json:"snoozes"`        // map[channelID]snooze expiry in milliseconds; since version 2
+}
+
+// RestoreResult summarizes a restore.
+type RestoreResult struct {
+    DryRun  bool `json:"dry_run"`
+    Groups  int  `json:"groups"`
+    Members int  `json:"members"`
+}
+
+// handleBackup returns or restores the whole plugin state. Only system
+// admins can use it.
+func (p *Plugin) handleBackup(w http.ResponseWriter, r *http.Request) {
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can back up or restore groups", http.StatusForbidden)
+        return
+    }
+
+    switch {
+    case r.Method == http.MethodGet && r.URL.Path == "/api/v4/groups/backup":
+        p.handleGetBackup(w, r)
+    case r.Method == http.MethodPost && r.URL.Path == "/api/v4/groups/restore":
+        p.handleRestore(w, r)
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+func (p *Plugin) handleGetBackup(w http.ResponseWriter, r *http.Request) {
+    p.groupMutex.RLock()
+    backup := &Backup{
+        Version:       backupVersion,
+        Groups:        p.groups,
+        Metadata:      p.groupMetadata,
+        DynamicGroups: p.dynamicGroups,
+        ExclusiveSets: p.exclusiveSets,
+        Trash:         p.groupTrash,
+        Snoozes:       p.channelSnoozes,
+    }
+    data, err := json.Marshal(backup)
+    p.groupMutex.RUnlock()
+
+    if err != nil {
+        p.writeError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Content-Disposition", `attachment; filename="custom-groups-backup.json"`)
+    w.Write(data)
+}
+
+// handleRestore replaces the plugin state with a backup. With dry_run=true
+// the backup is only validated.
+func (p *Plugin) handleRestore(w http.ResponseWriter, r *http.Request) {
+    dryRun := false
+    if value := r.URL.Query().Get("dry_run"); value != "" {
+        parsed, err := strconv.ParseBool(value)
+        if err != nil {
+            http.Error(w, "Invalid dry_run value", http.StatusBadRequest)
+            return
+        }
+        dryRun = parsed
+    }
+
+    var backup Backup
+    if err := json.NewDecoder(r.Body).Decode(&backup); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    if err := backup.validate(); err != nil {
+        http.Error(w, "Invalid backup: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    result := &RestoreResult{DryRun: dryRun, Groups: len(backup.Groups)}
+    for _, members := range backup.Groups {
+        result.Members += len(members)
+    }
+
+    if !dryRun {
+        if err := p.restoreBackup(&backup, r.Header.Get("Mattermost-User-Id")); err != nil {
+            p.writeError(w, err)
+            return
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(result)
+}
+
+// validate checks that a backup can be restored as is, and stores its group
+// names in canonical form as createGroup would. Reserved names, names that
+// only differ in case and subgroups that form a cycle are rejected.
+func (b *Backup) validate() error {
+    if b.Version < 1 || b.Version > backupVersion {
+        return fmt.Errorf("unsupported version %d", b.Version)
+    }
+
+    if b.Groups == nil {
+        return fmt.Errorf("groups are missing")
+    }
+
+    configuration := config.GetConfig()
+    groups := make(map[string][]string, len(b.Groups))
+    original := make(map[string]string, len(b.Groups))
+    for name, members := range b.Groups {
+        groupName := canonicalGroupName(name)
+        if groupName == "" {
+            return fmt.Errorf("invalid group name %q", name)
+        }
+        if configuration.IsReservedGroupName(groupName) {
+            return fmt.Errorf("reserved group name %s", groupName)
+        }
+        if other, ok := original[groupName]; ok {
+            return fmt.Errorf("group names %q and %q are the same group", other, name)
+        }
+        original[groupName] = name
+
+        if err := validateBackupMembers(groupName, members); err != nil {
+            return err
+        }
+        groups[groupName] = members
+    }
+
+    metadataByGroup := make(map[string]*GroupMetadata, len(b.Metadata))
+    for name, metadata := range b.Metadata {
+        groupName := canonicalGroupName(name)
+        if _, ok := groups[groupName]; !ok {
+            return fmt.Errorf("metadata for unknown group %s", name)
+        }
+        if _, ok := metadataByGroup[groupName]; ok {
+            return fmt.Errorf("duplicate metadata for group %s", groupName)
+        }
+        if metadata == nil {
+            return fmt.Errorf("empty metadata for group %s", groupName)
+        }
+        if metadata.Color != "" && !hexColorPattern.MatchString(metadata.Color) {
+            return fmt.Errorf("invalid color %q for group %s", metadata.Color, groupName)
+        }
+        if metadata.Template != "" {
+            if _, err := parseNotificationTemplate(metadata.Template); err != nil {
+                return fmt.Errorf("invalid template for group %s: %v", groupName, err)
+            }
+        }
+
+        var subgroups []string
+        for _, ref := range metadata.Subgroups {
+            ref = canonicalGroupName(ref)
+            if _, ok := groups[ref]; !ok {
+                return fmt.Errorf("group %s includes unknown group %s", groupName, ref)
+            }
+            if !contains(subgroups, ref) {
+                subgroups = append(subgroups, ref)
+            }
+        }
+        sort.Strings(subgroups)
+        metadata.Subgroups = subgroups
+        metadataByGroup[groupName] = metadata
+    }
+
+    subgroups := func(groupName string) []string {
+        if metadata, ok := metadataByGroup[groupName]; ok {
+            return metadata.Subgroups
+        }
+        return nil
+    }
+    for groupName := range groups {
+        for _, ref := range subgroups(groupName) {
+            if reachesGroup(subgroups, ref, groupName) {
+                return fmt.Errorf("%w: %s includes %s", ErrNestingCycle, ref, groupName)
+            }
+        }
+    }
+
+    b.Groups = groups
+    b.Metadata = metadataByGroup
+    if b.Version == 1 {
+        return nil
+    }
+
+    dynamicGroups := make(map[string]*DynamicGroup, len(b.DynamicGroups))
+    for name, group := range b.DynamicGroups {
+        groupName := canonicalGroupName(name)
+        if groupName == "" {
+            return fmt.Errorf("invalid dynamic group name %q", name)
+        }
+        if configuration.IsReservedGroupName(groupName) {
+            return fmt.Errorf("reserved group name %s", groupName)
+        }
+        if other, ok := original[groupName]; ok {
+            return fmt.Errorf("group names %q and %q are the same group", other, name)
+        }
+        original[groupName] = name
+        if group == nil || !model.IsValidId(group.ChannelID) {
+            return fmt.Errorf("invalid channel for dynamic group %s", groupName)
+        }
+        if group.Role != DynamicRoleAdmins && group.Role != DynamicRoleGuests && group.Role != DynamicRoleAll {
+            return fmt.Errorf("invalid role %q for dynamic group %s", group.Role, groupName)
+        }
+        dynamicGroups[groupName] = group
+    }
+
+    exclusiveSets := make(map[string][]string, len(b.ExclusiveSets))
+    for setName, names := range b.ExclusiveSets {
+        if strings.TrimSpace(setName) == "" {
+            return fmt.Errorf("invalid exclusive set name %q", setName)
+        }
+        groupNames := []string{}
+        for _, name := range names {
+            groupName := canonicalGroupName(name)
+            if _, ok := groups[groupName]; !ok {
+                return fmt.Errorf("exclusive set %s includes unknown group %s", setName, name)
+            }
+            if !contains(groupNames, groupName) {
+                groupNames = append(groupNames, groupName)
+            }
+        }
+        if len(groupNames) < 2 {
+            return fmt.Errorf("exclusive set %s has fewer than two groups", setName)
+        }
+        exclusiveSets[setName] = groupNames
+    }
+
+    trash := make(map[string]*DeletedGroup, len(b.Trash))
+    for name, deleted := range b.Trash {
+        groupName := canonicalGroupName(name)
+        if groupName == "" {
+            return fmt.Errorf("invalid deleted group name %q", name)
+        }
+        if _, ok := trash[groupName]; ok {
+            return fmt.Errorf("duplicate deleted group %s", groupName)
+        }
+        if deleted == nil {
+            return fmt.Errorf("empty deleted group %s", groupName)
+        }
+        if err := validateBackupMembers(groupName, deleted.Members); err != nil {
+            return err
+        }
+        if deleted.Members == nil {
+            deleted.Members = []string{}
+        }
+        trash[groupName] = deleted
+    }
+
+    snoozes := make(map[string]int64, len(b.Snoozes))
+    for channelID, expiresAt := range b.Snoozes {
+        if !model.IsValidId(channelID) || expiresAt <= 0 {
+            return fmt.Errorf("invalid snooze of channel %q", channelID)
+        }
+        snoozes[channelID] = expiresAt
+    }
+
+    b.DynamicGroups = dynamicGroups
+    b.ExclusiveSets = exclusiveSets
+    b.Trash = trash
+    b.Snoozes = snoozes
+    return nil
+}
+
+// validateBackupMembers checks the member IDs of a group in a backup.
+func validateBackupMembers(groupName string, members []string) error {
+    seen := make(map[string]bool, len(members))
+    for _, userID := range members {
+        if !model.IsValidId(userID) {
+            return fmt.Errorf("invalid user ID %q in group %s", userID, groupName)
+        }
+        if seen[userID] {
+            return fmt.Errorf("duplicate user ID %s in group %s", userID, groupName)
+        }
+        seen[userID] = true
+    }
+    return nil
+}
+
+// restoreBackup replaces the plugin state with a validated backup, logging
+// the membership differences as events. A version 1 backup only replaces the
+// groups and metadata. actorID is the restoring user's ID.
+func (p *Plugin) restoreBackup(backup *Backup, actorID string) error {
+    p.groupMutex.Lock()
+    for groupName, members := range p.groups {
+        restored := backup.Groups[groupName]
+        removed := []string{}
+        for _, userID := range members {
+            if !contains(restored, userID) {
+                removed = append(removed, userID)
+            }
+        }
+        p.events.append(memberEvents(EventMemberRemoved, groupName, actorID, removed))
+    }
+    for groupName, restored := range backup.Groups {
+        members := p.groups[groupName]
+        added := []string{}
+        for _, userID := range restored {
+            if !contains(members, userID) {
+                added = append(added, userID)
+            }
+        }
+        p.events.append(memberEvents(EventMemberAdded, groupName, actorID, added))
+    }
+
+    p.groups = backup.Groups
+    for groupName, members := range p.groups {
+        if members == nil {
+            members = []string{}
+        }
+        p.groups[groupName], _ = dedupeMembers(members)
+    }
+    p.groupMetadata = backup.Metadata
+    if p.groupMetadata == nil {
+        p.groupMetadata = make(map[string]*GroupMetadata)
+    }
+    full := backup.Version >= 2
+    if full {
+        p.dynamicGroups = backup.DynamicGroups
+        p.exclusiveSets = backup.ExclusiveSets
+        p.groupTrash = backup.Trash
+        p.channelSnoozes = backup.Snoozes
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    if err := p.saveGroupState(); err != nil {
+        return err
+    }
+    if !full {
+        return nil
+    }
+    for _, save := range []func() error{p.saveDynamicGroups, p.saveExclusiveSets, p.saveGroupTrash, p.saveChannelSnoozes} {
+        if err := save(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
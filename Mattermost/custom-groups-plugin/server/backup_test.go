@@ -0,0 +1,124 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestBackupValidateCanonicalizesNames(t *testing.T) {
+    newTestPlugin(t, newTestAPI(t))
+    alice, bob := model.NewId(), model.NewId()
+    backup := &Backup{
+        Version:  backupVersion,
+        Groups:   map[string][]string{" Dev ": {alice}, "OPS": {bob}},
+        Metadata: map[string]*GroupMetadata{"Dev": {Color: "#ff0000", Subgroups: []string{"Ops", "ops"}}},
+    }
+
+    require.NoError(t, backup.validate())
+    assert.Equal(t, map[string][]string{"dev": {alice}, "ops": {bob}}, backup.Groups)
+    require.Contains(t, backup.Metadata, "dev")
+    assert.Equal(t, []string{"ops"}, backup.Metadata["dev"].Subgroups)
+}
+
+func TestBackupValidateRejectsInvalidGroups(t *testing.T) {
+    newTestPlugin(t, newTestAPI(t))
+    userID := model.NewId()
+
+    for name, backup := range map[string]*Backup{
+        "collision":        {Groups: map[string][]string{"dev": {}, "Dev": {userID}}},
+        "reserved":         {Groups: map[string][]string{"Channel": {}}},
+        "unknown subgroup": {Groups: map[string][]string{"dev": {}}, Metadata: map[string]*GroupMetadata{"dev": {Subgroups: []string{"ops"}}}},
+        "self include":     {Groups: map[string][]string{"dev": {}}, Metadata: map[string]*GroupMetadata{"dev": {Subgroups: []string{"DEV"}}}},
+        "cycle": {
+            Groups:   map[string][]string{"dev": {}, "ops": {}, "qa": {}},
+            Metadata: map[string]*GroupMetadata{"dev": {Subgroups: []string{"ops"}}, "ops": {Subgroups: []string{"qa"}}, "QA": {Subgroups: []string{"dev"}}},
+        },
+        "dynamic collides": {
+            Groups:        map[string][]string{"dev": {}},
+            DynamicGroups: map[string]*DynamicGroup{"Dev": {ChannelID: userID, Role: DynamicRoleAll}},
+        },
+        "dynamic role":      {Groups: map[string][]string{}, DynamicGroups: map[string]*DynamicGroup{"oncall": {ChannelID: userID, Role: "owners"}}},
+        "exclusive unknown": {Groups: map[string][]string{"dev": {}}, ExclusiveSets: map[string][]string{"shift": {"dev", "ops"}}},
+        "exclusive single":  {Groups: map[string][]string{"dev": {}}, ExclusiveSets: map[string][]string{"shift": {"dev", "DEV"}}},
+        "trash member":      {Groups: map[string][]string{}, Trash: map[string]*DeletedGroup{"qa": {Members: []string{"bob"}}}},
+        "snooze channel":    {Groups: map[string][]string{}, Snoozes: map[string]int64{"town-square": 1}},
+        "duplicate metadata": {
+            Groups:   map[string][]string{"dev": {}},
+            Metadata: map[string]*GroupMetadata{"dev": {Color: "#ff0000"}, "Dev": {Color: "#00ff00"}},
+        },
+    } {
+        backup.Version = backupVersion
+        assert.Error(t, backup.validate(), name)
+    }
+}
+
+func TestRestoreStoresCanonicalNames(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "admin", Username: "admin"})
+    api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+    p := newTestPlugin(t, api)
+    userID := model.NewId()
+
+    w := serveRequest(p, http.MethodPost, "/api/v4/groups/restore", "admin",
+        `{"version": 1, "groups": {"Dev": ["`+userID+`"]}, "metadata": {"DEV": {"description": "Developers"}}}`)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+    assert.Equal(t, map[string][]string{"dev": {userID}}, p.groups)
+    assert.Equal(t, "Developers", p.groupMetadata["dev"].Description)
+
+    w = serveRequest(p, http.MethodPost, "/api/v4/groups/restore", "admin", `{"version": 1, "groups": {"here": []}}`)
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    assert.Contains(t, p.groups, "dev")
+}
+
+func TestBackupRoundTripKeepsTheWholeState(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "admin", Username: "admin"})
+    api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+    p := newTestPlugin(t, api)
+
+    alice, channelID := model.NewId(), model.NewId()
+    for _, groupName := range []string{"dev", "ops", "qa"} {
+        require.NoError(t, p.createGroup(groupName, []string{alice}, "", "admin"))
+    }
+    require.NoError(t, p.deleteGroup("qa", "admin"))
+    require.NoError(t, p.setDynamicGroup("oncall", &DynamicGroup{ChannelID: channelID, Role: DynamicRoleAdmins, CreatedBy: "admin"}, "admin"))
+    _, err := p.setExclusiveSet("shift", []string{"dev", "ops"})
+    require.NoError(t, err)
+    require.NoError(t, p.setChannelSnooze(channelID, time.Hour))
+
+    w := serveRequest(p, http.MethodGet, "/api/v4/groups/backup", "admin", "")
+    require.Equal(t, http.StatusOK, w.Code)
+    var backup Backup
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &backup))
+    assert.Equal(t, backupVersion, backup.Version)
+
+    target := newTestAPI(t)
+    expectUsers(target, &model.User{Id: "admin", Username: "admin"})
+    target.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+    restored := newTestPlugin(t, target)
+    w = serveRequest(restored, http.MethodPost, "/api/v4/groups/restore", "admin", w.Body.String())
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+    reloaded := loadTestServer(t, target)
+    require.NoError(t, reloaded.loadDynamicGroups())
+    require.NoError(t, reloaded.loadExclusiveSets())
+    require.NoError(t, reloaded.loadChannelSnoozes())
+    assert.Equal(t, p.groups, reloaded.groups)
+    assert.Contains(t, reloaded.groupTrash, "qa")
+    assert.Equal(t, p.dynamicGroups, reloaded.dynamicGroups)
+    assert.Equal(t, map[string][]string{"shift": {"dev", "ops"}}, reloaded.exclusiveSets)
+    assert.Equal(t, p.channelSnoozes, reloaded.channelSnoozes)
+
+    // A version 1 backup only replaces the groups and metadata
+    w = serveRequest(restored, http.MethodPost, "/api/v4/groups/restore", "admin", `{"version": 1, "groups": {"dev": [], "ops": []}}`)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+    assert.Contains(t, restored.dynamicGroups, "oncall")
+    assert.Contains(t, restored.groupTrash, "qa")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Recent posts of a channel scanned for group mentions
+    blastHistoryPosts = 1000
+
+    // Posts loaded per API call when scanning channel history
+    blastHistoryPageSize = 200
+)
+
+// GroupBlast is the number of users a mention of a group in a channel would
+// notify.
+type GroupBlast struct {
+    Group    string `json:"group"`
+    Mentions int    `json:"mentions"` // times the group was mentioned in the scanned history
+    Members  int    `json:"members"`
+    Notified int    `json:"notified"` // members a mention would notify, see recipientFilter
+}
+
+// recentGroupMentions counts the group mentions in the last blastHistoryPosts
+// posts of a channel.
+func (p *Plugin) recentGroupMentions(channelID string) (map[string]int, error) {
+    counts := make(map[string]int)
+    for page := 0; page*blastHistoryPageSize < blastHistoryPosts; page++ {
+        posts, appErr := p.API.GetPostsForChannel(channelID, page, blastHistoryPageSize)
+        if appErr != nil {
+            return nil, appErr
+        }
+        for _, post := range posts.Posts {
+            for groupName := range mentionedGroups(post) {
+                counts[groupName]++
+            }
+        }
+        if len(posts.Order) < blastHistoryPageSize {
+            break
+        }
+    }
+    return counts, nil
+}
+
+// channelBlast reports how many members of the channel's recently mentioned
+// groups, or of every group when all is set, a mention in the channel would
+// notify. Dynamic groups are resolved with the permissions of userID.
+func (p *Plugin) channelBlast(channelID, userID string, all bool) ([]*GroupBlast, error) {
+    counts, err := p.recentGroupMentions(channelID)
+    if err != nil {
+        return nil, err
+    }
+
+    p.groupMutex.RLock()
+    now := time.Now()
+    members := make(map[string][]string)
+    for groupName := range p.groups {
+        if all || counts[groupName] > 0 {
+            members[groupName] = p.resolveMembers(groupName, now)
+        }
+    }
+    dynamicGroups := make(map[string]*DynamicGroup)
+    for groupName, group := range p.dynamicGroups {
+        if all || counts[groupName] > 0 {
+            dynamicGroups[groupName] = group
+        }
+    }
+    p.groupMutex.RUnlock()
+
+    for groupName, group := range dynamicGroups {
+        resolved, err := p.dynamicMembers(group, userID)
+        if err != nil {
+            continue
+        }
+        members[groupName] = resolved
+    }
+
+    // Everyone in the channel is a possible author, so none is excluded
+    filter := p.newRecipientFilter(&model.Post{ChannelId: channelID})
+
+    blasts := make([]*GroupBlast, 0, len(members))
+    for groupName, groupMembers := range members {
+        blasts = append(blasts, &GroupBlast{
+            Group:    groupName,
+            Mentions: counts[groupName],
+            Members:  len(groupMembers),
+            Notified: len(filter.filter(groupMembers)),
+        })
+    }
+
+    // Largest blast radius first
+    sort.Slice(blasts, func(i, j int) bool {
+        if blasts[i].Notified != blasts[j].Notified {
+            return blasts[i].Notified > blasts[j].Notified
+        }
+        return blasts[i].Group < blasts[j].Group
+    })
+
+    return blasts, nil
+}
+
+// blastCommand shows how many users the groups mentioned in a channel
+// notify. Only channel admins can use it.
+func (p *Plugin) blastCommand(args *model.CommandArgs, trigger string, params []string, asJSON bool) *model.CommandResponse {
+    params, all := extractFlag(params, "--all")
+    if len(params) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a channel: `/%s blast ~channel [--all]`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    channelName := strings.TrimPrefix(params[0], "~")
+    channel, appErr := p.API.GetChannelByName(args.TeamId, channelName, false)
+    if appErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Channel ~%s not found", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if !p.API.HasPermissionToChannel(args.UserId, channel.Id, model.PermissionManageChannelRoles) {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Only admins of ~%s can see its blast radius", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    blasts, err := p.channelBlast(channel.Id, args.UserId, all)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Failed to load the history of ~%s", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if asJSON {
+        return jsonResponse(blasts)
+    }
+
+    if len(blasts) == 0 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("No groups were mentioned in the last %d posts of ~%s. Add `--all` to check every group.", blastHistoryPosts, channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var text strings.Builder
+    if all {
+        text.WriteString(fmt.Sprintf("Users a mention in ~%s would notify, per group:\n", channelName))
+    } else {
+        text.WriteString(fmt.Sprintf("Groups mentioned in the last %d posts of ~%s and the users a mention would notify:\n", blastHistoryPosts, channelName))
+    }
+    for _, blast := range blasts {
+        text.WriteString(fmt.Sprintf("- **%s**: %d of %d members, mentioned %d times\n", blast.Group, blast.Notified, blast.Members, blast.Mentions))
+    }
+
+    return &model.CommandResponse{
+        Text: text.String(),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// BulkImportGroup is one group in a bulk import request.
+type BulkImportGroup struct {
+    Name        string   `json:"name"`
+    Members     []string `json:"members"`               // usernames or user IDs
+    Description string   `json:"description,omitempty"` // used when the group is created
+    Tags        []string `json:"tags,omitempty"`        // added to the tags the group has
+}
+
+// BulkImportResult is the response of the bulk import endpoint.
+type BulkImportResult struct {
+    DryRun bool                `json:"dry_run"`
+    Groups []*BulkGroupOutcome `json:"groups"`
+}
+
+// BulkGroupOutcome is the outcome of importing one group. Error is set when
+// nothing was imported into the group.
+type BulkGroupOutcome struct {
+    Group   string   `json:"group"`
+    Created bool     `json:"created"`           // the group did not exist before
+    Added   []string `json:"added"`             // usernames added to the group
+    Skipped []string `json:"skipped"`           // usernames already in the group
+    Errors  []string `json:"errors"`            // members that could not be found
+    Error   string   `json:"error,omitempty"`
+}
+
+// handleBulkImport creates or extends several groups at once. Only system
+// admins can use it. With dry_run=true nothing is changed.
+func (p *Plugin) handleBulkImport(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can import groups", http.StatusForbidden)
+        return
+    }
+
+    dryRun := false
+    if value := r.URL.Query().Get("dry_run"); value != "" {
+        parsed, err := strconv.ParseBool(value)
+        if err != nil {
+            http.Error(w, "Invalid dry_run value", http.StatusBadRequest)
+            return
+        }
+        dryRun = parsed
+    }
+
+    var groups []BulkImportGroup
+    if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    result := &BulkImportResult{DryRun: dryRun, Groups: []*BulkGroupOutcome{}}
+    seen := make(map[string]bool)
+    for _, group := range groups {
+        outcome := p.bulkImportGroup(group, seen, userID, dryRun)
+        result.Groups = append(result.Groups, outcome)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(result)
+}
+
+// bulkImportGroup imports one group of a bulk import. New groups are created
+// with the resolved members; existing groups get the members they lack.
+func (p *Plugin) bulkImportGroup(group BulkImportGroup, seen map[string]bool, actorID string, dryRun bool) *BulkGroupOutcome {
+    group.Name = canonicalGroupName(group.Name)
+    outcome := &BulkGroupOutcome{
+        Group:   group.Name,
+        Added:   []string{},
+        Skipped: []string{},
+        Errors:  []string{},
+    }
+
+    switch {
+    case group.Name == "":
+        outcome.Error = "group name is required"
+        return outcome
+    case seen[group.Name]:
+        outcome.Error = "group is listed more than once"
+        return outcome
+    case config.GetConfig().IsReservedGroupName(group.Name):
+        outcome.Error = groupError(ErrReservedName, group.Name).Error()
+        return outcome
+    }
+    seen[group.Name] = true
+
+    tags, err := normalizeTags(group.Tags)
+    if err != nil {
+        outcome.Error = err.Error()
+        return outcome
+    }
+
+    // Resolve IDs to usernames so both kinds of reference share the import path
+    usernames := []string{}
+    for _, member := range group.Members {
+        member = strings.TrimPrefix(strings.TrimSpace(member), "@")
+        if member == "" {
+            continue
+        }
+        if model.IsValidId(member) {
+            if user, appErr := p.API.GetUser(member); appErr == nil {
+                usernames = append(usernames, user.Username)
+                continue
+            }
+        }
+        usernames = append(usernames, member)
+    }
+
+    p.groupMutex.RLock()
+    _, exists := p.groups[group.Name]
+    p.groupMutex.RUnlock()
+
+    if !exists {
+        outcome.Created = true
+        userIDs := []string{}
+        for _, username := range usernames {
+            user, appErr := p.API.GetUserByUsername(username)
+            if appErr != nil {
+                outcome.Errors = append(outcome.Errors, username)
+                continue
+            }
+            if contains(userIDs, user.Id) {
+                continue
+            }
+            userIDs = append(userIDs, user.Id)
+            outcome.Added = append(outcome.Added, username)
+        }
+
+        if !dryRun {
+            if err := p.createGroup(group.Name, userIDs, strings.TrimSpace(group.Description), actorID); err != nil {
+                outcome.Created = false
+                outcome.Added = []string{}
+                outcome.Error = err.Error()
+            } else if err := p.addGroupTags(group.Name, tags); err != nil {
+                p.API.LogWarn("Failed to tag imported group", "group", group.Name, "error", err.Error())
+            }
+        }
+        return outcome
+    }
+
+    var result *ImportResult
+    if dryRun {
+        result, _, err = p.planImport(group.Name, usernames)
+    } else {
+        result, err = p.importGroupMembers(group.Name, usernames, actorID)
+    }
+    if err != nil {
+        outcome.Error = err.Error()
+        return outcome
+    }
+    if !dryRun {
+        if err := p.addGroupTags(group.Name, tags); err != nil {
+            p.API.LogWarn("Failed to tag imported group", "group", group.Name, "error", err.Error())
+        }
+    }
+
+    outcome.Added = result.Added
+    outcome.Skipped = result.Skipped
+    outcome.Errors = result.Errors
+    return outcome
+}
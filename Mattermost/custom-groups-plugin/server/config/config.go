@@ -0,0 +1,480 @@
+package config
+
+import (
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/pkg/errors"
+)
+
+// Configuration holds the plugin settings from the System Console.
+type Configuration struct {
+    MaxNotificationsPerPost    int    // 0 disables the cap
+    CommandTrigger             string // slash command trigger word without the leading slash
+    MembersPageSize            int    // members shown per page by the info command
+    SyncSecret                 string // shared secret required by the sync endpoint; empty disables it
+    MaxMessageLength           int    // cap in characters for messages with expanded group mentions
+    AutocompleteEnabled        bool   // suggest groups in @mention autocomplete
+    AutocompleteUserShare      int    // percent of autocomplete suggestions reserved for real users
+    CommandRateLimit           int    // slash commands allowed per user per minute; 0 disables the limit
+    ImportConfirmThreshold     int    // imports adding more members need confirmation; 0 disables it
+    ThreadNotificationWindow   int    // minutes during which a member is notified once per thread; 0 disables it
+    ReservedGroupNames         string // comma-separated names that cannot be used for groups
+    DefaultGroups              string // comma-separated groups new users are added to
+    MentionAlertThreshold      int    // mentions of one group within the window that trigger an alert; 0 disables alerts
+    MentionAlertWindow         int    // minutes over which mentions are counted for alerts
+    MentionAlertChannel        string // ID of the channel alerts are posted in
+    DynamicGroupChannelLimit   int    // dynamic groups of larger channels are not expanded; 0 disables the limit
+    RemoteServerURL            string // site URL of the server groups are pushed to
+    RemoteServerToken          string // system admin access token for the remote server
+    MentionPolicyURL           string // URL asked whether a group mention may notify its members; empty disables it
+    MentionPolicyTimeout       int    // seconds to wait for the mention policy URL
+    MentionPolicyFailClosed    bool   // deny notifications when the mention policy URL cannot be reached
+    RestrictManagementToAdmins bool   // only system and team admins may create, delete and change groups
+    UnknownGroupHints          bool   // hint authors about mentions that look like a mistyped group name
+    ReadOnlyToken              string // token that grants GET access to the read endpoints; empty disables it
+    SimilarGroupOverlap        int    // percent of shared members at which /group similar reports two groups
+    ReportChannel              string // ID of the channel membership reports are posted in; empty disables them
+    ReportIntervalHours        int    // hours between membership reports
+
+    reservedGroupNames map[string]bool
+    defaultGroups      []string
+}
+
+const (
+    // Default cap on individual notifications a single post can generate
+    defaultMaxNotificationsPerPost = 500
+
+    // Slash command trigger used when none is configured
+    defaultCommandTrigger = "group"
+
+    // Members shown per page by the info command when none is configured
+    defaultMembersPageSize = 20
+
+    // Longest message every server accepts, whatever its database schema
+    defaultMaxMessageLength = model.PostMessageMaxRunesV1
+
+    // Slash commands allowed per user per minute
+    defaultCommandRateLimit = 30
+
+    // Imports adding more members than this must be confirmed
+    defaultImportConfirmThreshold = 50
+
+    // Minutes during which a member is notified at most once per thread
+    defaultThreadNotificationWindow = 60
+
+    // Minutes over which group mentions are counted for alerts
+    defaultMentionAlertWindow = 10
+
+    // Channel size above which dynamic groups are not expanded
+    defaultDynamicGroupChannelLimit = 1000
+
+    // Seconds to wait for the mention policy URL
+    defaultMentionPolicyTimeout = 3
+
+    // Percent of shared members at which groups are reported as similar
+    defaultSimilarGroupOverlap = 80
+
+    // Hours between membership reports
+    defaultReportIntervalHours = 24
+
+    // Names reserved by default: Mattermost's special mentions and the
+    // subcommands of the slash command
+    defaultReservedGroupNames = "all,here,channel,everyone,create,add,addchannel,remove,list,info,color,pin,unpin,priority,email,tag,schedule,status,dynamic,push,from-post,template,leave-all,rename-bulk,delete,trash,restore,export,import,import-preview,doctor,blast,dedupe,snooze,audit-export,exclusive,similar,merge,help"
+)
+
+// DefaultConfiguration returns the settings used before the System Console
+// values are loaded.
+func DefaultConfiguration() *Configuration {
+    return &Configuration{
+        MaxNotificationsPerPost:  defaultMaxNotificationsPerPost,
+        CommandTrigger:           defaultCommandTrigger,
+        MembersPageSize:          defaultMembersPageSize,
+        MaxMessageLength:         defaultMaxMessageLength,
+        AutocompleteEnabled:      true,
+        CommandRateLimit:         defaultCommandRateLimit,
+        ImportConfirmThreshold:   defaultImportConfirmThreshold,
+        ThreadNotificationWindow: defaultThreadNotificationWindow,
+        ReservedGroupNames:       defaultReservedGroupNames,
+        MentionAlertWindow:       defaultMentionAlertWindow,
+        DynamicGroupChannelLimit: defaultDynamicGroupChannelLimit,
+        MentionPolicyTimeout:     defaultMentionPolicyTimeout,
+        SimilarGroupOverlap:      defaultSimilarGroupOverlap,
+        ReportIntervalHours:      defaultReportIntervalHours,
+    }
+}
+
+var (
+    configuration     *Configuration
+    configurationLock sync.RWMutex
+)
+
+// GetConfig returns the active configuration, or the defaults before the
+// settings are loaded. The returned value must not be modified.
+func GetConfig() *Configuration {
+    configurationLock.RLock()
+    defer configurationLock.RUnlock()
+
+    if configuration == nil {
+        return DefaultConfiguration()
+    }
+
+    return configuration
+}
+
+// SetConfig replaces the active configuration.
+func SetConfig(config *Configuration) {
+    configurationLock.Lock()
+    defer configurationLock.Unlock()
+
+    configuration = config
+}
+
+// ProcessConfiguration normalizes the loaded settings and applies defaults.
+func (c *Configuration) ProcessConfiguration() error {
+    if c.MaxNotificationsPerPost < 0 {
+        c.MaxNotificationsPerPost = 0
+    }
+
+    if c.CommandRateLimit < 0 {
+        c.CommandRateLimit = 0
+    }
+
+    if c.ImportConfirmThreshold < 0 {
+        c.ImportConfirmThreshold = 0
+    }
+
+    if c.ThreadNotificationWindow < 0 {
+        c.ThreadNotificationWindow = 0
+    }
+
+    if c.MentionAlertThreshold < 0 {
+        c.MentionAlertThreshold = 0
+    }
+
+    if c.MentionAlertWindow <= 0 {
+        c.MentionAlertWindow = defaultMentionAlertWindow
+    }
+
+    c.MentionAlertChannel = strings.TrimSpace(c.MentionAlertChannel)
+
+    c.ReportChannel = strings.TrimSpace(c.ReportChannel)
+    if c.ReportIntervalHours <= 0 {
+        c.ReportIntervalHours = defaultReportIntervalHours
+    }
+
+    if c.AutocompleteUserShare < 0 {
+        c.AutocompleteUserShare = 0
+    }
+    if c.AutocompleteUserShare > 100 {
+        c.AutocompleteUserShare = 100
+    }
+
+    if c.DynamicGroupChannelLimit < 0 {
+        c.DynamicGroupChannelLimit = 0
+    }
+
+    if c.SimilarGroupOverlap <= 0 {
+        c.SimilarGroupOverlap = defaultSimilarGroupOverlap
+    }
+    if c.SimilarGroupOverlap > 100 {
+        c.SimilarGroupOverlap = 100
+    }
+
+    if c.MembersPageSize <= 0 {
+        c.MembersPageSize = defaultMembersPageSize
+    }
+
+    if c.MaxMessageLength <= 0 {
+        c.MaxMessageLength = defaultMaxMessageLength
+    }
+
+    c.CommandTrigger = strings.TrimPrefix(strings.TrimSpace(c.CommandTrigger), "/")
+    if c.CommandTrigger == "" {
+        c.CommandTrigger = defaultCommandTrigger
+    }
+
+    c.SyncSecret = strings.TrimSpace(c.SyncSecret)
+    c.ReadOnlyToken = strings.TrimSpace(c.ReadOnlyToken)
+
+    c.RemoteServerURL = strings.TrimRight(strings.TrimSpace(c.RemoteServerURL), "/")
+    c.RemoteServerToken = strings.TrimSpace(c.RemoteServerToken)
+
+    c.MentionPolicyURL = strings.TrimSpace(c.MentionPolicyURL)
+    if c.MentionPolicyTimeout <= 0 {
+        c.MentionPolicyTimeout = defaultMentionPolicyTimeout
+    }
+
+    c.reservedGroupNames = make(map[string]bool)
+    for _, name := range strings.Split(c.ReservedGroupNames, ",") {
+        name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+        if name != "" {
+            c.reservedGroupNames[name] = true
+        }
+    }
+
+    c.defaultGroups = nil
+    for _, name := range strings.Split(c.DefaultGroups, ",") {
+        name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+        if name != "" && !containsString(c.defaultGroups, name) {
+            c.defaultGroups = append(c.defaultGroups, name)
+        }
+    }
+
+    return nil
+}
+
+// IsReservedGroupName reports whether a group may not use the name.
+func (c *Configuration) IsReservedGroupName(name string) bool {
+    return c.reservedGroupNames[strings.ToLower(name)]
+}
+
+// DefaultGroupNames returns the groups new users are added to.
+func (c *Configuration) DefaultGroupNames() []string {
+    return c.defaultGroups
+}
+
+// IsValid reports settings that cannot be applied.
+func (c *Configuration) IsValid() error {
+    if strings.ContainsAny(c.CommandTrigger, " \t\n") {
+        return errors.Errorf("command trigger %q must not contain spaces", c.CommandTrigger)
+    }
+
+    if c.RemoteServerURL != "" && !model.IsValidHTTPURL(c.RemoteServerURL) {
+        return errors.Errorf("remote server URL %q must be an http or https URL", c.RemoteServerURL)
+    }
+
+    if c.MentionPolicyURL != "" && !model.IsValidHTTPURL(c.MentionPolicyURL) {
+        return errors.Errorf("mention policy URL %q must be an http or https URL", c.MentionPolicyURL)
+    }
+
+    if c.MentionAlertChannel != "" && !model.IsValidId(c.MentionAlertChannel) {
+        return errors.Errorf("mention alert channel %q is not a valid channel ID", c.MentionAlertChannel)
+    }
+
+    if c.ReportChannel != "" && !model.IsValidId(c.ReportChannel) {
+        return errors.Errorf("report channel %q is not a valid channel ID", c.ReportChannel)
+    }
+
+    return nil
+}
+
+// Keys used by ToMap and FromMap. Every Configuration setting has a key here
+// so the two stay in sync.
+const (
+    keyMaxNotificationsPerPost    = "maxNotificationsPerPost"
+    keyCommandTrigger             = "commandTrigger"
+    keyMembersPageSize            = "membersPageSize"
+    keySyncSecret                 = "syncSecret"
+    keyMaxMessageLength           = "maxMessageLength"
+    keyAutocompleteEnabled        = "autocompleteEnabled"
+    keyAutocompleteUserShare      = "autocompleteUserShare"
+    keyCommandRateLimit           = "commandRateLimit"
+    keyImportConfirmThreshold     = "importConfirmThreshold"
+    keyThreadNotificationWindow   = "threadNotificationWindow"
+    keyReservedGroupNames         = "reservedGroupNames"
+    keyDefaultGroups              = "defaultGroups"
+    keyMentionAlertThreshold      = "mentionAlertThreshold"
+    keyMentionAlertWindow         = "mentionAlertWindow"
+    keyMentionAlertChannel        = "mentionAlertChannel"
+    keyDynamicGroupChannelLimit   = "dynamicGroupChannelLimit"
+    keyRemoteServerURL            = "remoteServerURL"
+    keyRemoteServerToken          = "remoteServerToken"
+    keyMentionPolicyURL           = "mentionPolicyURL"
+    keyMentionPolicyTimeout       = "mentionPolicyTimeout"
+    keyMentionPolicyFailClosed    = "mentionPolicyFailClosed"
+    keyRestrictManagementToAdmins = "restrictManagementToAdmins"
+    keyUnknownGroupHints          = "unknownGroupHints"
+    keyReadOnlyToken              = "readOnlyToken"
+    keySimilarGroupOverlap        = "similarGroupOverlap"
+    keyReportChannel              = "reportChannel"
+    keyReportIntervalHours        = "reportIntervalHours"
+)
+
+func (c *Configuration) ToMap() map[string]interface{} {
+    return map[string]interface{}{
+        keyMaxNotificationsPerPost:    c.MaxNotificationsPerPost,
+        keyCommandTrigger:             c.CommandTrigger,
+        keyMembersPageSize:            c.MembersPageSize,
+        keySyncSecret:                 c.SyncSecret,
+        keyMaxMessageLength:           c.MaxMessageLength,
+        keyAutocompleteEnabled:        c.AutocompleteEnabled,
+        keyAutocompleteUserShare:      c.AutocompleteUserShare,
+        keyCommandRateLimit:           c.CommandRateLimit,
+        keyImportConfirmThreshold:     c.ImportConfirmThreshold,
+        keyThreadNotificationWindow:   c.ThreadNotificationWindow,
+        keyReservedGroupNames:         c.ReservedGroupNames,
+        keyDefaultGroups:              c.DefaultGroups,
+        keyMentionAlertThreshold:      c.MentionAlertThreshold,
+        keyMentionAlertWindow:         c.MentionAlertWindow,
+        keyMentionAlertChannel:        c.MentionAlertChannel,
+        keyDynamicGroupChannelLimit:   c.DynamicGroupChannelLimit,
+        keyRemoteServerURL:            c.RemoteServerURL,
+        keyRemoteServerToken:          c.RemoteServerToken,
+        keyMentionPolicyURL:           c.MentionPolicyURL,
+        keyMentionPolicyTimeout:       c.MentionPolicyTimeout,
+        keyMentionPolicyFailClosed:    c.MentionPolicyFailClosed,
+        keyRestrictManagementToAdmins: c.RestrictManagementToAdmins,
+        keyUnknownGroupHints:          c.UnknownGroupHints,
+        keyReadOnlyToken:              c.ReadOnlyToken,
+        keySimilarGroupOverlap:        c.SimilarGroupOverlap,
+        keyReportChannel:              c.ReportChannel,
+        keyReportIntervalHours:        c.ReportIntervalHours,
+    }
+}
+
+// FromMap is the inverse of ToMap. Keys are matched case-insensitively, since
+// the server lowercases plugin setting keys when it stores them, and numbers
+// may be float64 after a JSON round trip. Missing keys keep their zero value.
+func FromMap(settings map[string]interface{}) (*Configuration, error) {
+    values := make(map[string]interface{}, len(settings))
+    for key, value := range settings {
+        values[strings.ToLower(key)] = value
+    }
+
+    c := &Configuration{}
+    var err error
+    if c.MaxNotificationsPerPost, err = intSetting(values, keyMaxNotificationsPerPost); err != nil {
+        return nil, err
+    }
+    if c.CommandTrigger, err = stringSetting(values, keyCommandTrigger); err != nil {
+        return nil, err
+    }
+    if c.MembersPageSize, err = intSetting(values, keyMembersPageSize); err != nil {
+        return nil, err
+    }
+    if c.SyncSecret, err = stringSetting(values, keySyncSecret); err != nil {
+        return nil, err
+    }
+    if c.MaxMessageLength, err = intSetting(values, keyMaxMessageLength); err != nil {
+        return nil, err
+    }
+    if c.AutocompleteEnabled, err = boolSetting(values, keyAutocompleteEnabled); err != nil {
+        return nil, err
+    }
+    if c.AutocompleteUserShare, err = intSetting(values, keyAutocompleteUserShare); err != nil {
+        return nil, err
+    }
+    if c.CommandRateLimit, err = intSetting(values, keyCommandRateLimit); err != nil {
+        return nil, err
+    }
+    if c.ImportConfirmThreshold, err = intSetting(values, keyImportConfirmThreshold); err != nil {
+        return nil, err
+    }
+    if c.ThreadNotificationWindow, err = intSetting(values, keyThreadNotificationWindow); err != nil {
+        return nil, err
+    }
+    if c.ReservedGroupNames, err = stringSetting(values, keyReservedGroupNames); err != nil {
+        return nil, err
+    }
+    if c.DefaultGroups, err = stringSetting(values, keyDefaultGroups); err != nil {
+        return nil, err
+    }
+    if c.MentionAlertThreshold, err = intSetting(values, keyMentionAlertThreshold); err != nil {
+        return nil, err
+    }
+    if c.MentionAlertWindow, err = intSetting(values, keyMentionAlertWindow); err != nil {
+        return nil, err
+    }
+    if c.MentionAlertChannel, err = stringSetting(values, keyMentionAlertChannel); err != nil {
+        return nil, err
+    }
+    if c.DynamicGroupChannelLimit, err = intSetting(values, keyDynamicGroupChannelLimit); err != nil {
+        return nil, err
+    }
+    if c.RemoteServerURL, err = stringSetting(values, keyRemoteServerURL); err != nil {
+        return nil, err
+    }
+    if c.RemoteServerToken, err = stringSetting(values, keyRemoteServerToken); err != nil {
+        return nil, err
+    }
+    if c.MentionPolicyURL, err = stringSetting(values, keyMentionPolicyURL); err != nil {
+        return nil, err
+    }
+    if c.MentionPolicyTimeout, err = intSetting(values, keyMentionPolicyTimeout); err != nil {
+        return nil, err
+    }
+    if c.MentionPolicyFailClosed, err = boolSetting(values, keyMentionPolicyFailClosed); err != nil {
+        return nil, err
+    }
+    if c.RestrictManagementToAdmins, err = boolSetting(values, keyRestrictManagementToAdmins); err != nil {
+        return nil, err
+    }
+    if c.UnknownGroupHints, err = boolSetting(values, keyUnknownGroupHints); err != nil {
+        return nil, err
+    }
+    if c.ReadOnlyToken, err = stringSetting(values, keyReadOnlyToken); err != nil {
+        return nil, err
+    }
+    if c.SimilarGroupOverlap, err = intSetting(values, keySimilarGroupOverlap); err != nil {
+        return nil, err
+    }
+    if c.ReportChannel, err = stringSetting(values, keyReportChannel); err != nil {
+        return nil, err
+    }
+    if c.ReportIntervalHours, err = intSetting(values, keyReportIntervalHours); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+func boolSetting(values map[string]interface{}, key string) (bool, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return false, nil
+    case bool:
+        return value, nil
+    case string:
+        parsed, err := strconv.ParseBool(value)
+        if err != nil {
+            return false, errors.Wrapf(err, "invalid value for %s", key)
+        }
+        return parsed, nil
+    default:
+        return false, errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+func stringSetting(values map[string]interface{}, key string) (string, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return "", nil
+    case string:
+        return value, nil
+    default:
+        return "", errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+func intSetting(values map[string]interface{}, key string) (int, error) {
+    switch value := values[strings.ToLower(key)].(type) {
+    case nil:
+        return 0, nil
+    case int:
+        return value, nil
+    case int64:
+        return int(value), nil
+    case float64:
+        return int(value), nil
+    case string:
+        parsed, err := strconv.Atoi(value)
+        if err != nil {
+            return 0, errors.Wrapf(err, "invalid value for %s", key)
+        }
+        return parsed, nil
+    default:
+        return 0, errors.Errorf("invalid type %T for %s", value, key)
+    }
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+// filledConfiguration returns a configuration with every exported field set
+// to a distinct non-zero value, so a dropped field fails the comparison.
+func filledConfiguration(t *testing.T) *Configuration {
+    c := &Configuration{}
+    value := reflect.ValueOf(c).Elem()
+    for i := 0; i < value.NumField(); i++ {
+        field := value.Type().Field(i)
+        if field.PkgPath != "" {
+            continue
+        }
+        switch field.Type.Kind() {
+        case reflect.Bool:
+            value.Field(i).SetBool(true)
+        case reflect.String:
+            value.Field(i).SetString("value of " + field.Name)
+        case reflect.Int:
+            value.Field(i).SetInt(int64(i + 1))
+        default:
+            t.Fatalf("no test value for %s of type %s", field.Name, field.Type)
+        }
+    }
+    return c
+}
+
+func TestToMapCoversEveryField(t *testing.T) {
+    settings := filledConfiguration(t).ToMap()
+
+    exported := 0
+    configType := reflect.TypeOf(Configuration{})
+    for i := 0; i < configType.NumField(); i++ {
+        if configType.Field(i).PkgPath == "" {
+            exported++
+        }
+    }
+    assert.Len(t, settings, exported)
+}
+
+func TestFromMapRoundTrip(t *testing.T) {
+    original := filledConfiguration(t)
+
+    restored, err := FromMap(original.ToMap())
+    require.NoError(t, err)
+    assert.Equal(t, original, restored)
+}
+
+func TestFromMapRoundTripThroughStoredSettings(t *testing.T) {
+    original := filledConfiguration(t)
+
+    // The server stores plugin settings as JSON with lowercased keys
+    stored := make(map[string]interface{})
+    for key, value := range original.ToMap() {
+        stored[strings.ToLower(key)] = value
+    }
+    data, err := json.Marshal(stored)
+    require.NoError(t, err)
+    var loaded map[string]interface{}
+    require.NoError(t, json.Unmarshal(data, &loaded))
+
+    restored, err := FromMap(loaded)
+    require.NoError(t, err)
+    assert.Equal(t, original, restored)
+}
+
+func TestDefaultConfigurationRoundTrip(t *testing.T) {
+    original := DefaultConfiguration()
+
+    restored, err := FromMap(original.ToMap())
+    require.NoError(t, err)
+    assert.Equal(t, original, restored)
+}
+
+func TestFromMapRejectsWrongTypes(t *testing.T) {
+    for _, settings := range []map[string]interface{}{
+        {keyAutocompleteEnabled: "maybe"},
+        {keyAutocompleteEnabled: 1},
+        {keyCommandTrigger: true},
+        {keyMembersPageSize: "many"},
+        {keyMembersPageSize: []string{"1"}},
+    } {
+        _, err := FromMap(settings)
+        assert.Error(t, err, settings)
+    }
+}
+
+func TestFromMapParsesStrings(t *testing.T) {
+    c, err := FromMap(map[string]interface{}{keyAutocompleteEnabled: "true", keyMembersPageSize: "12"})
+    require.NoError(t, err)
+    assert.True(t, c.AutocompleteEnabled)
+    assert.Equal(t, 12, c.MembersPageSize)
+}
+
+func TestProcessConfigurationAppliesDefaults(t *testing.T) {
+    c := &Configuration{
+        CommandTrigger:          " /groups ",
+        MaxNotificationsPerPost: -1,
+        AutocompleteUserShare:   150,
+        SimilarGroupOverlap:     -5,
+        RemoteServerURL:         " https://example.com/ ",
+        ReservedGroupNames:      " @Admins , ,staff",
+        DefaultGroups:           "@Everyone-New, everyone-new,dev",
+    }
+    require.NoError(t, c.ProcessConfiguration())
+
+    assert.Equal(t, "groups", c.CommandTrigger)
+    assert.Equal(t, 0, c.MaxNotificationsPerPost)
+    assert.Equal(t, 100, c.AutocompleteUserShare)
+    assert.Equal(t, defaultSimilarGroupOverlap, c.SimilarGroupOverlap)
+    assert.Equal(t, defaultMembersPageSize, c.MembersPageSize)
+    assert.Equal(t, defaultMaxMessageLength, c.MaxMessageLength)
+    assert.Equal(t, defaultMentionAlertWindow, c.MentionAlertWindow)
+    assert.Equal(t, defaultMentionPolicyTimeout, c.MentionPolicyTimeout)
+    assert.Equal(t, defaultReportIntervalHours, c.ReportIntervalHours)
+    assert.Equal(t, "https://example.com", c.RemoteServerURL)
+    assert.True(t, c.IsReservedGroupName("ADMINS"))
+    assert.True(t, c.IsReservedGroupName("staff"))
+    assert.False(t, c.IsReservedGroupName(""))
+    assert.Equal(t, []string{"everyone-new", "dev"}, c.DefaultGroupNames())
+    assert.NoError(t, c.IsValid())
+}
+
+func TestIsValidRejectsBadSettings(t *testing.T) {
+    for _, c := range []*Configuration{
+        {CommandTrigger: "two words"},
+        {RemoteServerURL: "ftp://example.com"},
+        {MentionPolicyURL: "not a url"},
+        {MentionAlertChannel: "town-square"},
+        {ReportChannel: "town-square"},
+    } {
+        require.NoError(t, c.ProcessConfiguration())
+        assert.Error(t, c.IsValid(), c)
+    }
+
+    c := &Configuration{MentionAlertChannel: model.NewId(), ReportChannel: model.NewId(), MentionPolicyURL: "https://policy.example.com"}
+    require.NoError(t, c.ProcessConfiguration())
+    assert.NoError(t, c.IsValid())
+}
+
+func TestGetConfigDefaultsUntilSet(t *testing.T) {
+    SetConfig(nil)
+    t.Cleanup(func() { SetConfig(nil) })
+    assert.Equal(t, DefaultConfiguration(), GetConfig())
+
+    c := &Configuration{CommandTrigger: "groups"}
+    SetConfig(c)
+    assert.Same(t, c, GetConfig())
+}
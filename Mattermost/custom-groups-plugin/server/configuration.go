@@ -0,0 +1,38 @@
+package main
+
+import (
+    "github.com/pkg/errors"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// OnConfigurationChange loads, processes and validates the settings, then
+// applies them live. The slash command is re-registered when its trigger
+// changed.
+func (p *Plugin) OnConfigurationChange() error {
+    configuration := config.DefaultConfiguration()
+
+    if err := p.API.LoadPluginConfiguration(configuration); err != nil {
+        p.API.LogError("Error in LoadPluginConfiguration: " + err.Error())
+        return errors.Wrap(err, "failed to load plugin configuration")
+    }
+
+    if err := configuration.ProcessConfiguration(); err != nil {
+        p.API.LogError("Error in ProcessConfiguration: " + err.Error())
+        return errors.Wrap(err, "failed to process configuration")
+    }
+
+    if err := configuration.IsValid(); err != nil {
+        p.API.LogError("Error in Validating Configuration: " + err.Error())
+        return errors.Wrap(err, "configuration is invalid")
+    }
+
+    config.SetConfig(configuration)
+
+    if err := p.registerCommand(configuration.CommandTrigger); err != nil {
+        p.API.LogError("Error in RegisterCommand: " + err.Error())
+        return errors.Wrap(err, "failed to register command")
+    }
+
+    return nil
+}
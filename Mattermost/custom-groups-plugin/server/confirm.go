@@ -0,0 +1,179 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // ID of the plugin, used to build interactive button URLs
+    pluginID = "com.mattermost.custom-groups"
+
+    // How long an import waits for confirmation before it is discarded
+    pendingImportTTL = 10 * time.Minute
+
+    // Usernames shown in an import preview before the rest is summarized
+    importPreviewSize = 20
+)
+
+// pendingImport is an import waiting for its author to confirm it.
+type pendingImport struct {
+    UserID    string
+    Group     string
+    Usernames []string
+    Expires   time.Time
+}
+
+// pendingImports holds imports waiting for confirmation, keyed by a random ID.
+// The zero value is ready to use.
+type pendingImports struct {
+    mu      sync.Mutex
+    imports map[string]*pendingImport
+}
+
+// add stores an import and returns its ID. Expired imports are dropped.
+func (s *pendingImports) add(pending *pendingImport) string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.imports == nil {
+        s.imports = make(map[string]*pendingImport)
+    }
+
+    now := time.Now()
+    for id, existing := range s.imports {
+        if now.After(existing.Expires) {
+            delete(s.imports, id)
+        }
+    }
+
+    id := model.NewId()
+    s.imports[id] = pending
+    return id
+}
+
+// take removes and returns the import with the given ID, if it has not
+// expired.
+func (s *pendingImports) take(id string) (*pendingImport, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    pending, ok := s.imports[id]
+    if !ok {
+        return nil, false
+    }
+
+    delete(s.imports, id)
+    if time.Now().After(pending.Expires) {
+        return nil, false
+    }
+    return pending, true
+}
+
+// importConfirmation returns the preview of a large import with buttons to
+// apply or cancel it.
+func (p *Plugin) importConfirmation(userID, groupName string, usernames []string, preview *ImportResult) *model.CommandResponse {
+    id := p.pendingImports.add(&pendingImport{
+        UserID:    userID,
+        Group:     groupName,
+        Usernames: usernames,
+        Expires:   time.Now().Add(pendingImportTTL),
+    })
+
+    url := fmt.Sprintf("/plugins/%s/api/v4/groups/import/confirm", pluginID)
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("This import would add %d members to group %s. Please confirm within %d minutes.", len(preview.Added), groupName, int(pendingImportTTL/time.Minute)),
+        ResponseType: model.CommandResponseTypeEphemeral,
+        Attachments: []*model.SlackAttachment{{
+            Fields: []*model.SlackAttachmentField{
+                {Title: "Added", Value: previewList(preview.Added)},
+                {Title: "Already members", Value: previewList(preview.Skipped)},
+                {Title: "Not found", Value: previewList(preview.Errors)},
+            },
+            Actions: []*model.PostAction{
+                {
+                    Name:  "Import",
+                    Style: "primary",
+                    Integration: &model.PostActionIntegration{
+                        URL:     url,
+                        Context: map[string]interface{}{"import_id": id, "confirm": true},
+                    },
+                },
+                {
+                    Name: "Cancel",
+                    Integration: &model.PostActionIntegration{
+                        URL:     url,
+                        Context: map[string]interface{}{"import_id": id, "confirm": false},
+                    },
+                },
+            },
+        }},
+    }
+}
+
+// previewList shows the first usernames of a list and counts the rest.
+func previewList(usernames []string) string {
+    if len(usernames) == 0 {
+        return "none"
+    }
+
+    if len(usernames) <= importPreviewSize {
+        return strings.Join(usernames, ", ")
+    }
+
+    return fmt.Sprintf("%s and %d more", strings.Join(usernames[:importPreviewSize], ", "), len(usernames)-importPreviewSize)
+}
+
+// handleImportConfirm applies or cancels a pending import when its author
+// clicks one of the confirmation buttons.
+func (p *Plugin) handleImportConfirm(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req model.PostActionIntegrationRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-ID")
+    importID, _ := req.Context["import_id"].(string)
+    confirm, _ := req.Context["confirm"].(bool)
+
+    pending, ok := p.pendingImports.take(importID)
+    if !ok {
+        writeActionResponse(w, "This import has expired or was already handled. Please run the import again.")
+        return
+    }
+
+    if userID == "" || pending.UserID != userID {
+        http.Error(w, "Only the user who started the import can confirm it", http.StatusForbidden)
+        return
+    }
+
+    if !confirm {
+        writeActionResponse(w, fmt.Sprintf("Import into group %s cancelled", pending.Group))
+        return
+    }
+
+    result, err := p.importGroupMembers(pending.Group, pending.Usernames, userID)
+    if err != nil {
+        writeActionResponse(w, commandErrorText(err, pending.Group, fmt.Sprintf("Error importing members: %v", err)))
+        return
+    }
+
+    writeActionResponse(w, fmt.Sprintf("Successfully imported members into group %s (%d added, %d already members, %d not found)", pending.Group, len(result.Added), len(result.Skipped), len(result.Errors)))
+}
+
+func writeActionResponse(w http.ResponseWriter, text string) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(&model.PostActionIntegrationResponse{EphemeralText: text})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+    "encoding/csv"
+    "strings"
+    "time"
+)
+
+const (
+    // Header of the username column in exported and imported CSV
+    csvUsernameHeader = "username"
+
+    // Header of the join date column in exported CSV, ignored on import
+    csvJoinedAtHeader = "joined_at"
+)
+
+// encodeMembersCSV writes usernames and their join dates as CSV with a
+// header row, quoting values where needed. Unknown join dates are left empty.
+func encodeMembersCSV(usernames []string, joinedAt map[string]int64) (string, error) {
+    var out strings.Builder
+    writer := csv.NewWriter(&out)
+
+    if err := writer.Write([]string{csvUsernameHeader, csvJoinedAtHeader}); err != nil {
+        return "", err
+    }
+    for _, username := range usernames {
+        joined := ""
+        if millis := joinedAt[username]; millis != 0 {
+            joined = time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+        }
+        if err := writer.Write([]string{username, joined}); err != nil {
+            return "", err
+        }
+    }
+
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return "", err
+    }
+
+    return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// parseMembersCSV reads usernames from CSV. When the first row has a
+// username column header, only that column is read; otherwise every field
+// is a username, which also accepts the single-line username1,username2
+// format.
+func parseMembersCSV(data string) ([]string, error) {
+    reader := csv.NewReader(strings.NewReader(data))
+    reader.FieldsPerRecord = -1
+    reader.TrimLeadingSpace = true
+
+    records, err := reader.ReadAll()
+    if err != nil {
+        return nil, err
+    }
+
+    column := -1
+    if len(records) > 0 {
+        for i, field := range records[0] {
+            if strings.EqualFold(strings.TrimSpace(field), csvUsernameHeader) {
+                column = i
+                records = records[1:]
+                break
+            }
+        }
+    }
+
+    usernames := []string{}
+    for _, record := range records {
+        for i, field := range record {
+            if column >= 0 && i != column {
+                continue
+            }
+            if username := strings.TrimPrefix(strings.TrimSpace(field), "@"); username != "" {
+                usernames = append(usernames, username)
+            }
+        }
+    }
+
+    return usernames, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestEncodeMembersCSV(t *testing.T) {
+    joined := int64(1700000000000)
+    csv, err := encodeMembersCSV(
+        []string{"alice", "comma,name", "quote\"name", "new\nline"},
+        map[string]int64{"alice": joined},
+    )
+    require.NoError(t, err)
+    assert.Equal(t, "username,joined_at\nalice,2023-11-14T22:13:20Z\n\"comma,name\",\n\"quote\"\"name\",\n\"new\nline\",", csv)
+
+    csv, err = encodeMembersCSV(nil, nil)
+    require.NoError(t, err)
+    assert.Equal(t, "username,joined_at", csv)
+}
+
+func TestMembersCSVRoundTrip(t *testing.T) {
+    usernames := []string{"alice", "comma,name", "quote\"name", "  spaced", "new\nline"}
+    csv, err := encodeMembersCSV(usernames, nil)
+    require.NoError(t, err)
+
+    parsed, err := parseMembersCSV(csv)
+    require.NoError(t, err)
+    assert.Equal(t, []string{"alice", "comma,name", "quote\"name", "spaced", "new\nline"}, parsed)
+}
+
+func TestParseMembersCSV(t *testing.T) {
+    for _, tc := range []struct {
+        name     string
+        data     string
+        expected []string
+    }{
+        {"single line", "alice,bob, @carol", []string{"alice", "bob", "carol"}},
+        {"one per line", "alice\nbob\r\ncarol\n", []string{"alice", "bob", "carol"}},
+        {"header picks the column", "email,Username,joined_at\na@example.com,alice,\nb@example.com,bob,2024-01-01", []string{"alice", "bob"}},
+        {"ragged rows", "username,note\nalice\nbob,extra,fields", []string{"alice", "bob"}},
+        {"empty fields", "alice,,  ,\n,bob", []string{"alice", "bob"}},
+        {"empty", "", []string{}},
+        {"quoted", "\"alice\",\"b,ob\"", []string{"alice", "b,ob"}},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            usernames, err := parseMembersCSV(tc.data)
+            require.NoError(t, err)
+            assert.Equal(t, tc.expected, usernames)
+        })
+    }
+
+    for _, data := range []string{"\"alice,bob", "al\"ice"} {
+        _, err := parseMembersCSV(data)
+        assert.Error(t, err, data)
+    }
+}
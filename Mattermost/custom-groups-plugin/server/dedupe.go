@@ -0,0 +1,76 @@
+package main
+
+import (
+    "fmt"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// dedupeMembers returns the members with repeated IDs dropped, keeping the
+// first occurrence of each, and how many were dropped.
+func dedupeMembers(members []string) ([]string, int) {
+    seen := make(map[string]bool, len(members))
+    unique := make([]string, 0, len(members))
+    for _, userID := range members {
+        if seen[userID] {
+            continue
+        }
+        seen[userID] = true
+        unique = append(unique, userID)
+    }
+    return unique, len(members) - len(unique)
+}
+
+// dedupeGroup removes repeated member IDs from a group in place and persists
+// the group when any were found. It returns how many were removed.
+func (p *Plugin) dedupeGroup(groupName string) (int, error) {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return 0, groupError(ErrGroupNotFound, groupName)
+    }
+
+    unique, removed := dedupeMembers(members)
+    if removed == 0 {
+        p.groupMutex.Unlock()
+        return 0, nil
+    }
+    p.groups[groupName] = unique
+    p.touchGroup(groupName)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return removed, p.saveGroupState()
+}
+
+// dedupeCommand removes repeated member IDs from a group.
+func (p *Plugin) dedupeCommand(trigger string, args []string, quiet bool) *model.CommandResponse {
+    if len(args) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name: `/%s dedupe group_name`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := args[0]
+
+    removed, err := p.dedupeGroup(groupName)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if removed == 0 {
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Group %s has no duplicate members", groupName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: successText(quiet, fmt.Sprintf("Removed %d duplicate members from group %s", removed, groupName)),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
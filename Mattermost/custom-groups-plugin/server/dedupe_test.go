@@ -0,0 +1,69 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestDedupeMembersKeepsFirstOccurrence(t *testing.T) {
+    for _, tc := range []struct {
+        name     string
+        members  []string
+        expected []string
+        removed  int
+    }{
+        {"empty", []string{}, []string{}, 0},
+        {"no duplicates", []string{"a", "b", "c"}, []string{"a", "b", "c"}, 0},
+        {"adjacent duplicates", []string{"a", "a", "b"}, []string{"a", "b"}, 1},
+        {"scattered duplicates", []string{"c", "a", "c", "b", "a", "c"}, []string{"c", "a", "b"}, 3},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            unique, removed := dedupeMembers(tc.members)
+            assert.Equal(t, tc.expected, unique)
+            assert.Equal(t, tc.removed, removed)
+        })
+    }
+}
+
+func TestDedupeCommandRemovesDuplicates(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"a", "b", "c"}, "", "creator"))
+    p.groups["dev"] = []string{"b", "a", "b", "c", "a", "b"}
+
+    response := p.dedupeCommand("group", []string{"dev"}, false)
+
+    assert.Equal(t, "Removed 3 duplicate members from group dev", response.Text)
+    assert.Equal(t, []string{"b", "a", "c"}, p.groups["dev"])
+    assert.Equal(t, []string{"b", "a", "c"}, loadTestServer(t, api).groups["dev"], "the deduplicated group should be saved")
+
+    response = p.dedupeCommand("group", []string{"dev"}, false)
+    assert.Equal(t, "Group dev has no duplicate members", response.Text)
+}
+
+func TestDedupeCommandErrors(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+
+    assert.Equal(t, "Please specify a group name: `/group dedupe group_name`", p.dedupeCommand("group", nil, false).Text)
+    assert.Contains(t, p.dedupeCommand("group", []string{"missing"}, false).Text, "missing")
+}
+
+func TestImportDropsExistingDuplicates(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "a", Username: "alice"}, &model.User{Id: "b", Username: "bob"})
+    api.On("GetUserByUsername", "carol").Return(&model.User{Id: "c", Username: "carol"}, nil)
+    api.On("GetUserByUsername", "alice").Return(&model.User{Id: "a", Username: "alice"}, nil).Maybe()
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"a", "b"}, "", "creator"))
+    p.groups["dev"] = []string{"a", "b", "a", "b"}
+
+    _, err := p.importGroupMembers("dev", []string{"carol", "alice"}, "creator")
+    require.NoError(t, err)
+
+    assert.Equal(t, []string{"a", "b", "c"}, p.groups["dev"])
+    assert.Equal(t, []string{"a", "b", "c"}, loadTestServer(t, api).groups["dev"])
+}
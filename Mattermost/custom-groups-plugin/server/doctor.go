@@ -0,0 +1,244 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// DoctorReport lists the problems found in the groups dataset by category.
+// Missing members and orphaned entries are safe to fix; the other categories
+// need a decision and are only reported.
+type DoctorReport struct {
+    Empty           []string            `json:"empty"`            // groups without members
+    Deactivated     []string            `json:"deactivated"`      // groups whose members are all deactivated
+    UserCollisions  []string            `json:"user_collisions"`  // groups named like a user
+    CaseCollisions  []string            `json:"case_collisions"`  // groups whose names differ only by case
+    Oversized       []string            `json:"oversized"`        // groups larger than MaxNotificationsPerPost
+    MissingMembers  map[string][]string `json:"missing_members"`  // map[groupName]IDs of users that no longer exist
+    OrphanMetadata  []string            `json:"orphan_metadata"`  // metadata of groups that no longer exist
+    OrphanSchedules map[string][]string `json:"orphan_schedules"` // map[groupName]IDs of scheduled non-members
+    OrphanDynamic   []string            `json:"orphan_dynamic"`   // dynamic groups whose channel no longer exists
+    Fixed           bool                `json:"fixed"`
+}
+
+// problems counts the problems in the report.
+func (r *DoctorReport) problems() int {
+    count := len(r.Empty) + len(r.Deactivated) + len(r.UserCollisions) + len(r.CaseCollisions) + len(r.Oversized) + len(r.OrphanMetadata) + len(r.OrphanDynamic)
+    for _, ids := range r.MissingMembers {
+        count += len(ids)
+    }
+    for _, ids := range r.OrphanSchedules {
+        count += len(ids)
+    }
+    return count
+}
+
+// diagnoseGroups scans every group for problems without changing anything.
+func (p *Plugin) diagnoseGroups() *DoctorReport {
+    report := &DoctorReport{
+        Empty:           []string{},
+        Deactivated:     []string{},
+        UserCollisions:  []string{},
+        CaseCollisions:  []string{},
+        Oversized:       []string{},
+        MissingMembers:  make(map[string][]string),
+        OrphanMetadata:  []string{},
+        OrphanSchedules: make(map[string][]string),
+        OrphanDynamic:   []string{},
+    }
+
+    // Copy the state so users are looked up without holding the lock
+    p.groupMutex.RLock()
+    report.CaseCollisions = p.caseCollisions()
+    groups := make(map[string][]string, len(p.groups))
+    for groupName, members := range p.groups {
+        groups[groupName] = append([]string{}, members...)
+    }
+    for groupName, metadata := range p.groupMetadata {
+        if _, exists := p.groups[groupName]; !exists {
+            report.OrphanMetadata = append(report.OrphanMetadata, groupName)
+            continue
+        }
+        for userID := range metadata.Schedules {
+            if !contains(p.groups[groupName], userID) {
+                report.OrphanSchedules[groupName] = append(report.OrphanSchedules[groupName], userID)
+            }
+        }
+    }
+    channels := make(map[string]string, len(p.dynamicGroups))
+    for groupName, group := range p.dynamicGroups {
+        channels[groupName] = group.ChannelID
+    }
+    p.groupMutex.RUnlock()
+
+    limit := config.GetConfig().MaxNotificationsPerPost
+    users := make(map[string]*model.User)
+    for groupName, members := range groups {
+        if len(members) == 0 {
+            report.Empty = append(report.Empty, groupName)
+        }
+        if limit > 0 && len(members) > limit {
+            report.Oversized = append(report.Oversized, groupName)
+        }
+        if _, appErr := p.API.GetUserByUsername(groupName); appErr == nil {
+            report.UserCollisions = append(report.UserCollisions, groupName)
+        }
+
+        active := 0
+        for _, userID := range members {
+            user, seen := users[userID]
+            if !seen {
+                user, _ = p.API.GetUser(userID)
+                users[userID] = user
+            }
+            switch {
+            case user == nil:
+                report.MissingMembers[groupName] = append(report.MissingMembers[groupName], userID)
+            case user.DeleteAt == 0:
+                active++
+            }
+        }
+        if len(members) > 0 && active == 0 && len(report.MissingMembers[groupName]) < len(members) {
+            report.Deactivated = append(report.Deactivated, groupName)
+        }
+    }
+
+    for groupName, channelID := range channels {
+        if _, appErr := p.API.GetChannel(channelID); appErr != nil {
+            report.OrphanDynamic = append(report.OrphanDynamic, groupName)
+        }
+    }
+
+    for _, names := range [][]string{report.Empty, report.Deactivated, report.UserCollisions, report.Oversized, report.OrphanMetadata, report.OrphanDynamic} {
+        sort.Strings(names)
+    }
+
+    return report
+}
+
+// fixGroups applies the safe fixes of a report: members whose accounts no
+// longer exist are removed and orphaned metadata and schedules are dropped.
+func (p *Plugin) fixGroups(report *DoctorReport, actorID string) error {
+    for groupName, missing := range report.MissingMembers {
+        p.groupMutex.RLock()
+        members := p.groups[groupName]
+        kept := make([]string, 0, len(members))
+        for _, userID := range members {
+            if !contains(missing, userID) {
+                kept = append(kept, userID)
+            }
+        }
+        p.groupMutex.RUnlock()
+
+        if _, _, err := p.setGroupMembers(groupName, kept, actorID); err != nil {
+            return err
+        }
+    }
+
+    p.groupMutex.Lock()
+    for _, groupName := range report.OrphanMetadata {
+        if _, exists := p.groups[groupName]; !exists {
+            delete(p.groupMetadata, groupName)
+        }
+    }
+    for groupName, userIDs := range report.OrphanSchedules {
+        metadata, ok := p.groupMetadata[groupName]
+        if !ok {
+            continue
+        }
+        for _, userID := range userIDs {
+            if !contains(p.groups[groupName], userID) {
+                delete(metadata.Schedules, userID)
+            }
+        }
+        if metadata.isEmpty() {
+            delete(p.groupMetadata, groupName)
+        }
+    }
+    p.groupMutex.Unlock()
+
+    report.Fixed = true
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// doctorReportText renders a report with one section per category that has
+// problems.
+func (p *Plugin) doctorReportText(report *DoctorReport, fix bool, trigger string) string {
+    if report.problems() == 0 {
+        return "No problems found"
+    }
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Found %d problems:\n", report.problems()))
+    section := func(title string, names []string) {
+        if len(names) > 0 {
+            text.WriteString(fmt.Sprintf("**%s:** %s\n", title, previewList(names)))
+        }
+    }
+    memberSection := func(title string, byGroup map[string][]string) {
+        groupNames := make([]string, 0, len(byGroup))
+        for groupName := range byGroup {
+            groupNames = append(groupNames, groupName)
+        }
+        sort.Strings(groupNames)
+        for _, groupName := range groupNames {
+            text.WriteString(fmt.Sprintf("**%s in %s:** %s\n", title, groupName, previewList(byGroup[groupName])))
+        }
+    }
+
+    section("Empty groups", report.Empty)
+    section("Groups with only deactivated members", report.Deactivated)
+    section("Groups named like a user", report.UserCollisions)
+    section("Groups whose names differ only by case", report.CaseCollisions)
+    section("Groups larger than the notification cap", report.Oversized)
+    memberSection("Members that no longer exist", report.MissingMembers)
+    section("Settings of deleted groups", report.OrphanMetadata)
+    memberSection("Schedules of non-members", report.OrphanSchedules)
+    section("Dynamic groups of deleted channels", report.OrphanDynamic)
+
+    if fix {
+        text.WriteString("\nRemoved members that no longer exist and dropped orphaned settings and schedules. The other problems need a decision and were left as is.")
+    } else {
+        text.WriteString(fmt.Sprintf("\nNothing was changed. Run `/%s doctor --fix` to remove members that no longer exist and drop orphaned settings and schedules.", trigger))
+    }
+    return text.String()
+}
+
+// doctorCommand reports problems in the groups dataset and, with --fix,
+// applies the safe fixes. Only system admins can use it.
+func (p *Plugin) doctorCommand(userID, trigger string, args []string, asJSON bool) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can check the groups",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    _, fix := extractFlag(args, "--fix")
+
+    report := p.diagnoseGroups()
+    if fix {
+        if err := p.fixGroups(report, userID); err != nil {
+            return &model.CommandResponse{
+                Text: "Failed to save changes",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+    }
+
+    if asJSON {
+        return jsonResponse(report)
+    }
+
+    return &model.CommandResponse{
+        Text: p.doctorReportText(report, fix, trigger),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+const (
+    // Key for storing dynamic groups in KV store
+    dynamicGroupsKey = "custom_groups_dynamic"
+
+    // Channel members loaded per API call when resolving a dynamic group
+    dynamicGroupPageSize = 200
+)
+
+// Channel roles a dynamic group can select
+const (
+    DynamicRoleAdmins = "admins"
+    DynamicRoleGuests = "guests"
+    DynamicRoleAll    = "all"
+)
+
+// DynamicGroup is a group whose members are the members of a channel with a
+// given role, resolved whenever the group is mentioned.
+type DynamicGroup struct {
+    ChannelID string `json:"channel_id"`
+    Role      string `json:"role"`       // DynamicRoleAdmins, DynamicRoleGuests or DynamicRoleAll
+    CreatedBy string `json:"created_by"` // user ID
+    CreatedAt int64  `json:"created_at"` // milliseconds since epoch
+}
+
+// matches reports whether a channel member has the group's role.
+func (g *DynamicGroup) matches(member *model.ChannelMember) bool {
+    switch g.Role {
+    case DynamicRoleAdmins:
+        return member.SchemeAdmin
+    case DynamicRoleGuests:
+        return member.SchemeGuest
+    default:
+        return true
+    }
+}
+
+func (p *Plugin) loadDynamicGroups() error {
+    p.groupMutex.Lock()
+    defer p.groupMutex.Unlock()
+
+    p.dynamicGroups = make(map[string]*DynamicGroup)
+
+    data, appErr := p.API.KVGet(dynamicGroupsKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    if data != nil {
+        if err := json.Unmarshal(data, &p.dynamicGroups); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (p *Plugin) saveDynamicGroups() error {
+    return p.saveBlob(dynamicGroupsKey, func() interface{} { return p.dynamicGroups })
+}
+
+// groupNameTaken reports whether a static or dynamic group uses the name.
+// Callers must hold groupMutex.
+func (p *Plugin) groupNameTaken(groupName string) bool {
+    if _, exists := p.groups[groupName]; exists {
+        return true
+    }
+    _, exists := p.dynamicGroups[groupName]
+    return exists
+}
+
+// dynamicMembers resolves the members of a dynamic group for a post by
+// userID. It fails when the author cannot read the source channel, so private
+// channel rosters are not revealed, or when the channel has more members
+// than DynamicGroupChannelLimit.
+func (p *Plugin) dynamicMembers(group *DynamicGroup, userID string) ([]string, error) {
+    if !p.API.HasPermissionToChannel(userID, group.ChannelID, model.PermissionReadChannel) {
+        return nil, fmt.Errorf("user cannot read channel %s", group.ChannelID)
+    }
+
+    stats, appErr := p.API.GetChannelStats(group.ChannelID)
+    if appErr != nil {
+        return nil, appErr
+    }
+    if limit := config.GetConfig().DynamicGroupChannelLimit; limit > 0 && stats.MemberCount > int64(limit) {
+        return nil, fmt.Errorf("channel %s has %d members, more than the limit of %d", group.ChannelID, stats.MemberCount, limit)
+    }
+
+    members := []string{}
+    for page := 0; ; page++ {
+        channelMembers, appErr := p.API.GetChannelMembers(group.ChannelID, page, dynamicGroupPageSize)
+        if appErr != nil {
+            return nil, appErr
+        }
+        for _, member := range channelMembers {
+            if group.matches(&member) {
+                members = append(members, member.UserId)
+            }
+        }
+        if len(channelMembers) < dynamicGroupPageSize {
+            break
+        }
+    }
+
+    return members, nil
+}
+
+// canManageDynamicGroup reports whether the user created the dynamic group or
+// can manage the roles of its channel.
+func (p *Plugin) canManageDynamicGroup(group *DynamicGroup, userID string) bool {
+    return group.CreatedBy == userID || p.API.HasPermissionToChannel(userID, group.ChannelID, model.PermissionManageChannelRoles)
+}
+
+// setDynamicGroup creates or replaces a dynamic group. Static groups keep
+// their name, and a dynamic group can only be replaced by a user who may
+// manage it, so nobody can point someone else's group at their own channel.
+// actorID is the user setting the group.
+func (p *Plugin) setDynamicGroup(groupName string, group *DynamicGroup, actorID string) error {
+    if config.GetConfig().IsReservedGroupName(groupName) {
+        return groupError(ErrReservedName, groupName)
+    }
+
+    p.groupMutex.RLock()
+    existing := p.dynamicGroups[groupName]
+    p.groupMutex.RUnlock()
+    if existing != nil && !p.canManageDynamicGroup(existing, actorID) {
+        return groupError(ErrGroupExists, groupName)
+    }
+
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupExists, groupName)
+    }
+    // The group changed hands while the permission was checked
+    if p.dynamicGroups[groupName] != existing {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupExists, groupName)
+    }
+    p.dynamicGroups[groupName] = group
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveDynamicGroups()
+}
+
+// deleteDynamicGroup removes a dynamic group.
+func (p *Plugin) deleteDynamicGroup(groupName string) error {
+    p.groupMutex.Lock()
+    if _, exists := p.dynamicGroups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+    delete(p.dynamicGroups, groupName)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveDynamicGroups()
+}
+
+// dynamicGroupsText lists the dynamic groups with their source channels.
+func (p *Plugin) dynamicGroupsText() string {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    if len(p.dynamicGroups) == 0 {
+        return "No dynamic groups"
+    }
+
+    names := make([]string, 0, len(p.dynamicGroups))
+    for groupName := range p.dynamicGroups {
+        names = append(names, groupName)
+    }
+    sort.Strings(names)
+
+    var text strings.Builder
+    text.WriteString("Dynamic groups:\n")
+    for _, groupName := range names {
+        group := p.dynamicGroups[groupName]
+        channelName := group.ChannelID
+        if channel, appErr := p.API.GetChannel(group.ChannelID); appErr == nil {
+            channelName = channel.Name
+        }
+        text.WriteString(fmt.Sprintf("- **%s**: %s of ~%s\n", groupName, group.Role, channelName))
+    }
+
+    return text.String()
+}
+
+// dynamicCommand lists, creates or deletes dynamic groups. Creating a group
+// for a channel requires permission to manage the channel's roles.
+func (p *Plugin) dynamicCommand(args *model.CommandArgs, trigger string, params []string) *model.CommandResponse {
+    usage := fmt.Sprintf("Please specify a group name, channel and role: `/%s dynamic group_name ~channel admins|guests|all` or `/%s dynamic group_name none`", trigger, trigger)
+
+    if len(params) == 0 {
+        return &model.CommandResponse{
+            Text: p.dynamicGroupsText(),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := params[0]
+
+    if len(params) == 2 && strings.EqualFold(params[1], "none") {
+        p.groupMutex.RLock()
+        group, exists := p.dynamicGroups[groupName]
+        p.groupMutex.RUnlock()
+        if exists && !p.canManageDynamicGroup(group, args.UserId) {
+            return &model.CommandResponse{
+                Text: "Only channel admins or the creator can delete this dynamic group",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+
+        if err := p.deleteDynamicGroup(groupName); err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Deleted dynamic group %s", groupName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(params) != 3 {
+        return &model.CommandResponse{
+            Text: usage,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    role := strings.ToLower(params[2])
+    if role != DynamicRoleAdmins && role != DynamicRoleGuests && role != DynamicRoleAll {
+        return &model.CommandResponse{
+            Text: usage,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    channelName := strings.TrimPrefix(params[1], "~")
+    channel, appErr := p.API.GetChannelByName(args.TeamId, channelName, false)
+    if appErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Channel ~%s not found", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if !p.API.HasPermissionToChannel(args.UserId, channel.Id, model.PermissionManageChannelRoles) {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Only admins of ~%s can create dynamic groups for it", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if err := p.setDynamicGroup(groupName, &DynamicGroup{
+        ChannelID: channel.Id,
+        Role:      role,
+        CreatedBy: args.UserId,
+        CreatedAt: model.GetMillis(),
+    }, args.UserId); err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("Mentioning @%s now notifies the %s of ~%s", groupName, role, channelName),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestDynamicGroupCannotBeTakenOver(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("GetChannelByName", "team", "incidents", false).Return(&model.Channel{Id: "incidents", Name: "incidents"}, nil)
+    api.On("GetChannelByName", "team", "mine", false).Return(&model.Channel{Id: "mine", Name: "mine"}, nil)
+    for _, grant := range []struct {
+        userID, channelID string
+        allowed           bool
+    }{
+        {"owner", "incidents", true},
+        {"owner", "mine", false},
+        {"intruder", "incidents", false},
+        {"intruder", "mine", true},
+        {"admin", "incidents", true},
+        {"admin", "mine", true},
+    } {
+        api.On("HasPermissionToChannel", grant.userID, grant.channelID, model.PermissionManageChannelRoles).Return(grant.allowed).Maybe()
+    }
+    p := newTestPlugin(t, api)
+    dynamic := func(userID, command string) string {
+        return p.dynamicCommand(&model.CommandArgs{UserId: userID, TeamId: "team"}, "group", strings.Fields(command)).Text
+    }
+
+    require.Equal(t, "Mentioning @oncall now notifies the all of ~incidents", dynamic("owner", "oncall ~incidents all"))
+
+    // An admin of another channel cannot point the group at their channel
+    assert.Equal(t, "Group oncall already exists", dynamic("intruder", "oncall ~mine all"))
+    assert.Equal(t, "incidents", p.dynamicGroups["oncall"].ChannelID)
+    assert.Equal(t, "Only channel admins or the creator can delete this dynamic group", dynamic("intruder", "oncall none"))
+
+    // The creator and admins of the current channel can replace it
+    assert.Equal(t, "Mentioning @oncall now notifies the admins of ~incidents", dynamic("owner", "oncall ~incidents admins"))
+    assert.Equal(t, "Mentioning @oncall now notifies the all of ~mine", dynamic("admin", "oncall ~mine all"))
+    assert.Equal(t, "mine", p.dynamicGroups["oncall"].ChannelID)
+    assert.Equal(t, "admin", p.dynamicGroups["oncall"].CreatedBy)
+
+    // Static groups keep their name
+    require.NoError(t, p.createGroup("dev", nil, "", "creator"))
+    assert.Equal(t, "Group dev already exists", dynamic("intruder", "dev ~mine all"))
+}
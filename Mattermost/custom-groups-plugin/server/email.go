@@ -0,0 +1,63 @@
+package main
+
+import (
+    "html"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// setGroupEmailOffline turns emailing offline members about mentions of a
+// group on or off.
+func (p *Plugin) setGroupEmailOffline(groupName string, enabled bool) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.EmailOffline = enabled
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// emailEnabled reports whether the server sends email notifications at all.
+func (p *Plugin) emailEnabled() bool {
+    config := p.API.GetConfig()
+    return config != nil && config.EmailSettings.SendEmailNotifications != nil && *config.EmailSettings.SendEmailNotifications
+}
+
+// offlineEmail is a group mention notification to email to a member if they
+// are offline.
+type offlineEmail struct {
+    userID  string
+    subject string
+    message string
+}
+
+// emailIfOffline emails group mention notifications to the members who are
+// offline, unless they turned off email notifications.
+func (p *Plugin) emailIfOffline(emails []offlineEmail) {
+    for _, email := range emails {
+        status, appErr := p.API.GetUserStatus(email.userID)
+        if appErr != nil || status.Status != model.StatusOffline {
+            continue
+        }
+
+        user, appErr := p.API.GetUser(email.userID)
+        if appErr != nil || user.Email == "" || user.NotifyProps[model.EmailNotifyProp] == "false" {
+            continue
+        }
+
+        body := strings.ReplaceAll(html.EscapeString(email.message), "\n", "<br>")
+        if appErr := p.API.SendMail(user.Email, email.subject, body); appErr != nil {
+            p.API.LogWarn("Failed to email group mention", "user_id", email.userID, "error", appErr.Error())
+        }
+    }
+}
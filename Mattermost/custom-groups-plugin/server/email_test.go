@@ -0,0 +1,66 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+)
+
+func TestOfflineEmailsAreSentAfterTheHookReturns(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "alice"},
+        &model.User{Id: "u1", Username: "bob", Email: "bob@example.com"},
+        &model.User{Id: "u2", Username: "carol", Email: "carol@example.com"},
+        &model.User{Id: "u3", Username: "dave", Email: "dave@example.com", NotifyProps: model.StringMap{model.EmailNotifyProp: "false"}},
+    )
+    serverConfig := &model.Config{}
+    serverConfig.SetDefaults()
+    serverConfig.EmailSettings.SendEmailNotifications = model.NewBool(true)
+    api.On("GetConfig").Return(serverConfig)
+    expectNotifications(api, "channel")
+    api.On("GetUserStatus", "u1").Return(&model.Status{UserId: "u1", Status: model.StatusOffline}, nil)
+    api.On("GetUserStatus", "u2").Return(&model.Status{UserId: "u2", Status: model.StatusOnline}, nil)
+    api.On("GetUserStatus", "u3").Return(&model.Status{UserId: "u3", Status: model.StatusOffline}, nil)
+
+    release := make(chan struct{})
+    sent := make(chan string, 3)
+    api.On("SendMail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+        <-release
+        sent <- args.String(0)
+    })
+
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("oncall", []string{"u1", "u2", "u3"}, "", "creator"))
+    require.NoError(t, p.setGroupEmailOffline("oncall", true))
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", ChannelId: "channel", Message: "@oncall the site is down"})
+
+    done := make(chan struct{})
+    go func() {
+        p.MessageHasBeenPosted(&plugin.Context{}, post)
+        close(done)
+    }()
+
+    // The hook neither waits for the mail server nor keeps the groups locked
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("MessageHasBeenPosted waited for SendMail")
+    }
+    assert.True(t, p.groupMutex.TryLock())
+    p.groupMutex.Unlock()
+
+    close(release)
+    select {
+    case to := <-sent:
+        assert.Equal(t, "bob@example.com", to)
+    case <-time.After(time.Second):
+        t.Fatal("the offline member was not emailed")
+    }
+    assert.Never(t, func() bool { return len(sent) > 0 }, 100*time.Millisecond, 10*time.Millisecond, "only offline members who allow email are emailed")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+)
+
+var (
+    // ErrGroupNotFound is returned when the named group does not exist.
+    ErrGroupNotFound = errors.New("group not found")
+
+    // ErrGroupExists is returned when creating a group whose name is taken.
+    ErrGroupExists = errors.New("group already exists")
+
+    // ErrUserNotFound is returned when a user cannot be resolved.
+    ErrUserNotFound = errors.New("user not found")
+
+    // ErrAlreadyMember is returned when adding a user who is already in the group.
+    ErrAlreadyMember = errors.New("user already in group")
+
+    // ErrNotMember is returned when removing a user who is not in the group.
+    ErrNotMember = errors.New("user not in group")
+
+    // ErrNotInTrash is returned when restoring a group that is not in the trash.
+    ErrNotInTrash = errors.New("deleted group not found")
+
+    // ErrReservedName is returned when a group would use a reserved name.
+    ErrReservedName = errors.New("group name is reserved")
+
+    // ErrNestingCycle is returned when including a group in another would
+    // make them include each other.
+    ErrNestingCycle = errors.New("groups would include each other")
+
+    // ErrSetNotFound is returned when the named mutually exclusive set does
+    // not exist.
+    ErrSetNotFound = errors.New("exclusive set not found")
+)
+
+// groupError wraps a sentinel error with the group it refers to.
+func groupError(err error, groupName string) error {
+    return fmt.Errorf("%w: %s", err, groupName)
+}
+
+// httpStatusForError maps plugin errors to HTTP status codes.
+func httpStatusForError(err error) int {
+    switch {
+    case errors.Is(err, ErrGroupNotFound), errors.Is(err, ErrUserNotFound), errors.Is(err, ErrNotInTrash):
+        return http.StatusNotFound
+    case errors.Is(err, ErrGroupExists), errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrNotMember), errors.Is(err, ErrReservedName), errors.Is(err, ErrNestingCycle):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+// writeError writes err with the status code matching its sentinel. Errors
+// without a sentinel are logged and reported as a generic failure.
+func (p *Plugin) writeError(w http.ResponseWriter, err error) {
+    status := httpStatusForError(err)
+    if status == http.StatusInternalServerError {
+        p.API.LogError("Request failed", "error", err.Error())
+        http.Error(w, "Failed to save changes", status)
+        return
+    }
+
+    http.Error(w, err.Error(), status)
+}
+
+// commandErrorText maps plugin errors to slash command responses. name is the
+// group or user the error refers to; fallback is used for storage failures.
+func commandErrorText(err error, name, fallback string) string {
+    switch {
+    case errors.Is(err, ErrGroupNotFound):
+        return fmt.Sprintf("Group %s does not exist", name)
+    case errors.Is(err, ErrGroupExists):
+        return fmt.Sprintf("Group %s already exists", name)
+    case errors.Is(err, ErrUserNotFound):
+        return fmt.Sprintf("User %s not found", name)
+    case errors.Is(err, ErrAlreadyMember):
+        return fmt.Sprintf("User is already in group %s", name)
+    case errors.Is(err, ErrNotMember):
+        return fmt.Sprintf("User is not in group %s", name)
+    case errors.Is(err, ErrNotInTrash):
+        return fmt.Sprintf("No deleted group named %s", name)
+    case errors.Is(err, ErrReservedName):
+        return fmt.Sprintf("%s is a reserved name and cannot be used for a group", name)
+    default:
+        return fallback
+    }
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "sync"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing the membership event log in KV store
+    groupEventsKey = "custom_groups_events"
+
+    // Number of events kept in the log; older events are dropped
+    maxGroupEvents = 1000
+
+    // Maximum number of events returned by one request
+    groupEventsPageSize = 200
+)
+
+// Membership event types
+const (
+    EventMemberAdded   = "member_added"
+    EventMemberRemoved = "member_removed"
+)
+
+// GroupEvent is one membership change in the event log.
+type GroupEvent struct {
+    Seq       int64  `json:"seq"` // increases by one per event, never reused
+    Type      string `json:"type"`
+    Group     string `json:"group"`
+    User      string `json:"user"`            // user ID of the member
+    Actor     string `json:"actor,omitempty"` // user ID that made the change; empty if unknown
+    Timestamp int64  `json:"timestamp"`       // milliseconds since epoch
+}
+
+// EventsResponse is a page of the event log returned by the REST API.
+type EventsResponse struct {
+    Events  []*GroupEvent `json:"events"`
+    Cursor  int64         `json:"cursor"`   // pass as since to get the next page
+    HasMore bool          `json:"has_more"` // more events follow the cursor
+    Reset   bool          `json:"reset"`    // events after since were dropped; resync the full state
+}
+
+// eventLog is a bounded, append-only log of membership changes. Events are
+// numbered when they are stored, so a sequence number handed to a client is
+// never reused or changed. The zero value is ready to use.
+type eventLog struct {
+    mutex   sync.Mutex
+    lastSeq int64         // sequence number of the last stored event
+    events  []*GroupEvent // stored events; never modified once here
+    pending []*GroupEvent // logged events waiting to be stored and numbered
+}
+
+// storedEvents is the KV representation of the event log.
+type storedEvents struct {
+    LastSeq int64         `json:"last_seq"`
+    Events  []*GroupEvent `json:"events"`
+}
+
+// memberEvents returns one event of the given type per user.
+func memberEvents(eventType, groupName, actorID string, userIDs []string) []*GroupEvent {
+    events := make([]*GroupEvent, 0, len(userIDs))
+    for _, userID := range userIDs {
+        events = append(events, &GroupEvent{Type: eventType, Group: groupName, User: userID, Actor: actorID})
+    }
+    return events
+}
+
+// append queues events for the next save, which numbers them. Callers must
+// hold the groupMutex write lock so events are logged in the order the
+// changes were applied.
+func (l *eventLog) append(events []*GroupEvent) {
+    if len(events) == 0 {
+        return
+    }
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    now := model.GetMillis()
+    for _, event := range events {
+        event.Timestamp = now
+    }
+    l.pending = append(l.pending, events...)
+}
+
+// merge numbers copies of the pending events to follow the stored log, which
+// is the one in the KV store when another server stored events in the
+// meantime. It returns the new log and its last sequence number, and leaves
+// the log unchanged. Callers must hold the log mutex.
+func (l *eventLog) merge(stored *storedEvents) ([]*GroupEvent, int64) {
+    base, seq := l.events, l.lastSeq
+    if stored.LastSeq > l.lastSeq {
+        base, seq = stored.Events, stored.LastSeq
+    }
+
+    merged := append([]*GroupEvent(nil), base...)
+    for _, event := range l.pending {
+        seq++
+        numbered := *event
+        numbered.Seq = seq
+        merged = append(merged, &numbered)
+    }
+    if overflow := len(merged) - maxGroupEvents; overflow > 0 {
+        merged = merged[overflow:]
+    }
+
+    return merged, seq
+}
+
+// since returns copies of up to limit stored events with a sequence number
+// greater than seq.
+func (l *eventLog) since(seq int64, limit int) *EventsResponse {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    response := &EventsResponse{Events: []*GroupEvent{}, Cursor: seq}
+
+    // The cursor is ahead of the log, e.g. because the log was cleared
+    if seq > l.lastSeq {
+        response.Cursor = l.lastSeq
+        response.Reset = true
+        return response
+    }
+
+    if len(l.events) > 0 && seq < l.events[0].Seq-1 {
+        response.Reset = true
+    }
+
+    for _, event := range l.events {
+        if event.Seq <= seq {
+            continue
+        }
+        if len(response.Events) == limit {
+            response.HasMore = true
+            break
+        }
+        copied := *event
+        response.Events = append(response.Events, &copied)
+        response.Cursor = event.Seq
+    }
+
+    return response
+}
+
+// after returns copies of the events logged after the given time in
+// milliseconds, including those not stored yet, and whether the log still
+// holds every such event.
+func (l *eventLog) after(timestamp int64) ([]*GroupEvent, bool) {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    events := []*GroupEvent{}
+    for _, logged := range [][]*GroupEvent{l.events, l.pending} {
+        for _, event := range logged {
+            if event.Timestamp > timestamp {
+                copied := *event
+                events = append(events, &copied)
+            }
+        }
+    }
+
+    complete := len(l.events) == 0 || l.events[0].Seq == 1 || l.events[0].Timestamp <= timestamp
+    return events, complete
+}
+
+func (p *Plugin) loadGroupEvents() error {
+    data, appErr := p.API.KVGet(groupEventsKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    var stored storedEvents
+    if data != nil {
+        if err := json.Unmarshal(data, &stored); err != nil {
+            return err
+        }
+    }
+
+    p.events.mutex.Lock()
+    p.events.lastSeq = stored.LastSeq
+    p.events.events = stored.Events
+    p.events.mutex.Unlock()
+
+    return nil
+}
+
+// saveGroupEvents persists the event log with compare-and-set. The pending
+// events are numbered after the events stored by any server, so neither
+// server's events are lost, and only become visible once the write
+// succeeds. Like saveBlob it holds storeMutex from the snapshot until the
+// write finishes.
+func (p *Plugin) saveGroupEvents() error {
+    p.storeMutex.Lock()
+    defer p.storeMutex.Unlock()
+
+    for attempt := 0; attempt < indexUpdateAttempts; attempt++ {
+        current, appErr := p.API.KVGet(groupEventsKey)
+        if appErr != nil {
+            return appErr
+        }
+
+        var stored storedEvents
+        if current != nil {
+            if err := json.Unmarshal(current, &stored); err != nil {
+                return err
+            }
+        }
+
+        p.events.mutex.Lock()
+        merged, lastSeq := p.events.merge(&stored)
+        numbered := len(p.events.pending)
+        data, err := json.Marshal(&storedEvents{
+            LastSeq: lastSeq,
+            Events:  merged,
+        })
+        p.events.mutex.Unlock()
+
+        if err != nil {
+            return err
+        }
+
+        ok, appErr := p.API.KVCompareAndSet(groupEventsKey, current, data)
+        if appErr != nil {
+            return appErr
+        }
+        if ok {
+            // Events logged during the write stay pending for the next save
+            p.events.mutex.Lock()
+            p.events.events = merged
+            p.events.lastSeq = lastSeq
+            p.events.pending = append([]*GroupEvent(nil), p.events.pending[numbered:]...)
+            p.events.mutex.Unlock()
+            return nil
+        }
+    }
+
+    return fmt.Errorf("%s changed %d times while being updated", groupEventsKey, indexUpdateAttempts)
+}
+
+// handleGetEvents returns the membership events after the since cursor. Only
+// system admins can read them.
+func (p *Plugin) handleGetEvents(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if !hasReadToken(r) && (userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem)) {
+        http.Error(w, "Only system administrators can read group events", http.StatusForbidden)
+        return
+    }
+
+    var since int64
+    if value := r.URL.Query().Get("since"); value != "" {
+        parsed, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || parsed < 0 {
+            http.Error(w, "Invalid since value", http.StatusBadRequest)
+            return
+        }
+        since = parsed
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(p.events.since(since, groupEventsPageSize))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func eventSeqs(events []*GroupEvent) map[string]int64 {
+    seqs := map[string]int64{}
+    for _, event := range events {
+        seqs[event.User] = event.Seq
+    }
+    return seqs
+}
+
+func TestEventsAreNumberedWhenStored(t *testing.T) {
+    api := newTestAPI(t)
+    setup := newTestPlugin(t, api)
+    require.NoError(t, setup.createGroup("dev", nil, "", "creator"))
+
+    first := loadTestServer(t, api)
+    second := loadTestServer(t, api)
+
+    first.events.append(memberEvents(EventMemberAdded, "dev", "actor", []string{"alice"}))
+    assert.Empty(t, first.events.since(0, groupEventsPageSize).Events, "unsaved events have no number yet")
+    events, _ := first.events.after(0)
+    assert.Contains(t, eventSeqs(events), "alice")
+
+    require.NoError(t, first.saveGroupEvents())
+    seen := first.events.since(0, groupEventsPageSize)
+    require.Equal(t, map[string]int64{"alice": 1}, eventSeqs(seen.Events))
+    assert.Equal(t, int64(1), seen.Cursor)
+
+    // The second server logged its event before seeing the first's
+    second.events.append(memberEvents(EventMemberAdded, "dev", "actor", []string{"bob"}))
+    require.NoError(t, second.saveGroupEvents())
+    assert.Equal(t, map[string]int64{"alice": 1, "bob": 2}, eventSeqs(second.events.since(0, groupEventsPageSize).Events))
+
+    first.events.append(memberEvents(EventMemberRemoved, "dev", "actor", []string{"carol"}))
+    require.NoError(t, first.saveGroupEvents())
+
+    next := first.events.since(seen.Cursor, groupEventsPageSize)
+    assert.Equal(t, map[string]int64{"bob": 2, "carol": 3}, eventSeqs(next.Events))
+    assert.False(t, next.Reset)
+    assert.Equal(t, int64(1), seen.Events[0].Seq, "events handed out are never renumbered")
+
+    // Changing a returned event does not change the log
+    seen.Events[0].User = "mallory"
+    assert.Equal(t, "alice", first.events.since(0, 1).Events[0].User)
+    assert.Equal(t, int64(3), loadTestServer(t, api).events.lastSeq)
+}
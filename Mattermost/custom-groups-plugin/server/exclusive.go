@@ -0,0 +1,251 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing mutually exclusive group sets in KV store
+    exclusiveSetsKey = "custom_groups_exclusive"
+
+    // Name that deletes a set in the exclusive command
+    exclusiveNone = "none"
+)
+
+// ExclusiveRemoval is a membership removed because the user was added to
+// another group of a mutually exclusive set.
+type ExclusiveRemoval struct {
+    User  string `json:"user"`  // username, or the user ID if it cannot be loaded
+    Group string `json:"group"` // group the user was removed from
+
+    userID string
+}
+
+func (p *Plugin) loadExclusiveSets() error {
+    p.groupMutex.Lock()
+    defer p.groupMutex.Unlock()
+
+    p.exclusiveSets = make(map[string][]string)
+
+    data, appErr := p.API.KVGet(exclusiveSetsKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    if data != nil {
+        if err := json.Unmarshal(data, &p.exclusiveSets); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (p *Plugin) saveExclusiveSets() error {
+    return p.saveBlob(exclusiveSetsKey, func() interface{} { return p.exclusiveSets })
+}
+
+// enforceExclusive removes users just added to a group from the other groups
+// of every mutually exclusive set the group belongs to. Callers must hold the
+// groupMutex write lock and persist the group state afterwards.
+func (p *Plugin) enforceExclusive(groupName string, userIDs []string, actorID string) []ExclusiveRemoval {
+    removals := []ExclusiveRemoval{}
+    if len(userIDs) == 0 {
+        return removals
+    }
+
+    for _, setName := range sortedKeys(p.exclusiveSets) {
+        groupNames := p.exclusiveSets[setName]
+        if !contains(groupNames, groupName) {
+            continue
+        }
+
+        for _, other := range groupNames {
+            members, exists := p.groups[other]
+            if other == groupName || !exists {
+                continue
+            }
+
+            kept := []string{}
+            removed := []string{}
+            for _, memberID := range members {
+                if contains(userIDs, memberID) {
+                    removed = append(removed, memberID)
+                } else {
+                    kept = append(kept, memberID)
+                }
+            }
+            if len(removed) == 0 {
+                continue
+            }
+
+            p.groups[other] = kept
+            p.touchGroup(other)
+            p.events.append(memberEvents(EventMemberRemoved, other, actorID, removed))
+            p.forgetJoins(other, removed)
+            for _, userID := range removed {
+                removals = append(removals, ExclusiveRemoval{Group: other, userID: userID})
+            }
+        }
+    }
+
+    return removals
+}
+
+// resolveRemovals fills in the usernames of the removed users.
+func (p *Plugin) resolveRemovals(removals []ExclusiveRemoval) []ExclusiveRemoval {
+    for i := range removals {
+        removals[i].User = removals[i].userID
+        if user, appErr := p.API.GetUser(removals[i].userID); appErr == nil {
+            removals[i].User = user.Username
+        }
+    }
+    return removals
+}
+
+// exclusiveRemovalText reports the automatic removals, or returns an empty
+// string when there were none.
+func exclusiveRemovalText(removals []ExclusiveRemoval) string {
+    if len(removals) == 0 {
+        return ""
+    }
+
+    parts := make([]string, 0, len(removals))
+    for _, removal := range removals {
+        parts = append(parts, fmt.Sprintf("@%s from %s", removal.User, removal.Group))
+    }
+    return "Removed from mutually exclusive groups: " + strings.Join(parts, ", ")
+}
+
+func sortedKeys(sets map[string][]string) []string {
+    keys := make([]string, 0, len(sets))
+    for key := range sets {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// setExclusiveSet defines a mutually exclusive set of existing groups, or
+// deletes it when groupNames is empty. It returns the users who are already
+// in more than one of the groups, which are left as they are.
+func (p *Plugin) setExclusiveSet(setName string, groupNames []string) ([]string, error) {
+    p.groupMutex.Lock()
+    overlapping := []string{}
+    if len(groupNames) == 0 {
+        if _, exists := p.exclusiveSets[setName]; !exists {
+            p.groupMutex.Unlock()
+            return nil, fmt.Errorf("%w: %s", ErrSetNotFound, setName)
+        }
+        delete(p.exclusiveSets, setName)
+    } else {
+        seen := map[string]bool{}
+        for _, groupName := range groupNames {
+            members, exists := p.groups[groupName]
+            if !exists {
+                p.groupMutex.Unlock()
+                return nil, groupError(ErrGroupNotFound, groupName)
+            }
+            for _, userID := range members {
+                if seen[userID] && !contains(overlapping, userID) {
+                    overlapping = append(overlapping, userID)
+                }
+                seen[userID] = true
+            }
+        }
+        p.exclusiveSets[setName] = groupNames
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return overlapping, p.saveExclusiveSets()
+}
+
+// exclusiveCommand lists, defines or deletes mutually exclusive group sets.
+// Only system admins can use it.
+func (p *Plugin) exclusiveCommand(userID, trigger string, args []string) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can manage mutually exclusive groups",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(args) == 0 {
+        p.groupMutex.RLock()
+        defer p.groupMutex.RUnlock()
+
+        if len(p.exclusiveSets) == 0 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("No mutually exclusive sets exist. Define one with `/%s exclusive set_name group_name group_name...`", trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+
+        var text strings.Builder
+        text.WriteString("Mutually exclusive sets:\n")
+        for _, setName := range sortedKeys(p.exclusiveSets) {
+            text.WriteString(fmt.Sprintf("- **%s**: %s\n", setName, strings.Join(p.exclusiveSets[setName], ", ")))
+        }
+        return &model.CommandResponse{
+            Text: text.String(),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    setName := args[0]
+    groupNames := []string{}
+    for _, groupName := range args[1:] {
+        groupName = canonicalGroupName(strings.TrimPrefix(groupName, "@"))
+        if !contains(groupNames, groupName) {
+            groupNames = append(groupNames, groupName)
+        }
+    }
+
+    if len(groupNames) == 1 && groupNames[0] == exclusiveNone {
+        if _, err := p.setExclusiveSet(setName, nil); err != nil {
+            text := "Failed to save changes"
+            if errors.Is(err, ErrSetNotFound) {
+                text = fmt.Sprintf("Set %s does not exist", setName)
+            }
+            return &model.CommandResponse{
+                Text: text,
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Deleted mutually exclusive set %s", setName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(groupNames) < 2 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a set name and at least two groups: `/%s exclusive set_name group_name group_name...` or `/%s exclusive set_name none`", trigger, trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    overlapping, err := p.setExclusiveSet(setName, groupNames)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Failed to save set %s: %v", setName, err),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    text := fmt.Sprintf("Users can now be in only one of %s. Adding a user to one removes them from the others.", strings.Join(groupNames, ", "))
+    if len(overlapping) > 0 {
+        text += fmt.Sprintf("\n%d users are already in more than one of them and stay until they are added again: @%s", len(overlapping), strings.Join(p.usernames(overlapping), ", @"))
+    }
+    return &model.CommandResponse{
+        Text: text,
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Channel members added at most for a channel-wide mention
+const maxFromPostMembers = 1000
+
+// atMentionPattern matches @mentions with the characters usernames may use.
+var atMentionPattern = regexp.MustCompile(`@([A-Za-z0-9._-]+)`)
+
+// postAudience returns the IDs of the users a post mentioned: users mentioned
+// by name, members of expanded groups and, for @channel, @all and @here, the
+// channel's current members.
+func (p *Plugin) postAudience(post *model.Post) ([]string, error) {
+    userIDs := []string{}
+    add := func(userID string) {
+        if userID != post.UserId && !contains(userIDs, userID) {
+            userIDs = append(userIDs, userID)
+        }
+    }
+
+    channelWide := false
+    for _, match := range atMentionPattern.FindAllStringSubmatch(post.Message, -1) {
+        name := strings.ToLower(strings.TrimRight(match[1], "."))
+        switch name {
+        case "channel", "all", "here":
+            channelWide = true
+            continue
+        }
+        if user, appErr := p.API.GetUserByUsername(name); appErr == nil && !user.IsBot {
+            add(user.Id)
+        }
+    }
+
+    if groupMentions, ok := post.Props["group_mentions"].([]interface{}); ok {
+        for _, mention := range groupMentions {
+            if mentionProps, ok := mention.(map[string]interface{}); ok {
+                members, _ := mentionMembers(mentionProps["members"])
+                for _, userID := range members {
+                    add(userID)
+                }
+            }
+        }
+    }
+
+    if channelWide {
+        for page := 0; len(userIDs) < maxFromPostMembers; page++ {
+            channelMembers, appErr := p.API.GetChannelMembers(post.ChannelId, page, dynamicGroupPageSize)
+            if appErr != nil {
+                return nil, appErr
+            }
+            for _, member := range channelMembers {
+                add(member.UserId)
+            }
+            if len(channelMembers) < dynamicGroupPageSize {
+                break
+            }
+        }
+        if len(userIDs) > maxFromPostMembers {
+            userIDs = userIDs[:maxFromPostMembers]
+        }
+    }
+
+    return userIDs, nil
+}
+
+// fromPostCommand shows the audience of a post, or saves it as a new group
+// when a group name is given. Only system admins can use it.
+func (p *Plugin) fromPostCommand(userID, trigger string, args []string) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can create groups from posts",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(args) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a post ID: `/%s from-post post_id [group_name]`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    postID := args[0]
+
+    post, appErr := p.API.GetPost(postID)
+    if appErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Post %s not found", postID),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    userIDs, err := p.postAudience(post)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: "Failed to load the channel members",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(userIDs) == 0 {
+        return &model.CommandResponse{
+            Text: "The post does not mention any users",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(args) < 2 {
+        // Only the listed users are resolved to usernames
+        shown := userIDs
+        if len(shown) > importPreviewSize {
+            shown = shown[:importPreviewSize]
+        }
+        preview := strings.Join(p.usernames(shown), ", ")
+        if len(userIDs) > len(shown) {
+            preview += fmt.Sprintf(" and %d more", len(userIDs)-len(shown))
+        }
+
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("The post mentions %d users: %s\nRun `/%s from-post %s group_name` to save them as a new group.",
+                len(userIDs), preview, trigger, postID),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := canonicalGroupName(args[1])
+
+    if err := p.createGroup(groupName, userIDs, "", userID); err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save group"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("Created group %s with the %d users mentioned in the post", groupName, len(userIDs)),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+)
+
+// expectAnyCommandCalls lets every API call a command can make succeed with
+// an empty result or fail with not found, so fuzzed commands reach their
+// parsers whatever they ask for.
+func expectAnyCommandCalls(api *testAPI) {
+    notFound := model.NewAppError("fuzz", "not_found", nil, "", 404)
+    serverConfig := &model.Config{}
+    serverConfig.SetDefaults()
+
+    api.On("GetUser", mock.Anything).Return(&model.User{Id: "fuzzer", Username: "fuzzer", Roles: model.SystemUserRoleId}, nil).Maybe()
+    api.On("GetUserByUsername", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetUserByEmail", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("SearchUsers", mock.Anything).Return([]*model.User{}, nil).Maybe()
+    api.On("GetUsersInChannel", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*model.User{}, nil).Maybe()
+    api.On("GetUserStatus", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetUserStatusesByIds", mock.Anything).Return([]*model.Status{}, nil).Maybe()
+    api.On("HasPermissionTo", mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("HasPermissionToTeam", mock.Anything, mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("HasPermissionToChannel", mock.Anything, mock.Anything, mock.Anything).Return(true).Maybe()
+    api.On("GetTeamsForUser", mock.Anything).Return([]*model.Team{}, nil).Maybe()
+    api.On("GetTeam", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetChannel", mock.Anything).Return(&model.Channel{Id: "channel", TeamId: "team", Type: model.ChannelTypeOpen, Name: "town-square"}, nil).Maybe()
+    api.On("GetChannelByName", mock.Anything, mock.Anything, mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetChannelMember", mock.Anything, mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetChannelMembers", mock.Anything, mock.Anything, mock.Anything).Return(model.ChannelMembers{}, nil).Maybe()
+    api.On("GetChannelStats", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetDirectChannel", mock.Anything, mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetPost", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetPostsForChannel", mock.Anything, mock.Anything, mock.Anything).Return(model.NewPostList(), nil).Maybe()
+    api.On("CreatePost", mock.Anything).Return(&model.Post{}, nil).Maybe()
+    api.On("SendEphemeralPost", mock.Anything, mock.Anything).Return(&model.Post{}).Maybe()
+    api.On("SendMail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+    api.On("GetFileInfo", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetFile", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("UploadFile", mock.Anything, mock.Anything, mock.Anything).Return(&model.FileInfo{}, nil).Maybe()
+    api.On("GetSession", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("GetConfig").Return(serverConfig).Maybe()
+}
+
+func FuzzExecuteCommand(f *testing.F) {
+    f.Add("/group create team alice")
+    f.Add("/group import team --json alice,bob")
+
+    f.Fuzz(func(t *testing.T, command string) {
+        api := newTestAPI(t)
+        expectAnyCommandCalls(api)
+        p := newTestPlugin(t, api)
+
+        response, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{
+            UserId:    "fuzzer",
+            ChannelId: "channel",
+            TeamId:    "team",
+            Command:   command,
+        })
+        assert.True(t, response != nil || appErr != nil, "command %q got no response", command)
+    })
+}
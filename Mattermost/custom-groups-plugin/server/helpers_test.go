@@ -0,0 +1,145 @@
+package main
+
+import (
+    "bytes"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/mattermost/mattermost-server/v6/plugin/plugintest"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// testAPI is a plugintest.API mock backed by an in-memory KV store. Log
+// calls are accepted without expectations; other calls need them.
+type testAPI struct {
+    *plugintest.API
+
+    kvMutex sync.Mutex
+    kv      map[string][]byte
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+    api := &testAPI{API: &plugintest.API{}, kv: make(map[string][]byte)}
+    t.Cleanup(func() { api.AssertExpectations(t) })
+    return api
+}
+
+func (a *testAPI) KVGet(key string) ([]byte, *model.AppError) {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    return a.kv[key], nil
+}
+
+func (a *testAPI) KVSet(key string, value []byte) *model.AppError {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    a.kv[key] = append([]byte(nil), value...)
+    return nil
+}
+
+func (a *testAPI) KVDelete(key string) *model.AppError {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+    delete(a.kv, key)
+    return nil
+}
+
+func (a *testAPI) KVCompareAndSet(key string, oldValue, newValue []byte) (bool, *model.AppError) {
+    a.kvMutex.Lock()
+    defer a.kvMutex.Unlock()
+
+    current, exists := a.kv[key]
+    if (oldValue == nil && exists) || (oldValue != nil && !bytes.Equal(current, oldValue)) {
+        return false, nil
+    }
+    a.kv[key] = append([]byte(nil), newValue...)
+    return true, nil
+}
+
+func (a *testAPI) LogDebug(string, ...interface{}) {}
+func (a *testAPI) LogInfo(string, ...interface{})  {}
+func (a *testAPI) LogWarn(string, ...interface{})  {}
+func (a *testAPI) LogError(string, ...interface{}) {}
+
+// newTestPlugin returns a plugin with empty state using api and the
+// processed default configuration.
+func newTestPlugin(t *testing.T, api *testAPI) *Plugin {
+    settings := config.DefaultConfiguration()
+    require.NoError(t, settings.ProcessConfiguration())
+    config.SetConfig(settings)
+    t.Cleanup(func() { config.SetConfig(nil) })
+
+    p := &Plugin{
+        groups:         make(map[string][]string),
+        groupMetadata:  make(map[string]*GroupMetadata),
+        groupTrash:     make(map[string]*DeletedGroup),
+        dynamicGroups:  make(map[string]*DynamicGroup),
+        channelSnoozes: make(map[string]int64),
+        exclusiveSets:  make(map[string][]string),
+    }
+    p.SetAPI(api)
+    return p
+}
+
+// expectUsers lets GetUser return each of the users.
+func expectUsers(api *testAPI, users ...*model.User) {
+    for _, user := range users {
+        api.On("GetUser", user.Id).Return(user, nil).Maybe()
+    }
+}
+
+// expectNotifications sets up the calls made while notifying group members
+// about a post in an open channel whose members have not muted it.
+func expectNotifications(api *testAPI, channelID string) {
+    api.On("GetChannel", channelID).Return(&model.Channel{Id: channelID, Name: "town-square", Type: model.ChannelTypeOpen}, nil).Maybe()
+    api.On("GetChannelMember", channelID, mock.Anything).Return(nil, model.NewAppError("GetChannelMember", "not_found", nil, "", http.StatusNotFound)).Maybe()
+    api.On("GetConfig").Return(&model.Config{}).Maybe()
+    api.On("SendEphemeralPost", mock.Anything, mock.Anything).Return(&model.Post{}).Maybe()
+}
+
+// ephemeralRecipients returns the users sent ephemeral posts, in order.
+func ephemeralRecipients(api *testAPI) []string {
+    recipients := []string{}
+    for _, call := range api.Calls {
+        if call.Method == "SendEphemeralPost" {
+            recipients = append(recipients, call.Arguments.String(0))
+        }
+    }
+    return recipients
+}
+
+// expectViewer sets up the session of a user who is a member of the channel.
+func expectViewer(api *testAPI, sessionID, userID, channelID string) {
+    api.On("GetSession", sessionID).Return(&model.Session{Id: sessionID, UserId: userID}, nil).Maybe()
+    api.On("GetChannelMember", channelID, userID).Return(&model.ChannelMember{ChannelId: channelID, UserId: userID}, nil).Maybe()
+}
+
+// suggestionNames returns the group names of autocomplete suggestions.
+func suggestionNames(suggestions []*model.User) []string {
+    names := []string{}
+    for _, suggestion := range suggestions {
+        names = append(names, suggestion.Username)
+    }
+    return names
+}
+
+// serveRequest sends a request by the user to the plugin's HTTP API, with the
+// extra headers given as name, value pairs.
+func serveRequest(p *Plugin, method, path, userID, body string, headers ...string) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(method, path, strings.NewReader(body))
+    r.Header.Set("Mattermost-User-Id", userID)
+    for i := 0; i+1 < len(headers); i += 2 {
+        r.Header.Set(headers[i], headers[i+1])
+    }
+    w := httptest.NewRecorder()
+    p.ServeHTTP(&plugin.Context{}, w, r)
+    return w
+}
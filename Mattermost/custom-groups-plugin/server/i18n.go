@@ -0,0 +1,72 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+const (
+    // Locale used when a string has no translation for the user's locale
+    defaultLocale = "en"
+)
+
+// catalog holds the user-facing strings keyed by locale and message ID.
+// Translations are added by creating another locale entry with the same IDs.
+var catalog = map[string]map[string]string{
+    "en": {
+        "help":            "Available commands: create, add, addchannel, remove, list, info, color, pin, unpin, priority, email, tag, schedule, status, dynamic, push, from-post, template, leave-all, rename-bulk, delete, trash, restore, export, import, import-preview, doctor, blast, dedupe, snooze, audit-export, exclusive, similar, merge",
+        "unknown_command": "Unknown command. Available commands: create, add, addchannel, remove, list, info, color, pin, unpin, priority, email, tag, schedule, status, dynamic, push, from-post, template, leave-all, rename-bulk, delete, trash, restore, export, import, import-preview, doctor, blast, dedupe, snooze, audit-export, exclusive, similar, merge",
+
+        "notification.mention":       "You were mentioned in group @%s by @%s in ~%s\nGroup members: %s",
+        "notification.limit_notice":  "This post mentions %s with %d members. Individual notifications were skipped because they exceed the limit of %d per post.",
+        "notification.limit_warning": "Your post would notify %d users, which exceeds the limit of %d notifications per post. Members were not pinged individually.",
+        "notification.groups":        "%d groups",
+        "notification.permalink":     "[Jump to message](%s)",
+        "notification.email_subject": "You were mentioned in group @%s",
+        "notification.unknown_group": "No group named @%s; did you mean @%s?",
+
+        "alert.mention_rate": "Group @%s was mentioned %d times in the last %d minutes, most recently by @%s in ~%s.",
+    },
+}
+
+// translate returns the message for the locale, falling back to the base
+// language (e.g. "pt" for "pt-BR") and then to English. Arguments are
+// formatted into the message.
+func translate(locale, id string, args ...interface{}) string {
+    message, ok := lookupMessage(locale, id)
+    if !ok {
+        if i := strings.IndexAny(locale, "-_"); i > 0 {
+            message, ok = lookupMessage(locale[:i], id)
+        }
+    }
+    if !ok {
+        message, ok = lookupMessage(defaultLocale, id)
+    }
+    if !ok {
+        return id
+    }
+
+    if len(args) > 0 {
+        return fmt.Sprintf(message, args...)
+    }
+    return message
+}
+
+func lookupMessage(locale, id string) (string, bool) {
+    messages, ok := catalog[strings.ToLower(locale)]
+    if !ok {
+        return "", false
+    }
+    message, ok := messages[id]
+    return message, ok
+}
+
+// userLocale returns the locale configured for the user, or the default
+// locale when the user cannot be loaded.
+func (p *Plugin) userLocale(userID string) string {
+    user, appErr := p.API.GetUser(userID)
+    if appErr != nil || user.Locale == "" {
+        return defaultLocale
+    }
+    return user.Locale
+}
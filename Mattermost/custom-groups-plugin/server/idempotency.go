@@ -0,0 +1,169 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    // Header carrying the client's idempotency key on mutating requests
+    idempotencyKeyHeader = "Idempotency-Key"
+
+    // How long the result of a request is replayed for retries with its key
+    idempotencyTTL = 10 * time.Minute
+)
+
+// idempotentResponse is a recorded response replayed for retries.
+type idempotentResponse struct {
+    request     string // hash of the query and body of the request
+    status      int
+    contentType string
+    body        []byte
+    expires     time.Time
+    done        bool // false while the first request is still running
+}
+
+// idempotencyCache remembers the responses of mutating requests by key. The
+// zero value is ready to use.
+type idempotencyCache struct {
+    mu        sync.Mutex
+    responses map[string]*idempotentResponse
+}
+
+// begin returns the recorded response for the key, or reserves the key for
+// the request with the given hash and returns nil when the request should
+// run. inProgress is true when another request with the key is still
+// running, and mismatch when the key was used for a different request.
+func (c *idempotencyCache) begin(key, request string, now time.Time) (response *idempotentResponse, inProgress, mismatch bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.responses == nil {
+        c.responses = make(map[string]*idempotentResponse)
+    }
+
+    for k, r := range c.responses {
+        if r.done && now.After(r.expires) {
+            delete(c.responses, k)
+        }
+    }
+
+    if existing, ok := c.responses[key]; ok {
+        if existing.request != request {
+            return nil, false, true
+        }
+        if !existing.done {
+            return nil, true, false
+        }
+        return existing, false, false
+    }
+
+    c.responses[key] = &idempotentResponse{request: request}
+    return nil, false, false
+}
+
+// finish records the response for the key. Server errors are not recorded so
+// the request can be retried.
+func (c *idempotencyCache) finish(key, request string, recorder *responseRecorder, now time.Time) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if recorder.status >= http.StatusInternalServerError {
+        delete(c.responses, key)
+        return
+    }
+
+    c.responses[key] = &idempotentResponse{
+        request:     request,
+        status:      recorder.status,
+        contentType: recorder.Header().Get("Content-Type"),
+        body:        recorder.body.Bytes(),
+        expires:     now.Add(idempotencyTTL),
+        done:        true,
+    }
+}
+
+// responseRecorder passes a response through while keeping a copy.
+type responseRecorder struct {
+    http.ResponseWriter
+    status int
+    body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+    r.status = status
+    r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+    if r.status == 0 {
+        r.status = http.StatusOK
+    }
+    r.body.Write(data)
+    return r.ResponseWriter.Write(data)
+}
+
+// withIdempotency runs a mutating request once per Idempotency-Key and user,
+// replaying the recorded response for retries. A key reused with a different
+// query or body is rejected rather than replayed. Requests without the
+// header run normally.
+func (p *Plugin) withIdempotency(w http.ResponseWriter, r *http.Request, handler func(http.ResponseWriter, *http.Request)) {
+    key := r.Header.Get(idempotencyKeyHeader)
+    if key == "" || r.Method == http.MethodGet {
+        handler(w, r)
+        return
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    r.Body = io.NopCloser(bytes.NewReader(body))
+    request := requestHash(r.URL.RawQuery, body)
+
+    key = r.Header.Get("Mattermost-User-Id") + " " + r.Method + " " + r.URL.Path + " " + key
+    response, inProgress, mismatch := p.idempotency.begin(key, request, time.Now())
+    if mismatch {
+        http.Error(w, "The idempotency key was already used for a different request", http.StatusUnprocessableEntity)
+        return
+    }
+    if inProgress {
+        http.Error(w, "A request with this idempotency key is in progress", http.StatusConflict)
+        return
+    }
+
+    if response != nil {
+        atomic.AddInt64(&p.metrics.idempotencyHits, 1)
+        if response.contentType != "" {
+            w.Header().Set("Content-Type", response.contentType)
+        }
+        w.Header().Set("Idempotent-Replayed", "true")
+        w.WriteHeader(response.status)
+        w.Write(response.body)
+        return
+    }
+    atomic.AddInt64(&p.metrics.idempotencyMisses, 1)
+
+    recorder := &responseRecorder{ResponseWriter: w}
+    handler(recorder, r)
+    if recorder.status == 0 {
+        recorder.status = http.StatusOK
+    }
+    p.idempotency.finish(key, request, recorder, time.Now())
+}
+
+// requestHash identifies the parameters of a request by its query and body.
+func requestHash(query string, body []byte) string {
+    hash := sha256.New()
+    hash.Write([]byte(query))
+    hash.Write([]byte{0})
+    hash.Write(body)
+    return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeyReplaysRetries(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "admin"}, &model.User{Id: "other"})
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", nil, "", "creator"))
+    addMember := `{"group_name": "dev", "user_id": "u1"}`
+
+    first := serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", addMember, idempotencyKeyHeader, "retry-1")
+    require.Equal(t, http.StatusOK, first.Code)
+    assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+    retry := serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", addMember, idempotencyKeyHeader, "retry-1")
+    assert.Equal(t, http.StatusOK, retry.Code)
+    assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+    assert.Equal(t, []string{"u1"}, p.groups["dev"])
+
+    // Another key, or the same key from another user, runs the request again
+    for _, w := range []*httptest.ResponseRecorder{
+        serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", addMember, idempotencyKeyHeader, "retry-2"),
+        serveRequest(p, http.MethodPost, "/api/v4/groups/members", "other", addMember, idempotencyKeyHeader, "retry-1"),
+        serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", addMember),
+    } {
+        assert.NotEqual(t, http.StatusOK, w.Code)
+        assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+    }
+    assert.Equal(t, []string{"u1"}, p.groups["dev"])
+}
+
+func TestIdempotencyCache(t *testing.T) {
+    var cache idempotencyCache
+    now := time.Now()
+
+    response, inProgress, mismatch := cache.begin("key", "request", now)
+    assert.Nil(t, response)
+    assert.False(t, inProgress)
+    assert.False(t, mismatch)
+
+    _, inProgress, _ = cache.begin("key", "request", now)
+    assert.True(t, inProgress, "a retry while the first request runs must not run it again")
+
+    recorder := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+    recorder.Header().Set("Content-Type", "application/json")
+    recorder.WriteHeader(http.StatusCreated)
+    recorder.Write([]byte(`{"ok":true}`))
+    cache.finish("key", "request", recorder, now)
+
+    response, _, _ = cache.begin("key", "request", now.Add(idempotencyTTL-time.Second))
+    require.NotNil(t, response)
+    assert.Equal(t, http.StatusCreated, response.status)
+    assert.Equal(t, "application/json", response.contentType)
+    assert.Equal(t, `{"ok":true}`, string(response.body))
+
+    // A different request with the key is not replayed
+    response, _, mismatch = cache.begin("key", "other request", now)
+    assert.Nil(t, response)
+    assert.True(t, mismatch)
+
+    // Keys expire after the TTL
+    response, inProgress, mismatch = cache.begin("key", "other request", now.Add(idempotencyTTL+time.Second))
+    assert.Nil(t, response)
+    assert.False(t, inProgress)
+    assert.False(t, mismatch)
+
+    // Server errors are not recorded so the request can be retried
+    cache.begin("failed", "request", now)
+    failed := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+    failed.WriteHeader(http.StatusInternalServerError)
+    cache.finish("failed", "request", failed, now)
+    response, inProgress, _ = cache.begin("failed", "request", now)
+    assert.Nil(t, response)
+    assert.False(t, inProgress)
+}
+
+func TestIdempotencyKeyRejectsDifferentRequests(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "admin"})
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", nil, "", "creator"))
+
+    first := serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", `{"group_name": "dev", "user_id": "u1"}`, idempotencyKeyHeader, "add")
+    require.Equal(t, http.StatusOK, first.Code)
+
+    for _, w := range []*httptest.ResponseRecorder{
+        serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", `{"group_name": "dev", "user_id": "u2"}`, idempotencyKeyHeader, "add"),
+        serveRequest(p, http.MethodPost, "/api/v4/groups/members?notify=false", "admin", `{"group_name": "dev", "user_id": "u1"}`, idempotencyKeyHeader, "add"),
+    } {
+        assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+        assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+    }
+    assert.Equal(t, []string{"u1"}, p.groups["dev"])
+
+    retry := serveRequest(p, http.MethodPost, "/api/v4/groups/members", "admin", `{"group_name": "dev", "user_id": "u1"}`, idempotencyKeyHeader, "add")
+    assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+}
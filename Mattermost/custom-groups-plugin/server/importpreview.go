@@ -0,0 +1,115 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Largest roster file the import preview reads
+const maxRosterFileSize = 1024 * 1024
+
+// ImportPreview is the outcome an import of a roster file would have.
+type ImportPreview struct {
+    Group      string   `json:"group"`
+    Added      []string `json:"added"`      // usernames that would be added
+    Existing   []string `json:"existing"`   // usernames already in the group
+    Unresolved []string `json:"unresolved"` // usernames and emails that match no user
+}
+
+// canReadFile reports whether the user uploaded the file or can read the
+// channel it was posted in.
+func (p *Plugin) canReadFile(userID string, info *model.FileInfo) bool {
+    if info.CreatorId == userID {
+        return true
+    }
+
+    if info.PostId == "" {
+        return false
+    }
+    post, appErr := p.API.GetPost(info.PostId)
+    if appErr != nil {
+        return false
+    }
+    return p.API.HasPermissionToChannel(userID, post.ChannelId, model.PermissionReadChannel)
+}
+
+// previewRosterImport resolves the usernames and emails of a roster file and
+// reports what importing it into the group would do, without changing it.
+func (p *Plugin) previewRosterImport(userID, groupName, fileID string) (*ImportPreview, error) {
+    info, appErr := p.API.GetFileInfo(fileID)
+    if appErr != nil || !p.canReadFile(userID, info) {
+        return nil, fmt.Errorf("file %s not found", fileID)
+    }
+    if info.Size > maxRosterFileSize {
+        return nil, fmt.Errorf("file %s is larger than %d KB", info.Name, maxRosterFileSize/1024)
+    }
+
+    data, appErr := p.API.GetFile(fileID)
+    if appErr != nil {
+        return nil, fmt.Errorf("failed to read file %s", info.Name)
+    }
+
+    entries, err := parseMembersCSV(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("invalid CSV data: %v", err)
+    }
+
+    preview := &ImportPreview{Group: groupName, Unresolved: []string{}}
+
+    // Emails are resolved here; usernames are resolved by planImport
+    usernames := []string{}
+    for _, entry := range entries {
+        if !strings.Contains(entry, "@") {
+            usernames = append(usernames, entry)
+            continue
+        }
+        user, appErr := p.API.GetUserByEmail(entry)
+        if appErr != nil {
+            preview.Unresolved = append(preview.Unresolved, entry)
+            continue
+        }
+        usernames = append(usernames, user.Username)
+    }
+
+    result, _, err := p.planImport(groupName, usernames)
+    if err != nil {
+        return nil, err
+    }
+
+    preview.Added = result.Added
+    preview.Existing = result.Skipped
+    preview.Unresolved = append(preview.Unresolved, result.Errors...)
+    return preview, nil
+}
+
+// importPreviewCommand shows who importing a roster file would add.
+func (p *Plugin) importPreviewCommand(userID, trigger string, args []string, asJSON bool) *model.CommandResponse {
+    if len(args) < 2 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name and file ID: `/%s import-preview group_name file_id [--json]`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := args[0]
+
+    preview, err := p.previewRosterImport(userID, groupName, args[1])
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, err.Error()),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if asJSON {
+        return jsonResponse(preview)
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("Importing this file into group %s would add %d members, %d are already members and %d cannot be resolved. Nothing was changed.\n\n**Would be added:** %s\n**Already members:** %s\n**Not found:** %s",
+            groupName, len(preview.Added), len(preview.Existing), len(preview.Unresolved),
+            previewList(preview.Added), previewList(preview.Existing), previewList(preview.Unresolved)),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
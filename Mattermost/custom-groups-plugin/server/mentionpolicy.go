@@ -0,0 +1,139 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+const (
+    // Largest mention policy response read
+    maxMentionPolicyResponseSize = 64 * 1024
+
+    // Mention policy requests made at once for one post
+    maxConcurrentPolicyChecks = 8
+)
+
+// MentionPolicyRequest is sent to the mention policy URL before the members
+// of a mentioned group are notified.
+type MentionPolicyRequest struct {
+    Group   string `json:"group"`
+    Author  string `json:"author"`  // user ID of the post author
+    Channel string `json:"channel"` // ID of the channel the post is in
+}
+
+// MentionPolicyResponse is the answer of the mention policy URL.
+type MentionPolicyResponse struct {
+    Allow  bool   `json:"allow"`
+    Reason string `json:"reason,omitempty"`
+}
+
+// checkMentionPolicy asks the mention policy URL whether the group's members
+// may be notified about the post. A 403 or {"allow": false} denies it.
+func (p *Plugin) checkMentionPolicy(url string, timeout time.Duration, groupName string, post *model.Post) (*MentionPolicyResponse, error) {
+    body, err := json.Marshal(&MentionPolicyRequest{
+        Group:   groupName,
+        Author:  post.UserId,
+        Channel: post.ChannelId,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    client := &http.Client{Timeout: timeout}
+    resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxMentionPolicyResponseSize))
+    if err != nil {
+        return nil, err
+    }
+
+    var decision MentionPolicyResponse
+    switch resp.StatusCode {
+    case http.StatusOK:
+        if err := json.Unmarshal(data, &decision); err != nil {
+            return nil, fmt.Errorf("invalid response: %v", err)
+        }
+    case http.StatusForbidden:
+        decision.Reason = string(bytes.TrimSpace(data))
+    default:
+        return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+    }
+
+    return &decision, nil
+}
+
+// mentionAllowed reports whether the members of a group mentioned in the post
+// should be notified. Without a mention policy URL every mention is allowed;
+// when the policy cannot be checked, MentionPolicyFailClosed decides.
+func (p *Plugin) mentionAllowed(groupName string, post *model.Post) bool {
+    configuration := config.GetConfig()
+    if configuration.MentionPolicyURL == "" {
+        return true
+    }
+
+    timeout := time.Duration(configuration.MentionPolicyTimeout) * time.Second
+    decision, err := p.checkMentionPolicy(configuration.MentionPolicyURL, timeout, groupName, post)
+    if err != nil {
+        p.API.LogWarn("Mention policy check failed", "group", groupName, "post_id", post.Id, "fail_closed", configuration.MentionPolicyFailClosed, "error", err.Error())
+        return !configuration.MentionPolicyFailClosed
+    }
+
+    if !decision.Allow {
+        p.API.LogInfo("Mention policy denied group notification", "group", groupName, "post_id", post.Id, "reason", decision.Reason)
+    }
+    return decision.Allow
+}
+
+// allowedMentions reports which of the groups mentioned in the post should
+// notify their members. The groups are checked concurrently, so a slow policy
+// URL delays the post's notifications by about one timeout however many
+// groups it mentions. Callers must not hold groupMutex.
+func (p *Plugin) allowedMentions(groupNames []string, post *model.Post) map[string]bool {
+    allowed := make(map[string]bool, len(groupNames))
+    if config.GetConfig().MentionPolicyURL == "" {
+        for _, groupName := range groupNames {
+            allowed[groupName] = true
+        }
+        return allowed
+    }
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    slots := make(chan struct{}, maxConcurrentPolicyChecks)
+    seen := make(map[string]bool, len(groupNames))
+    for _, groupName := range groupNames {
+        if seen[groupName] {
+            continue
+        }
+        seen[groupName] = true
+
+        wg.Add(1)
+        slots <- struct{}{}
+        go func(groupName string) {
+            defer wg.Done()
+            defer func() { <-slots }()
+
+            ok := p.mentionAllowed(groupName, post)
+            mu.Lock()
+            allowed[groupName] = ok
+            mu.Unlock()
+        }(groupName)
+    }
+    wg.Wait()
+
+    return allowed
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// usePolicyServer points the mention policy URL at a test server calling
+// handler.
+func usePolicyServer(t *testing.T, handler http.HandlerFunc) {
+    server := httptest.NewServer(handler)
+    t.Cleanup(server.Close)
+
+    settings := config.DefaultConfiguration()
+    settings.MentionPolicyURL = server.URL
+    settings.MentionPolicyTimeout = 2
+    require.NoError(t, settings.ProcessConfiguration())
+    config.SetConfig(settings)
+}
+
+func TestMentionPolicyIsCheckedWithoutGroupLock(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "alice"},
+        &model.User{Id: "u1", Username: "bob"},
+        &model.User{Id: "u2", Username: "carol"},
+        &model.User{Id: "u3", Username: "dave"},
+    )
+    expectNotifications(api, "channel")
+    p := newTestPlugin(t, api)
+    for groupName, members := range map[string][]string{"dev": {"u1"}, "ops": {"u2"}, "qa": {"u3"}} {
+        require.NoError(t, p.createGroup(groupName, members, "", "creator"))
+    }
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", ChannelId: "channel", Message: "@dev @ops @qa release is out"})
+
+    const delay = 200 * time.Millisecond
+    var mu sync.Mutex
+    var checked []string
+    lockFree := true
+    usePolicyServer(t, func(w http.ResponseWriter, r *http.Request) {
+        var request MentionPolicyRequest
+        require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+        // A writer must not wait for the policy URL
+        if p.groupMutex.TryLock() {
+            p.groupMutex.Unlock()
+        } else {
+            mu.Lock()
+            lockFree = false
+            mu.Unlock()
+        }
+        time.Sleep(delay)
+
+        mu.Lock()
+        checked = append(checked, request.Group)
+        mu.Unlock()
+        _ = json.NewEncoder(w).Encode(&MentionPolicyResponse{Allow: request.Group != "ops"})
+    })
+
+    start := time.Now()
+    p.MessageHasBeenPosted(&plugin.Context{}, post)
+    elapsed := time.Since(start)
+
+    assert.True(t, lockFree, "groupMutex was held during a policy request")
+    assert.Less(t, elapsed, 2*delay, "the groups should be checked concurrently")
+    sort.Strings(checked)
+    assert.Equal(t, []string{"dev", "ops", "qa"}, checked)
+    recipients := ephemeralRecipients(api)
+    sort.Strings(recipients)
+    assert.Equal(t, []string{"u1", "u3"}, recipients)
+}
+
+func TestAllowedMentions(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+    post := &model.Post{Id: "post", UserId: "author", ChannelId: "channel"}
+
+    // Without a policy URL every group is allowed
+    assert.Equal(t, map[string]bool{"dev": true, "ops": true}, p.allowedMentions([]string{"dev", "ops"}, post))
+
+    var mu sync.Mutex
+    requests := 0
+    usePolicyServer(t, func(w http.ResponseWriter, r *http.Request) {
+        var request MentionPolicyRequest
+        require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+        mu.Lock()
+        requests++
+        mu.Unlock()
+        if request.Group == "ops" {
+            w.WriteHeader(http.StatusForbidden)
+            return
+        }
+        _ = json.NewEncoder(w).Encode(&MentionPolicyResponse{Allow: true})
+    })
+
+    assert.Equal(t, map[string]bool{"dev": true, "ops": false}, p.allowedMentions([]string{"dev", "ops", "dev"}, post))
+    assert.Equal(t, 2, requests, "each group should be checked once")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "sync"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// mentionRates counts mentions per group, in total and within the alert
+// window, and remembers when each group was last alerted about. The zero
+// value is ready to use.
+type mentionRates struct {
+    mu      sync.Mutex
+    totals  map[string]int64       // map[groupName]mentions since activation
+    recent  map[string][]time.Time // map[groupName]mentions within the window
+    alerted map[string]time.Time   // map[groupName]time of the last alert
+}
+
+// record counts a mention of each group and returns how often the groups
+// that reached threshold within window were mentioned in it. A group is
+// alerted about at most once per window. A threshold of 0 only counts.
+func (m *mentionRates) record(groupNames []string, threshold int, window time.Duration, now time.Time) map[string]int {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.totals == nil {
+        m.totals = make(map[string]int64)
+        m.recent = make(map[string][]time.Time)
+        m.alerted = make(map[string]time.Time)
+    }
+
+    exceeded := make(map[string]int)
+    for _, groupName := range groupNames {
+        m.totals[groupName]++
+        if threshold <= 0 || window <= 0 {
+            continue
+        }
+
+        recent := []time.Time{}
+        for _, at := range m.recent[groupName] {
+            if now.Sub(at) < window {
+                recent = append(recent, at)
+            }
+        }
+        recent = append(recent, now)
+        m.recent[groupName] = recent
+
+        if len(recent) >= threshold {
+            if last, ok := m.alerted[groupName]; !ok || now.Sub(last) >= window {
+                m.alerted[groupName] = now
+                exceeded[groupName] = len(recent)
+            }
+        }
+    }
+
+    // Drop groups without recent mentions so the maps do not grow forever
+    for groupName, recent := range m.recent {
+        if len(recent) == 0 || now.Sub(recent[len(recent)-1]) >= window {
+            delete(m.recent, groupName)
+        }
+    }
+    for groupName, last := range m.alerted {
+        if now.Sub(last) >= window {
+            delete(m.alerted, groupName)
+        }
+    }
+
+    return exceeded
+}
+
+// snapshot returns the mentions per group since activation.
+func (m *mentionRates) snapshot() map[string]int64 {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    totals := make(map[string]int64, len(m.totals))
+    for groupName, count := range m.totals {
+        totals[groupName] = count
+    }
+    return totals
+}
+
+// trackMentions counts the groups newly mentioned in the post and alerts the
+// configured channel about groups mentioned more often than allowed.
+func (p *Plugin) trackMentions(post *model.Post, skip map[string]bool) {
+    var groupNames []string
+    for groupName := range mentionedGroups(post) {
+        if !skip[groupName] {
+            groupNames = append(groupNames, groupName)
+        }
+    }
+    if len(groupNames) == 0 {
+        return
+    }
+
+    configuration := config.GetConfig()
+    window := time.Duration(configuration.MentionAlertWindow) * time.Minute
+    threshold := configuration.MentionAlertThreshold
+    if configuration.MentionAlertChannel == "" {
+        threshold = 0
+    }
+
+    for groupName, count := range p.mentionRates.record(groupNames, threshold, window, time.Now()) {
+        p.alertMentionRate(post, configuration.MentionAlertChannel, groupName, count, configuration.MentionAlertWindow)
+    }
+}
+
+// alertMentionRate posts a notice about a frequently mentioned group in the
+// alert channel.
+func (p *Plugin) alertMentionRate(post *model.Post, alertChannelID, groupName string, count, windowMinutes int) {
+    p.API.LogWarn("Group mentioned more often than the alert threshold", "group", groupName, "count", count, "user_id", post.UserId)
+
+    author := post.UserId
+    if user, appErr := p.API.GetUser(post.UserId); appErr == nil {
+        author = user.Username
+    }
+
+    channelName := post.ChannelId
+    permalink := ""
+    if channel, appErr := p.API.GetChannel(post.ChannelId); appErr == nil {
+        channelName = channel.Name
+        permalink = p.postPermalink(post, channel)
+    }
+
+    message := translate(defaultLocale, "alert.mention_rate", groupName, count, windowMinutes, author, channelName)
+    if permalink != "" {
+        message += "\n" + translate(defaultLocale, "notification.permalink", permalink)
+    }
+
+    if _, appErr := p.API.CreatePost(&model.Post{
+        UserId:    p.botID,
+        ChannelId: alertChannelID,
+        Message:   message,
+    }); appErr != nil {
+        p.API.LogError("Failed to post group mention alert", "error", appErr.Error())
+    }
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+    "encoding/json"
+    "regexp"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing per-group metadata in KV store
+    groupMetadataKey = "custom_groups_metadata"
+
+    // Priority set on posts mentioning an urgent group
+    postPriorityUrgent = "urgent"
+)
+
+// hexColorPattern matches #rgb and #rrggbb colors.
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// GroupMetadata holds optional per-group settings stored alongside the
+// membership map.
+type GroupMetadata struct {
+    Color string `json:"color,omitempty"` // highlight color for group chips, e.g. #1e90ff
+    Label string `json:"label,omitempty"` // short label shown on group chips
+
+    // Template overrides the mention notification text, see renderNotification
+    Template string `json:"template,omitempty"`
+
+    Pinned       bool `json:"pinned,omitempty"`        // suggested before other groups in autocomplete
+    Urgent       bool `json:"urgent,omitempty"`        // mentions mark the post as urgent
+    EmailOffline bool `json:"email_offline,omitempty"` // offline members are also emailed
+
+    // Schedules limits when members are mentioned, see activeMembers
+    Schedules map[string]*MemberSchedule `json:"schedules,omitempty"` // map[userID]schedule
+
+    Description string   `json:"description,omitempty"` // what the group is for
+    Tags        []string `json:"tags,omitempty"`        // sorted, lowercase tags for organizing groups
+    Subgroups   []string `json:"subgroups,omitempty"`   // sorted names of the groups whose members are included in mentions
+    CreatedBy   string   `json:"created_by,omitempty"`  // user ID of the creator, empty for groups created before it was recorded
+
+    CreatedAt int64 `json:"created_at,omitempty"` // milliseconds since epoch
+    UpdatedAt int64 `json:"updated_at,omitempty"` // last membership change
+
+    // JoinedAt records when each member was added, in milliseconds since
+    // epoch. Members added before join dates were recorded have none.
+    JoinedAt map[string]int64 `json:"joined_at,omitempty"` // map[userID]time
+}
+
+func (m *GroupMetadata) isEmpty() bool {
+    return m.Color == "" && m.Label == "" && m.Template == "" && !m.Pinned && !m.Urgent && !m.EmailOffline && m.Description == "" && len(m.Tags) == 0 && len(m.Subgroups) == 0 && m.CreatedBy == "" && len(m.Schedules) == 0 && len(m.JoinedAt) == 0 && m.CreatedAt == 0 && m.UpdatedAt == 0
+}
+
+// clone returns a copy of the metadata that shares no slices or maps with it.
+// Schedules are replaced rather than changed in place, so they are shared.
+func (m *GroupMetadata) clone() *GroupMetadata {
+    if m == nil {
+        return nil
+    }
+
+    clone := *m
+    clone.Tags = append([]string(nil), m.Tags...)
+    clone.Subgroups = append([]string(nil), m.Subgroups...)
+    if m.Schedules != nil {
+        clone.Schedules = make(map[string]*MemberSchedule, len(m.Schedules))
+        for userID, schedule := range m.Schedules {
+            clone.Schedules[userID] = schedule
+        }
+    }
+    if m.JoinedAt != nil {
+        clone.JoinedAt = make(map[string]int64, len(m.JoinedAt))
+        for userID, joinedAt := range m.JoinedAt {
+            clone.JoinedAt[userID] = joinedAt
+        }
+    }
+    return &clone
+}
+
+func (p *Plugin) loadGroupMetadata() error {
+    data, appErr := p.API.KVGet(groupMetadataKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    metadata := make(map[string]*GroupMetadata)
+    if data != nil {
+        if err := json.Unmarshal(data, &metadata); err != nil {
+            return err
+        }
+    }
+
+    p.groupMutex.Lock()
+    p.groupMetadata = metadata
+    stored, err := p.metadataEntries()
+    p.groupMutex.Unlock()
+
+    if err != nil {
+        return err
+    }
+
+    p.storeMutex.Lock()
+    p.storedMetadata = stored
+    p.storeMutex.Unlock()
+
+    return nil
+}
+
+// saveGroupMetadata persists the metadata of the groups that changed since
+// it was last stored. The changes are merged into the stored blob with
+// compare-and-set, so changes another server made to other groups are kept.
+// Like saveGroups it must not be called while holding groupMutex.
+func (p *Plugin) saveGroupMetadata() error {
+    p.storeMutex.Lock()
+    defer p.storeMutex.Unlock()
+
+    p.groupMutex.RLock()
+    snapshot, err := p.metadataEntries()
+    p.groupMutex.RUnlock()
+
+    if err != nil {
+        return err
+    }
+
+    if err := p.storeEntries(groupMetadataKey, p.storedMetadata, snapshot); err != nil {
+        return err
+    }
+    p.storedMetadata = snapshot
+
+    return nil
+}
+
+// metadataEntries returns the JSON of each group's metadata. Callers must
+// hold groupMutex.
+func (p *Plugin) metadataEntries() (map[string]json.RawMessage, error) {
+    entries := make(map[string]json.RawMessage, len(p.groupMetadata))
+    for groupName, metadata := range p.groupMetadata {
+        data, err := json.Marshal(metadata)
+        if err != nil {
+            return nil, err
+        }
+        entries[groupName] = data
+    }
+    return entries, nil
+}
+
+// metadataFor returns the metadata of a group, creating it when missing.
+// Callers must hold the groupMutex write lock.
+func (p *Plugin) metadataFor(groupName string) *GroupMetadata {
+    metadata, ok := p.groupMetadata[groupName]
+    if !ok {
+        metadata = &GroupMetadata{}
+        p.groupMetadata[groupName] = metadata
+    }
+    return metadata
+}
+
+// touchGroup records a change to a group, setting its creation time on first
+// use. Callers must hold the groupMutex write lock.
+func (p *Plugin) touchGroup(groupName string) {
+    metadata := p.metadataFor(groupName)
+    now := model.GetMillis()
+    if metadata.CreatedAt == 0 {
+        metadata.CreatedAt = now
+    }
+    metadata.UpdatedAt = now
+}
+
+// recordJoins sets the join date of members added to a group. Callers must
+// hold the groupMutex write lock.
+func (p *Plugin) recordJoins(groupName string, userIDs []string) {
+    if len(userIDs) == 0 {
+        return
+    }
+
+    metadata := p.metadataFor(groupName)
+    if metadata.JoinedAt == nil {
+        metadata.JoinedAt = make(map[string]int64)
+    }
+    now := model.GetMillis()
+    for _, userID := range userIDs {
+        metadata.JoinedAt[userID] = now
+    }
+}
+
+// forgetJoins drops the join date of members removed from a group. Callers
+// must hold the groupMutex write lock.
+func (p *Plugin) forgetJoins(groupName string, userIDs []string) {
+    metadata, ok := p.groupMetadata[groupName]
+    if !ok {
+        return
+    }
+    for _, userID := range userIDs {
+        delete(metadata.JoinedAt, userID)
+    }
+}
+
+// joinedAt returns when the user joined the group, or 0 when it is unknown.
+// Callers must hold groupMutex.
+func (p *Plugin) joinedAt(groupName, userID string) int64 {
+    if metadata, ok := p.groupMetadata[groupName]; ok {
+        return metadata.JoinedAt[userID]
+    }
+    return 0
+}
+
+// joinDateText renders a join date for command output.
+func joinDateText(millis int64) string {
+    if millis == 0 {
+        return "unknown"
+    }
+    return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format("2006-01-02")
+}
+
+// saveGroupState persists the membership map, the group metadata and the
+// membership event log. Callers must release groupMutex first.
+func (p *Plugin) saveGroupState() error {
+    if err := p.saveGroups(); err != nil {
+        return err
+    }
+    if err := p.saveGroupMetadata(); err != nil {
+        return err
+    }
+    return p.saveGroupEvents()
+}
+
+// setGroupColor sets or clears (empty color) the highlight color and label
+// of a group.
+func (p *Plugin) setGroupColor(groupName, color, label string) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.Color = color
+    metadata.Label = label
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// setGroupTemplate sets or clears (empty template) the notification template
+// of a group. The template must already be validated.
+func (p *Plugin) setGroupTemplate(groupName, template string) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.Template = template
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// setGroupPinned pins a group to the top of autocomplete suggestions or
+// unpins it.
+func (p *Plugin) setGroupPinned(groupName string, pinned bool) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.Pinned = pinned
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// setGroupUrgent marks a group's mentions as urgent or back to normal
+// priority.
+func (p *Plugin) setGroupUrgent(groupName string, urgent bool) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.Urgent = urgent
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// isPinned reports whether a group is pinned. Callers must hold groupMutex.
+func (p *Plugin) isPinned(groupName string) bool {
+    metadata, ok := p.groupMetadata[groupName]
+    return ok && metadata.Pinned
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sync/atomic"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// metrics counts feature usage since the plugin was activated. The zero value
+// is ready to use.
+type metrics struct {
+    autocompleteRequests    int64
+    autocompleteSuggestions int64
+    postsExpanded           int64
+    groupsMatched           int64
+
+    idempotencyHits     int64 // requests answered with a recorded response
+    idempotencyMisses   int64
+    recipientHits       int64 // recipient decisions reused within a post
+    recipientMisses     int64
+    groupLockAcquired   int64 // groupMutex read locks taken by the post hooks and autocomplete
+    groupLockContended  int64
+    groupLockWaitMicros int64
+}
+
+// Stats is the response of the stats endpoint.
+type Stats struct {
+    Groups                  int   `json:"groups"`
+    Members                 int   `json:"members"`                  // memberships across all groups
+    AutocompleteRequests    int64 `json:"autocomplete_requests"`    // @mention autocompletes handled
+    AutocompleteSuggestions int64 `json:"autocomplete_suggestions"` // groups suggested in total
+    PostsExpanded           int64 `json:"posts_expanded"`           // posts with at least one group mention
+    GroupsMatched           int64 `json:"groups_matched"`           // group mentions expanded in total
+
+    MentionsByGroup map[string]int64 `json:"mentions_by_group"` // posts mentioning each group
+
+    Details []*GroupReport `json:"details,omitempty"` // every group sorted by name, with details=true
+}
+
+func (m *metrics) autocomplete(suggestions int) {
+    atomic.AddInt64(&m.autocompleteRequests, 1)
+    atomic.AddInt64(&m.autocompleteSuggestions, int64(suggestions))
+}
+
+func (m *metrics) expansion(groups int) {
+    atomic.AddInt64(&m.postsExpanded, 1)
+    atomic.AddInt64(&m.groupsMatched, int64(groups))
+}
+
+// handleStats returns group totals and usage counters. Only system admins
+// can read them.
+func (p *Plugin) handleStats(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if !hasReadToken(r) && (userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem)) {
+        http.Error(w, "Only system administrators can read plugin stats", http.StatusForbidden)
+        return
+    }
+
+    stats := &Stats{
+        AutocompleteRequests:    atomic.LoadInt64(&p.metrics.autocompleteRequests),
+        AutocompleteSuggestions: atomic.LoadInt64(&p.metrics.autocompleteSuggestions),
+        PostsExpanded:           atomic.LoadInt64(&p.metrics.postsExpanded),
+        GroupsMatched:           atomic.LoadInt64(&p.metrics.groupsMatched),
+        MentionsByGroup:         p.mentionRates.snapshot(),
+    }
+
+    p.groupMutex.RLock()
+    stats.Groups = len(p.groups)
+    for _, members := range p.groups {
+        stats.Members += len(members)
+    }
+    if r.URL.Query().Get("details") == "true" {
+        groupNames, _ := p.sortedGroupNames(sortByName)
+        stats.Details = p.groupReports(groupNames, stats.MentionsByGroup)
+    }
+    p.groupMutex.RUnlock()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// groupNameCommands are the subcommands whose first argument is a group name.
+var groupNameCommands = map[string]bool{
+    "create":         true,
+    "add":            true,
+    "addchannel":     true,
+    "remove":         true,
+    "info":           true,
+    "color":          true,
+    "pin":            true,
+    "unpin":          true,
+    "priority":       true,
+    "email":          true,
+    "tag":            true,
+    "schedule":       true,
+    "status":         true,
+    "dynamic":        true,
+    "push":           true,
+    "template":       true,
+    "delete":         true,
+    "restore":        true,
+    "export":         true,
+    "import":         true,
+    "import-preview": true,
+    "dedupe":         true,
+}
+
+// canonicalGroupName returns the form a group name is stored under. Group
+// names are case-insensitive, so "Dev" and "dev" are the same group.
+func canonicalGroupName(groupName string) string {
+    return strings.ToLower(strings.TrimSpace(groupName))
+}
+
+// isNameRune reports whether r can continue a group name or username in a
+// mention, so that a mention of @dev does not match @dev-ops.
+func isNameRune(r rune) bool {
+    return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// groupMentionIndexes returns the byte ranges of the mentions of a group in
+// the message, ignoring case. A mention must not be part of a longer word or
+// name; trailing periods, as at the end of a sentence, are not part of it.
+func groupMentionIndexes(message, groupName string) [][]int {
+    pattern := regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(groupName))
+
+    var mentions [][]int
+    for _, match := range pattern.FindAllStringIndex(message, -1) {
+        if before, _ := utf8.DecodeLastRuneInString(message[:match[0]]); match[0] > 0 && (isNameRune(before) || before == '.') {
+            continue
+        }
+        rest := strings.TrimLeft(message[match[1]:], ".")
+        if after, _ := utf8.DecodeRuneInString(rest); rest != "" && isNameRune(after) {
+            continue
+        }
+        mentions = append(mentions, match)
+    }
+    return mentions
+}
+
+// alreadyExpanded reports whether one of the mentions is followed by the
+// member list added by an earlier expansion.
+func alreadyExpanded(message string, mentions [][]int) bool {
+    for _, mention := range mentions {
+        if strings.HasPrefix(message[mention[1]:], " (Group - ") {
+            return true
+        }
+    }
+    return false
+}
+
+// replaceMentions replaces the mentions at the byte ranges, in order, with
+// the replacement.
+func replaceMentions(message string, mentions [][]int, replacement string) string {
+    var replaced strings.Builder
+    last := 0
+    for _, mention := range mentions {
+        replaced.WriteString(message[last:mention[0]])
+        replaced.WriteString(replacement)
+        last = mention[1]
+    }
+    replaced.WriteString(message[last:])
+    return replaced.String()
+}
+
+// caseCollisions returns the groups whose names differ only by case from
+// another group. Callers must hold groupMutex.
+func (p *Plugin) caseCollisions() []string {
+    byCanonical := make(map[string][]string)
+    for groupName := range p.groups {
+        canonical := canonicalGroupName(groupName)
+        byCanonical[canonical] = append(byCanonical[canonical], groupName)
+    }
+    for groupName := range p.dynamicGroups {
+        canonical := canonicalGroupName(groupName)
+        byCanonical[canonical] = append(byCanonical[canonical], groupName)
+    }
+
+    collisions := []string{}
+    for _, names := range byCanonical {
+        if len(names) > 1 {
+            collisions = append(collisions, names...)
+        }
+    }
+    sort.Strings(collisions)
+    return collisions
+}
+
+// planCanonicalNames returns the new name of every static and dynamic group
+// whose name is not in lowercase. When several groups share a lowercase name,
+// the one already in lowercase, or else the first by name, gets it, and the
+// others get the first free name with a -2, -3... suffix. Callers must hold
+// groupMutex.
+func (p *Plugin) planCanonicalNames() map[string]string {
+    byCanonical := make(map[string][]string)
+    taken := make(map[string]bool)
+    add := func(groupName string) {
+        canonical := canonicalGroupName(groupName)
+        byCanonical[canonical] = append(byCanonical[canonical], groupName)
+        taken[canonical] = true
+    }
+    for groupName := range p.groups {
+        add(groupName)
+    }
+    for groupName := range p.dynamicGroups {
+        add(groupName)
+    }
+
+    renames := make(map[string]string)
+    var losers []string
+    for _, canonical := range sortedKeys(byCanonical) {
+        names := byCanonical[canonical]
+        sort.Strings(names)
+        winner := names[0]
+        if contains(names, canonical) {
+            winner = canonical
+        }
+        for _, groupName := range names {
+            switch {
+            case groupName == winner && groupName != canonical:
+                renames[groupName] = canonical
+            case groupName != winner:
+                losers = append(losers, groupName)
+            }
+        }
+    }
+
+    sort.Strings(losers)
+    for _, groupName := range losers {
+        canonical := canonicalGroupName(groupName)
+        for n := 2; ; n++ {
+            candidate := fmt.Sprintf("%s-%d", canonical, n)
+            if !taken[candidate] {
+                taken[candidate] = true
+                renames[groupName] = candidate
+                break
+            }
+        }
+    }
+
+    return renames
+}
+
+// canonicalizeGroupNames renames groups stored before names were
+// case-insensitive to their lowercase form. Groups whose lowercase name is
+// already taken get a numbered name instead, see planCanonicalNames, so they
+// can still be addressed by commands and merged or renamed.
+func (p *Plugin) canonicalizeGroupNames() error {
+    p.groupMutex.Lock()
+    plan := p.planCanonicalNames()
+
+    renamed := make(map[string]string)
+    collided := make(map[string]string)
+    dynamicRenamed := false
+    for groupName, newName := range plan {
+        if newName != canonicalGroupName(groupName) {
+            collided[groupName] = newName
+        }
+        if group, ok := p.dynamicGroups[groupName]; ok {
+            p.dynamicGroups[newName] = group
+            delete(p.dynamicGroups, groupName)
+            dynamicRenamed = true
+            continue
+        }
+        p.groups[newName] = p.groups[groupName]
+        delete(p.groups, groupName)
+        if metadata, ok := p.groupMetadata[groupName]; ok {
+            p.groupMetadata[newName] = metadata
+            delete(p.groupMetadata, groupName)
+        }
+        renamed[groupName] = newName
+    }
+    trashRenamed := false
+    for groupName, deleted := range p.groupTrash {
+        canonical := canonicalGroupName(groupName)
+        if _, exists := p.groupTrash[canonical]; canonical == groupName || exists {
+            continue
+        }
+        p.groupTrash[canonical] = deleted
+        delete(p.groupTrash, groupName)
+        trashRenamed = true
+    }
+    for setName, groupNames := range p.exclusiveSets {
+        for i, groupName := range groupNames {
+            if canonical, ok := renamed[groupName]; ok {
+                p.exclusiveSets[setName][i] = canonical
+            }
+        }
+    }
+    p.renameSubgroupRefs(renamed)
+    p.groupMutex.Unlock()
+
+    for groupName, newName := range collided {
+        p.API.LogWarn("Group name differed only by case from another group, so it was renamed", "group", groupName, "new_name", newName)
+    }
+
+    if dynamicRenamed {
+        if err := p.saveDynamicGroups(); err != nil {
+            return err
+        }
+    }
+    if trashRenamed {
+        if err := p.saveGroupTrash(); err != nil {
+            return err
+        }
+    }
+    if len(renamed) == 0 {
+        return nil
+    }
+    p.API.LogInfo("Renamed groups to lowercase names", "count", len(renamed))
+
+    if err := p.saveExclusiveSets(); err != nil {
+        return err
+    }
+    if err := p.saveGroupMetadata(); err != nil {
+        return err
+    }
+    return p.saveGroups()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestCreateGroupIgnoresCase(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+
+    require.NoError(t, p.createGroup("Dev", nil, "", "creator"))
+    assert.Contains(t, p.groups, "dev")
+    assert.NotContains(t, p.groups, "Dev")
+
+    for _, name := range []string{"dev", "DEV", " dEv "} {
+        assert.ErrorIs(t, p.createGroup(name, nil, "", "creator"), ErrGroupExists, name)
+    }
+    assert.Len(t, p.groups, 1)
+}
+
+func TestCanonicalizeGroupNames(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+    p.groups = map[string][]string{
+        "Dev":   {"a"},
+        "dev":   {"b"},
+        "DEV":   {"c"},
+        "Ops":   {"d"},
+        "dev-2": {"e"},
+    }
+    p.groupMetadata["Dev"] = &GroupMetadata{Description: "mixed case"}
+    p.groupMetadata["all-eng"] = &GroupMetadata{Subgroups: []string{"Ops"}}
+    p.groups["all-eng"] = []string{}
+
+    require.NoError(t, p.canonicalizeGroupNames())
+
+    assert.Equal(t, map[string][]string{
+        "dev":     {"b"},
+        "dev-2":   {"e"},
+        "dev-3":   {"c"},
+        "dev-4":   {"a"},
+        "ops":     {"d"},
+        "all-eng": {},
+    }, p.groups)
+    assert.Equal(t, "mixed case", p.groupMetadata["dev-4"].Description)
+    assert.Equal(t, []string{"ops"}, p.groupMetadata["all-eng"].Subgroups)
+
+    reloaded := loadTestServer(t, api)
+    assert.Equal(t, p.groups, reloaded.groups)
+    assert.Equal(t, "mixed case", reloaded.groupMetadata["dev-4"].Description)
+}
+
+func TestCanonicalizeGroupNamesKeepsCollidedGroupsReachable(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    p.groups = map[string][]string{"Dev": {"a"}, "DEV": {"b"}}
+
+    require.NoError(t, p.canonicalizeGroupNames())
+
+    // Every group now has a lowercase name, which commands can address
+    assert.Equal(t, map[string][]string{"dev": {"b"}, "dev-2": {"a"}}, p.groups)
+    for groupName := range p.groups {
+        assert.Equal(t, canonicalGroupName(groupName), groupName)
+    }
+    assert.Empty(t, p.caseCollisions())
+}
+
+func TestGroupMentionIndexes(t *testing.T) {
+    for _, test := range []struct {
+        message  string
+        expected [][]int
+    }{
+        {"@dev", [][]int{{0, 4}}},
+        {"ping @Dev please", [][]int{{5, 9}}},
+        {"(@DEV) and @dev.", [][]int{{1, 5}, {11, 15}}},
+        {"@dev, @dev!", [][]int{{0, 4}, {6, 10}}},
+        {"@dev-ops", nil},
+        {"@devs", nil},
+        {"@dev.ops", nil},
+        {"mail@dev", nil},
+        {"no mention", nil},
+    } {
+        assert.Equal(t, test.expected, groupMentionIndexes(test.message, "dev"), test.message)
+    }
+}
+
+func TestExpandGroupMentionsIgnoresCase(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("GetUser", "u1").Return(&model.User{Id: "u1", Username: "alice"}, nil)
+    p := newTestPlugin(t, api)
+    p.groups["dev"] = []string{"u1"}
+
+    post := &model.Post{UserId: "author", Message: "ping @Dev and @DEV, not @dev-ops"}
+    p.expandGroupMentions(post, nil)
+
+    assert.Equal(t, "ping @dev (Group - 1 members: @alice) and @dev (Group - 1 members: @alice), not @dev-ops", post.Message)
+    assert.Contains(t, post.Props["mentions"], "u1")
+}
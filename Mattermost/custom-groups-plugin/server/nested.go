@@ -0,0 +1,290 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Prefix of a member entry that includes another group, e.g. group:backend
+    groupRefPrefix = "group:"
+
+    // Levels of included groups followed when a mention is expanded
+    maxGroupNesting = 5
+)
+
+// splitGroupRefs separates group:name references from the other tokens,
+// returning the canonical names of the referenced groups.
+func splitGroupRefs(tokens []string) ([]string, []string) {
+    refs := []string{}
+    rest := []string{}
+    for _, token := range tokens {
+        if !strings.HasPrefix(strings.ToLower(token), groupRefPrefix) {
+            rest = append(rest, token)
+            continue
+        }
+        ref := canonicalGroupName(strings.TrimPrefix(token[len(groupRefPrefix):], "@"))
+        if ref != "" && !contains(refs, ref) {
+            refs = append(refs, ref)
+        }
+    }
+    return refs, rest
+}
+
+// subgroups returns the groups a group includes. Callers must hold
+// groupMutex.
+func (p *Plugin) subgroups(groupName string) []string {
+    if metadata, ok := p.groupMetadata[groupName]; ok {
+        return metadata.Subgroups
+    }
+    return nil
+}
+
+// includesGroup reports whether target is from or is included by it, directly
+// or through other groups. Callers must hold groupMutex.
+func (p *Plugin) includesGroup(from, target string) bool {
+    return reachesGroup(p.subgroups, from, target)
+}
+
+// reachesGroup reports whether target is from or is included by it, with
+// subgroups returning the groups a group includes.
+func reachesGroup(subgroups func(groupName string) []string, from, target string) bool {
+    visited := make(map[string]bool)
+    pending := []string{from}
+    for len(pending) > 0 {
+        groupName := pending[len(pending)-1]
+        pending = pending[:len(pending)-1]
+        if groupName == target {
+            return true
+        }
+        if visited[groupName] {
+            continue
+        }
+        visited[groupName] = true
+        pending = append(pending, subgroups(groupName)...)
+    }
+    return false
+}
+
+// resolveMembers returns the currently scheduled members of a group and of
+// the groups it includes, up to maxGroupNesting levels deep, each member once
+// in the order found. Included groups that no longer exist are skipped.
+// Callers must hold groupMutex.
+func (p *Plugin) resolveMembers(groupName string, now time.Time) []string {
+    members := p.activeMembers(groupName, p.groups[groupName], now)
+    if len(p.subgroups(groupName)) == 0 {
+        return members
+    }
+
+    seen := make(map[string]bool)
+    visited := make(map[string]bool)
+    resolved := []string{}
+
+    var walk func(name string, depth int)
+    walk = func(name string, depth int) {
+        if visited[name] {
+            return
+        }
+        if depth > maxGroupNesting {
+            p.API.LogWarn("Group nesting is too deep, included groups were skipped", "group", groupName, "skipped", name)
+            return
+        }
+        visited[name] = true
+
+        groupMembers, exists := p.groups[name]
+        if !exists {
+            return
+        }
+        for _, userID := range p.activeMembers(name, groupMembers, now) {
+            if !seen[userID] {
+                seen[userID] = true
+                resolved = append(resolved, userID)
+            }
+        }
+        for _, subgroup := range p.subgroups(name) {
+            walk(subgroup, depth+1)
+        }
+    }
+    walk(groupName, 0)
+
+    return resolved
+}
+
+// checkSubgroupRefs reports why a group about to be created could not include
+// the referenced groups: one does not exist or is the group itself.
+func (p *Plugin) checkSubgroupRefs(groupName string, refs []string) error {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    for _, ref := range refs {
+        if ref == groupName {
+            return fmt.Errorf("%w: %s cannot include itself", ErrNestingCycle, ref)
+        }
+        if _, exists := p.groups[ref]; !exists {
+            return groupError(ErrGroupNotFound, ref)
+        }
+    }
+    return nil
+}
+
+// addSubgroups makes a group include other groups. Nothing is changed when a
+// referenced group does not exist or including it would create a cycle. It
+// returns the groups newly included and those that already were.
+func (p *Plugin) addSubgroups(groupName string, refs []string) ([]string, []string, error) {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return nil, nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    added := []string{}
+    skipped := []string{}
+    for _, ref := range refs {
+        if _, exists := p.groups[ref]; !exists {
+            p.groupMutex.Unlock()
+            return nil, nil, groupError(ErrGroupNotFound, ref)
+        }
+        if p.includesGroup(ref, groupName) {
+            p.groupMutex.Unlock()
+            return nil, nil, fmt.Errorf("%w: %s includes %s", ErrNestingCycle, ref, groupName)
+        }
+        if contains(p.subgroups(groupName), ref) {
+            skipped = append(skipped, ref)
+            continue
+        }
+        added = append(added, ref)
+    }
+
+    if len(added) > 0 {
+        metadata := p.metadataFor(groupName)
+        metadata.Subgroups = append(append([]string{}, metadata.Subgroups...), added...)
+        sort.Strings(metadata.Subgroups)
+        p.touchGroup(groupName)
+    }
+    p.groupMutex.Unlock()
+
+    if len(added) == 0 {
+        return added, skipped, nil
+    }
+
+    // Save to persistent storage
+    return added, skipped, p.saveGroupMetadata()
+}
+
+// removeSubgroups stops a group from including other groups. It returns the
+// groups removed and those that were not included.
+func (p *Plugin) removeSubgroups(groupName string, refs []string) ([]string, []string, error) {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return nil, nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    removed := []string{}
+    missing := []string{}
+    kept := []string{}
+    for _, subgroup := range p.subgroups(groupName) {
+        if contains(refs, subgroup) {
+            removed = append(removed, subgroup)
+        } else {
+            kept = append(kept, subgroup)
+        }
+    }
+    for _, ref := range refs {
+        if !contains(removed, ref) {
+            missing = append(missing, ref)
+        }
+    }
+
+    if len(removed) > 0 {
+        metadata := p.metadataFor(groupName)
+        metadata.Subgroups = kept
+        if len(kept) == 0 {
+            metadata.Subgroups = nil
+        }
+        p.touchGroup(groupName)
+    }
+    p.groupMutex.Unlock()
+
+    if len(removed) == 0 {
+        return removed, missing, nil
+    }
+
+    // Save to persistent storage
+    return removed, missing, p.saveGroupMetadata()
+}
+
+// renameSubgroupRefs points the references to renamed groups at their new
+// names. Callers must hold the groupMutex write lock.
+func (p *Plugin) renameSubgroupRefs(renamed map[string]string) {
+    for _, metadata := range p.groupMetadata {
+        if len(metadata.Subgroups) == 0 {
+            continue
+        }
+        subgroups := []string{}
+        for _, subgroup := range metadata.Subgroups {
+            if to, ok := renamed[subgroup]; ok {
+                subgroup = to
+            }
+            if !contains(subgroups, subgroup) {
+                subgroups = append(subgroups, subgroup)
+            }
+        }
+        sort.Strings(subgroups)
+        metadata.Subgroups = subgroups
+    }
+}
+
+// subgroupsCommand adds or removes group:name references of a group.
+func (p *Plugin) subgroupsCommand(groupName string, refs []string, remove, quiet bool) *model.CommandResponse {
+    p.groupMutex.RLock()
+    _, exists := p.groups[groupName]
+    p.groupMutex.RUnlock()
+    if !exists {
+        return &model.CommandResponse{
+            Text: commandErrorText(groupError(ErrGroupNotFound, groupName), groupName, ""),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var changed, unchanged []string
+    var err error
+    if remove {
+        changed, unchanged, err = p.removeSubgroups(groupName, refs)
+    } else {
+        changed, unchanged, err = p.addSubgroups(groupName, refs)
+    }
+    if err != nil {
+        text := "Failed to save changes"
+        if errors.Is(err, ErrGroupNotFound) || errors.Is(err, ErrNestingCycle) {
+            text = fmt.Sprintf("Cannot include the groups in %s: %v", groupName, err)
+        }
+        return &model.CommandResponse{
+            Text: text,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var text strings.Builder
+    if remove {
+        text.WriteString(fmt.Sprintf("Group %s: %d groups no longer included, %d were not included", groupName, len(changed), len(unchanged)))
+    } else {
+        text.WriteString(fmt.Sprintf("Group %s: %d groups included, %d already included", groupName, len(changed), len(unchanged)))
+    }
+    if len(changed) > 0 {
+        text.WriteString(fmt.Sprintf("\n- Changed: @%s", strings.Join(changed, ", @")))
+    }
+    if len(unchanged) > 0 {
+        text.WriteString(fmt.Sprintf("\n- Unchanged: @%s", strings.Join(unchanged, ", @")))
+    }
+
+    return &model.CommandResponse{
+        Text: successText(quiet, text.String()),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestAddSubgroupsRejectsCycles(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    for _, groupName := range []string{"a", "b", "c"} {
+        require.NoError(t, p.createGroup(groupName, nil, "", "creator"))
+    }
+
+    _, _, err := p.addSubgroups("a", []string{"b"})
+    require.NoError(t, err)
+    _, _, err = p.addSubgroups("b", []string{"c"})
+    require.NoError(t, err)
+
+    _, _, err = p.addSubgroups("c", []string{"a"})
+    assert.ErrorIs(t, err, ErrNestingCycle)
+    _, _, err = p.addSubgroups("a", []string{"a"})
+    assert.ErrorIs(t, err, ErrNestingCycle)
+    _, _, err = p.addSubgroups("a", []string{"missing"})
+    assert.ErrorIs(t, err, ErrGroupNotFound)
+
+    assert.Empty(t, p.subgroups("c"))
+    assert.Equal(t, []string{"b"}, p.subgroups("a"))
+}
+
+func TestResolveMembersDedupesAndSkipsDeletedGroups(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("frontend", []string{"u1", "u2"}, "", "creator"))
+    require.NoError(t, p.createGroup("backend", []string{"u2", "u3"}, "", "creator"))
+    require.NoError(t, p.createGroup("infra", []string{"u4"}, "", "creator"))
+    require.NoError(t, p.createGroup("all-eng", []string{"u3"}, "", "creator"))
+    _, _, err := p.addSubgroups("all-eng", []string{"frontend", "backend", "infra"})
+    require.NoError(t, err)
+
+    assert.ElementsMatch(t, []string{"u1", "u2", "u3", "u4"}, p.resolveMembers("all-eng", time.Now()))
+
+    require.NoError(t, p.deleteGroup("infra", "actor"))
+    assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, p.resolveMembers("all-eng", time.Now()))
+}
+
+func TestResolveMembersCapsDepth(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    levels := maxGroupNesting + 2
+    for i := 0; i < levels; i++ {
+        require.NoError(t, p.createGroup(fmt.Sprintf("level-%d", i), []string{fmt.Sprintf("u%d", i)}, "", "creator"))
+    }
+    for i := 0; i < levels-1; i++ {
+        _, _, err := p.addSubgroups(fmt.Sprintf("level-%d", i), []string{fmt.Sprintf("level-%d", i+1)})
+        require.NoError(t, err)
+    }
+
+    members := p.resolveMembers("level-0", time.Now())
+
+    assert.Len(t, members, maxGroupNesting+1)
+    assert.Contains(t, members, fmt.Sprintf("u%d", maxGroupNesting))
+    assert.NotContains(t, members, fmt.Sprintf("u%d", maxGroupNesting+1))
+}
+
+func TestCreateCommandIncludesGroups(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("backend", nil, "", "creator"))
+
+    response, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "creator", Command: "/group create all-eng Everyone in engineering group:backend"})
+    require.Nil(t, appErr)
+    assert.Contains(t, response.Text, "Created group all-eng")
+    assert.Equal(t, []string{"backend"}, p.subgroups("all-eng"))
+    assert.Equal(t, "Everyone in engineering", p.groupMetadata["all-eng"].Description)
+
+    response, appErr = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "creator", Command: "/group create loop group:loop"})
+    require.Nil(t, appErr)
+    assert.Contains(t, response.Text, "Cannot include the groups")
+    assert.NotContains(t, p.groups, "loop")
+
+    response, appErr = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "creator", Command: "/group create web group:missing"})
+    require.Nil(t, appErr)
+    assert.Contains(t, response.Text, "Cannot include the groups")
+    assert.NotContains(t, p.groups, "web")
+}
+
+func TestCreateGroupEndpointRejectsNonIDMembers(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("GetUser", "creator").Return(&model.User{Id: "creator"}, nil)
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("backend", nil, "", "creator"))
+
+    post := func(body string) *httptest.ResponseRecorder {
+        r := httptest.NewRequest(http.MethodPost, "/api/v4/groups", strings.NewReader(body))
+        r.Header.Set("Mattermost-User-Id", "creator")
+        w := httptest.NewRecorder()
+        p.ServeHTTP(&plugin.Context{}, w, r)
+        return w
+    }
+
+    w := post(`{"name": "all-eng", "members": ["group:backend"]}`)
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    assert.NotContains(t, p.groups, "all-eng")
+
+    userID := model.NewId()
+    w = post(fmt.Sprintf(`{"name": "all-eng", "members": [%q], "subgroups": ["backend"]}`, userID))
+    assert.Equal(t, http.StatusCreated, w.Code)
+    assert.Equal(t, []string{userID}, p.groups["all-eng"])
+    assert.Equal(t, []string{"backend"}, p.subgroups("all-eng"))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// UserHasBeenCreated adds new users to the configured default groups.
+func (p *Plugin) UserHasBeenCreated(c *plugin.Context, user *model.User) {
+    if user.IsBot {
+        return
+    }
+
+    groupNames := config.GetConfig().DefaultGroupNames()
+    if len(groupNames) == 0 {
+        return
+    }
+
+    if _, err := p.joinDefaultGroups(user.Id, groupNames); err != nil {
+        p.API.LogError("Failed to add new user to default groups", "user_id", user.Id, "error", err.Error())
+    }
+}
+
+// joinDefaultGroups adds the user to every listed group that exists and
+// persists the change once. It returns the names of the groups joined.
+func (p *Plugin) joinDefaultGroups(userID string, groupNames []string) ([]string, error) {
+    p.groupMutex.Lock()
+    joined := []string{}
+    for _, groupName := range groupNames {
+        members, exists := p.groups[groupName]
+        if !exists {
+            p.API.LogWarn("Default group does not exist", "group", groupName)
+            continue
+        }
+
+        if contains(members, userID) {
+            continue
+        }
+
+        p.groups[groupName] = append(members, userID)
+        p.touchGroup(groupName)
+        p.events.append(memberEvents(EventMemberAdded, groupName, "", []string{userID}))
+        p.recordJoins(groupName, []string{userID})
+        p.enforceExclusive(groupName, []string{userID}, "")
+        joined = append(joined, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    if len(joined) == 0 {
+        return joined, nil
+    }
+
+    // Save to persistent storage
+    return joined, p.saveGroupState()
+}
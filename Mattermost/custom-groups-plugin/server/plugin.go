@@ -2,65 +2,244 @@ package main
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
     "net/http"
+    "sort"
+    "strconv"
     "strings"
     "sync"
+    "time"
+    "unicode"
+    "unicode/utf8"
 
     "github.com/mattermost/mattermost-server/v6/model"
     "github.com/mattermost/mattermost-server/v6/plugin"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
 )
 
 type Plugin struct {
     plugin.MattermostPlugin
     groups     map[string][]string // map[groupName][]userIDs
     groupMutex sync.RWMutex
+
+    storedGroups map[string][]string // map[groupName][]userIDs as last written to the KV store, guarded by storeMutex
+    storeMutex   sync.Mutex          // serializes snapshots and writes to the KV store; taken before groupMutex
+
+    storedMetadata map[string]json.RawMessage // map[groupName]metadata as last written to the KV store, guarded by storeMutex
+    storedTrash    map[string]json.RawMessage // map[groupName]deleted group as last written to the KV store, guarded by storeMutex
+
+    groupMetadata map[string]*GroupMetadata // map[groupName]metadata, guarded by groupMutex
+    groupTrash    map[string]*DeletedGroup  // map[groupName]deleted group, guarded by groupMutex
+    dynamicGroups map[string]*DynamicGroup  // map[groupName]dynamic group, guarded by groupMutex
+
+    channelSnoozes map[string]int64    // map[channelID]snooze expiry in milliseconds, guarded by groupMutex
+    exclusiveSets  map[string][]string // map[setName]group names of which a user may join one, guarded by groupMutex
+
+    registeredTrigger string     // trigger of the currently registered slash command
+    commandLock       sync.Mutex // guards registeredTrigger
+
+    botID string
+
+    scheduleStop chan struct{} // closed to stop the background schedule checks
+    reportStop   chan struct{} // closed to stop the membership report job
+
+    commandLimiter      commandLimiter
+    pendingImports      pendingImports
+    threadNotifications threadNotifications
+    metrics             metrics
+    idempotency         idempotencyCache
+    events              eventLog
+    mentionRates        mentionRates
 }
 
 const (
-    // Key for storing groups data in KV store
+    // Key of the single blob earlier versions stored every group in
     groupsKey = "custom_groups"
+
+    // Username of the bot that posts group mention notices
+    botUsername = "custom-groups"
 )
 
 func (p *Plugin) OnActivate() error {
     p.groups = make(map[string][]string)
+
+    if err := p.OnConfigurationChange(); err != nil {
+        return err
+    }
+
+    botID, err := p.ensureBot()
+    if err != nil {
+        return err
+    }
+    p.botID = botID
     
     // Load existing groups from KV store
-    data, err := p.API.KVGet(groupsKey)
-    if err != nil {
+    if err := p.loadGroups(); err != nil {
+        return err
+    }
+
+    if err := p.loadGroupMetadata(); err != nil {
+        return err
+    }
+
+    if err := p.loadGroupTrash(); err != nil {
+        return err
+    }
+
+    if err := p.loadGroupEvents(); err != nil {
+        return err
+    }
+
+    if err := p.loadDynamicGroups(); err != nil {
+        return err
+    }
+
+    if err := p.loadChannelSnoozes(); err != nil {
+        return err
+    }
+
+    if err := p.loadExclusiveSets(); err != nil {
         return err
     }
+
+    if err := p.canonicalizeGroupNames(); err != nil {
+        return err
+    }
+
+    p.scheduleStop = make(chan struct{})
+    go p.runScheduleChecks(p.scheduleStop)
+
+    p.reportStop = make(chan struct{})
+    go p.runReports(p.reportStop)
     
-    if data != nil {
-        if err := json.Unmarshal(data, &p.groups); err != nil {
-            return err
+    return nil
+}
+
+// OnDeactivate stops the background schedule checks and membership reports.
+func (p *Plugin) OnDeactivate() error {
+    if p.scheduleStop != nil {
+        close(p.scheduleStop)
+        p.scheduleStop = nil
+    }
+    if p.reportStop != nil {
+        close(p.reportStop)
+        p.reportStop = nil
+    }
+    return nil
+}
+
+// registerCommand registers the slash command under trigger, removing the
+// previously registered trigger when it changed.
+func (p *Plugin) registerCommand(trigger string) error {
+    p.commandLock.Lock()
+    defer p.commandLock.Unlock()
+
+    if trigger == p.registeredTrigger {
+        return nil
+    }
+
+    if p.registeredTrigger != "" {
+        if err := p.API.UnregisterCommand("", p.registeredTrigger); err != nil {
+            p.API.LogWarn("Failed to unregister previous command", "trigger", p.registeredTrigger, "error", err.Error())
         }
     }
-    
+
     if err := p.API.RegisterCommand(&model.Command{
-        Trigger:          "group",
+        Trigger:          trigger,
         AutoComplete:     true,
         AutoCompleteDesc: "Manage user groups",
-        AutoCompleteHint: "[create|add|list|delete|export|import] [group_name] [username]",
+        AutoCompleteHint: "[create|add|addchannel|remove|list|info|color|pin|unpin|priority|email|tag|schedule|status|dynamic|push|from-post|template|leave-all|rename-bulk|delete|trash|restore|export|import|import-preview|doctor|blast|dedupe|snooze|audit-export|exclusive|similar|merge] [group_name] [username]",
     }); err != nil {
         return err
     }
 
+    p.registeredTrigger = trigger
     return nil
 }
 
+// ensureBot returns the ID of the plugin's bot user, creating it on first
+// activation.
+func (p *Plugin) ensureBot() (string, error) {
+    if user, appErr := p.API.GetUserByUsername(botUsername); appErr == nil {
+        if !user.IsBot {
+            return "", fmt.Errorf("user %s already exists and is not a bot", botUsername)
+        }
+        return user.Id, nil
+    }
+
+    bot, appErr := p.API.CreateBot(&model.Bot{
+        Username:    botUsername,
+        DisplayName: "Custom Groups",
+        Description: "Posts notices for custom group mentions.",
+    })
+    if appErr != nil {
+        return "", appErr
+    }
+
+    return bot.UserId, nil
+}
+
 // GetMentionKeywords returns the mention keywords for the plugin
 func (p *Plugin) GetMentionKeywords() []string {
     p.groupMutex.RLock()
     defer p.groupMutex.RUnlock()
 
+    return p.mentionKeywords(nil)
+}
+
+// mentionKeywords returns the sorted @group keywords, limited to the groups
+// the viewer can see when a viewer is given. Callers must hold groupMutex.
+func (p *Plugin) mentionKeywords(viewer *groupViewer) []string {
     keywords := make([]string, 0, len(p.groups))
-    for groupName := range p.groups {
+    for groupName, members := range p.groups {
+        if viewer != nil && !p.canSeeGroup(viewer, groupName, members) {
+            continue
+        }
         keywords = append(keywords, "@"+groupName)
     }
+    sort.Strings(keywords)
     return keywords
 }
 
+// handleGetKeywords returns the @group keywords the requesting user can see,
+// so clients can highlight group mentions. With channel_id the user must be
+// a member of that channel.
+func (p *Plugin) handleGetKeywords(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" {
+        http.Error(w, "Not authorized", http.StatusUnauthorized)
+        return
+    }
+
+    viewer := &groupViewer{UserID: userID, ChannelID: r.URL.Query().Get("channel_id")}
+    if viewer.ChannelID != "" {
+        channel, appErr := p.API.GetChannel(viewer.ChannelID)
+        if appErr != nil {
+            http.Error(w, "Channel not found", http.StatusNotFound)
+            return
+        }
+        if _, appErr := p.API.GetChannelMember(viewer.ChannelID, userID); appErr != nil {
+            http.Error(w, "Not a member of the channel", http.StatusForbidden)
+            return
+        }
+        viewer.TeamID = channel.TeamId
+    }
+
+    p.groupMutex.RLock()
+    keywords := p.mentionKeywords(viewer)
+    p.groupMutex.RUnlock()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(keywords)
+}
+
 // GetMentionsData returns the mention data for the plugin
 func (p *Plugin) GetMentionsData(channelID string) []string {
     p.groupMutex.RLock()
@@ -74,24 +253,75 @@ func (p *Plugin) GetMentionsData(channelID string) []string {
 }
 
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+    if r.Header.Get(readTokenHeader) != "" {
+        var ok bool
+        if r, ok = p.authenticateReadToken(w, r); !ok {
+            return
+        }
+    } else if !p.authenticate(w, r) {
+        return
+    }
+
     switch r.URL.Path {
     case "/api/v4/groups":
-        p.handleGroups(w, r)
+        p.withIdempotency(w, r, p.handleGroups)
     case "/api/v4/groups/members":
-        p.handleGroupMembers(w, r)
+        p.withIdempotency(w, r, p.handleGroupMembers)
+    case "/api/v4/groups/sync":
+        p.withIdempotency(w, r, p.handleSyncGroup)
+    case "/api/v4/groups/one":
+        p.handleGetGroup(w, r)
+    case "/api/v4/groups/search":
+        p.handleSearchGroups(w, r)
+    case "/api/v4/groups/import":
+        p.withIdempotency(w, r, p.handleBulkImport)
+    case "/api/v4/groups/import/confirm":
+        p.handleImportConfirm(w, r)
+    case "/api/v4/groups/backup", "/api/v4/groups/restore":
+        p.withIdempotency(w, r, p.handleBackup)
+    case "/api/v4/groups/stats":
+        p.handleStats(w, r)
+    case "/api/v4/groups/keywords":
+        p.handleGetKeywords(w, r)
+    case "/api/v4/groups/events":
+        p.handleGetEvents(w, r)
+    case "/debug/vars":
+        p.handleDebugVars(w, r)
     default:
         http.NotFound(w, r)
     }
 }
 
+// authenticate rejects requests that Mattermost did not authenticate, and
+// write requests by users that no longer exist. It reports whether the request
+// may continue.
+func (p *Plugin) authenticate(w http.ResponseWriter, r *http.Request) bool {
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" {
+        http.Error(w, "Not authorized", http.StatusUnauthorized)
+        return false
+    }
+
+    switch r.Method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return true
+    }
+
+    if _, appErr := p.API.GetUser(userID); appErr != nil {
+        http.Error(w, "Not authorized", http.StatusUnauthorized)
+        return false
+    }
+    return true
+}
+
 func (p *Plugin) handleGroups(w http.ResponseWriter, r *http.Request) {
     switch r.Method {
     case http.MethodGet:
-        p.getGroups(w, r)
+        p.handleGetGroups(w, r)
     case http.MethodPost:
-        p.createGroup(w, r)
+        p.handleCreateGroup(w, r)
     case http.MethodDelete:
-        p.deleteGroup(w, r)
+        p.handleDeleteGroup(w, r)
     default:
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
     }
@@ -100,113 +330,284 @@ func (p *Plugin) handleGroups(w http.ResponseWriter, r *http.Request) {
 func (p *Plugin) handleGroupMembers(w http.ResponseWriter, r *http.Request) {
     switch r.Method {
     case http.MethodPost:
-        p.addGroupMember(w, r)
+        p.handleAddGroupMember(w, r)
     case http.MethodDelete:
-        p.removeGroupMember(w, r)
+        p.handleRemoveGroupMember(w, r)
     default:
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
     }
 }
 
-func (p *Plugin) getGroups(w http.ResponseWriter, r *http.Request) {
-    p.groupMutex.RLock()
-    defer p.groupMutex.RUnlock()
+const (
+    // Groups per page of the groups endpoint unless per_page is given
+    defaultGroupsPerPage = 100
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(p.groups)
+    // Largest page of the groups endpoint
+    maxGroupsPerPage = 1000
+)
+
+// groupsPageParams reads the page and per_page query parameters of the groups
+// endpoint. Pages are numbered from 0 and hold defaultGroupsPerPage groups
+// unless per_page says otherwise.
+func groupsPageParams(r *http.Request) (page, perPage int, err error) {
+    query := r.URL.Query()
+    perPage = defaultGroupsPerPage
+    if value := query.Get("per_page"); value != "" {
+        if perPage, err = strconv.Atoi(value); err != nil || perPage <= 0 {
+            return 0, 0, fmt.Errorf("invalid per_page %q", value)
+        }
+        if perPage > maxGroupsPerPage {
+            perPage = maxGroupsPerPage
+        }
+    }
+    if value := query.Get("page"); value != "" {
+        if page, err = strconv.Atoi(value); err != nil || page < 0 {
+            return 0, 0, fmt.Errorf("invalid page %q", value)
+        }
+    }
+    return page, perPage, nil
 }
 
-func (p *Plugin) createGroup(w http.ResponseWriter, r *http.Request) {
-    var req struct {
-        Name string   `json:"name"`
-        Members []string `json:"members"`
+// pageOfNames returns the names on the page. Pages past the end are empty,
+// however large page is.
+func pageOfNames(names []string, page, perPage int) []string {
+    if page > len(names)/perPage {
+        return []string{}
     }
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+    start := page * perPage
+    if start >= len(names) {
+        return []string{}
+    }
+    end := start + perPage
+    if end > len(names) {
+        end = len(names)
+    }
+    return names[start:end]
+}
+
+// handleGetGroups returns the member IDs and descriptions of the groups on the
+// requested page, with groups ordered by name and the total number of groups
+// in the X-Total-Count header. With tag, only groups with that tag are
+// returned. With details=true it returns the groups with their metadata,
+// including the description and creator. Only the requested page is copied
+// under the read lock; it is serialized after the lock is released.
+func (p *Plugin) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+    page, perPage, err := groupsPageParams(r)
+    if err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
+    details := r.URL.Query().Get("details") == "true"
+    tag := r.URL.Query().Get("tag")
 
-    p.groupMutex.Lock()
-    defer p.groupMutex.Unlock()
+    p.groupMutex.RLock()
+    names := make([]string, 0, len(p.groups))
+    for groupName := range p.groups {
+        if p.hasTag(groupName, tag) {
+            names = append(names, groupName)
+        }
+    }
+    sort.Strings(names)
+    total := len(names)
+
+    var response interface{}
+    if details {
+        groups := make([]*GroupResponse, 0, perPage)
+        for _, groupName := range pageOfNames(names, page, perPage) {
+            groups = append(groups, &GroupResponse{
+                Name:     groupName,
+                Members:  append([]string{}, p.groups[groupName]...),
+                Metadata: p.groupMetadata[groupName].clone(),
+            })
+        }
+        response = groups
+    } else {
+        groups := make(map[string]*GroupSummary, perPage)
+        for _, groupName := range pageOfNames(names, page, perPage) {
+            groups[groupName] = p.groupSummary(groupName)
+        }
+        response = groups
+    }
+    p.groupMutex.RUnlock()
 
-    if _, exists := p.groups[req.Name]; exists {
-        http.Error(w, "Group already exists", http.StatusBadRequest)
+    data, err := json.Marshal(response)
+    if err != nil {
+        http.Error(w, "Failed to encode groups", http.StatusInternalServerError)
         return
     }
 
-    p.groups[req.Name] = req.Members
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("X-Total-Count", strconv.Itoa(total))
+    w.Write(data)
+}
+
+// leaveAllGroups removes the user from every group they belong to and
+// persists the change once. It returns the names of the groups left.
+func (p *Plugin) leaveAllGroups(userID string) ([]string, error) {
+    p.groupMutex.Lock()
+    left := []string{}
+    for groupName, members := range p.groups {
+        if !contains(members, userID) {
+            continue
+        }
+
+        newMembers := []string{}
+        for _, member := range members {
+            if member != userID {
+                newMembers = append(newMembers, member)
+            }
+        }
+        p.groups[groupName] = newMembers
+        p.touchGroup(groupName)
+        p.events.append(memberEvents(EventMemberRemoved, groupName, userID, []string{userID}))
+        p.forgetJoins(groupName, []string{userID})
+        left = append(left, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    if len(left) == 0 {
+        return left, nil
+    }
+
+    sort.Strings(left)
 
     // Save to persistent storage
-    if err := p.saveGroups(); err != nil {
-        http.Error(w, "Failed to save group", http.StatusInternalServerError)
-        return
-    }
+    return left, p.saveGroupState()
+}
 
-    w.WriteHeader(http.StatusCreated)
+// GroupResponse is the representation of a single group returned by the
+// REST API.
+type GroupResponse struct {
+    Name     string         `json:"name"`
+    Members  []string       `json:"members"`
+    Metadata *GroupMetadata `json:"metadata,omitempty"`
+}
+
+// GroupSummary is the representation of a group in the groups endpoint's
+// default response, keyed by group name.
+type GroupSummary struct {
+    Members     []string `json:"members"`
+    Description string   `json:"description"`
+    CreatedBy   string   `json:"created_by,omitempty"`
+    CreatedAt   int64    `json:"created_at,omitempty"`
+}
+
+// groupSummary returns the summary of the group, sharing no slices with the
+// group. Callers must hold groupMutex.
+func (p *Plugin) groupSummary(groupName string) *GroupSummary {
+    summary := &GroupSummary{Members: append([]string{}, p.groups[groupName]...)}
+    if metadata := p.groupMetadata[groupName]; metadata != nil {
+        summary.Description = metadata.Description
+        summary.CreatedBy = metadata.CreatedBy
+        summary.CreatedAt = metadata.CreatedAt
+    }
+    return summary
 }
 
-func (p *Plugin) deleteGroup(w http.ResponseWriter, r *http.Request) {
-    groupName := r.URL.Query().Get("name")
+func (p *Plugin) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    groupName := canonicalGroupName(r.URL.Query().Get("name"))
     if groupName == "" {
         http.Error(w, "Group name is required", http.StatusBadRequest)
         return
     }
 
-    p.groupMutex.Lock()
-    defer p.groupMutex.Unlock()
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
 
-    if _, exists := p.groups[groupName]; !exists {
-        http.Error(w, "Group not found", http.StatusNotFound)
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.writeError(w, groupError(ErrGroupNotFound, groupName))
         return
     }
 
-    delete(p.groups, groupName)
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(&GroupResponse{
+        Name:     groupName,
+        Members:  members,
+        Metadata: p.groupMetadata[groupName],
+    })
+}
 
-    // Save to persistent storage
-    if err := p.saveGroups(); err != nil {
-        http.Error(w, "Failed to save changes", http.StatusInternalServerError)
+func (p *Plugin) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+    if !p.requireManager(w, r) {
         return
     }
 
-    w.WriteHeader(http.StatusOK)
-}
-
-func (p *Plugin) addGroupMember(w http.ResponseWriter, r *http.Request) {
     var req struct {
-        GroupName string `json:"group_name"`
-        UserID    string `json:"user_id"`
+        Name string   `json:"name"`
+        Members []string `json:"members"`
+        Description string `json:"description"`
+        Subgroups []string `json:"subgroups"`
     }
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
 
-    p.groupMutex.Lock()
-    defer p.groupMutex.Unlock()
+    // Members are user IDs; other groups are included through subgroups
+    for _, member := range req.Members {
+        if !model.IsValidId(member) {
+            http.Error(w, fmt.Sprintf("Invalid member %q: members must be user IDs, include other groups with subgroups", member), http.StatusBadRequest)
+            return
+        }
+    }
 
-    members, exists := p.groups[req.GroupName]
-    if !exists {
-        http.Error(w, "Group not found", http.StatusNotFound)
+    groupName := canonicalGroupName(req.Name)
+    refs := []string{}
+    for _, ref := range req.Subgroups {
+        if ref = canonicalGroupName(strings.TrimPrefix(ref, "@")); ref != "" && !contains(refs, ref) {
+            refs = append(refs, ref)
+        }
+    }
+    if err := p.checkSubgroupRefs(groupName, refs); err != nil {
+        p.writeError(w, err)
         return
     }
 
-    if contains(members, req.UserID) {
-        http.Error(w, "User already in group", http.StatusBadRequest)
+    if err := p.createGroup(groupName, req.Members, strings.TrimSpace(req.Description), r.Header.Get("Mattermost-User-Id")); err != nil {
+        p.writeError(w, err)
         return
     }
 
-    p.groups[req.GroupName] = append(members, req.UserID)
+    if len(refs) > 0 {
+        if _, _, err := p.addSubgroups(groupName, refs); err != nil {
+            p.writeError(w, err)
+            return
+        }
+    }
 
-    // Save to persistent storage
-    if err := p.saveGroups(); err != nil {
-        http.Error(w, "Failed to save changes", http.StatusInternalServerError)
+    w.WriteHeader(http.StatusCreated)
+}
+
+func (p *Plugin) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+    if !p.requireManager(w, r) {
+        return
+    }
+
+    groupName := canonicalGroupName(r.URL.Query().Get("name"))
+    if groupName == "" {
+        http.Error(w, "Group name is required", http.StatusBadRequest)
+        return
+    }
+
+    if err := p.deleteGroup(groupName, r.Header.Get("Mattermost-User-Id")); err != nil {
+        p.writeError(w, err)
         return
     }
 
     w.WriteHeader(http.StatusOK)
 }
 
-func (p *Plugin) removeGroupMember(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+    if !p.requireManager(w, r) {
+        return
+    }
+
     var req struct {
         GroupName string `json:"group_name"`
         UserID    string `json:"user_id"`
@@ -216,79 +617,204 @@ func (p *Plugin) removeGroupMember(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    p.groupMutex.Lock()
-    defer p.groupMutex.Unlock()
-
-    members, exists := p.groups[req.GroupName]
-    if !exists {
-        http.Error(w, "Group not found", http.StatusNotFound)
+    req.GroupName = canonicalGroupName(req.GroupName)
+    if _, err := p.addGroupMember(req.GroupName, req.UserID, r.Header.Get("Mattermost-User-Id")); err != nil {
+        p.writeError(w, err)
         return
     }
 
-    var newMembers []string
-    for _, member := range members {
-        if member != req.UserID {
-            newMembers = append(newMembers, member)
-        }
-    }
+    w.WriteHeader(http.StatusOK)
+}
 
-    if len(newMembers) == len(members) {
-        http.Error(w, "User not in group", http.StatusBadRequest)
+func (p *Plugin) handleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+    if !p.requireManager(w, r) {
         return
     }
 
-    p.groups[req.GroupName] = newMembers
+    var req struct {
+        GroupName string `json:"group_name"`
+        UserID    string `json:"user_id"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
 
-    // Save to persistent storage
-    if err := p.saveGroups(); err != nil {
-        http.Error(w, "Failed to save changes", http.StatusInternalServerError)
+    req.GroupName = canonicalGroupName(req.GroupName)
+    if err := p.removeGroupMember(req.GroupName, req.UserID, r.Header.Get("Mattermost-User-Id")); err != nil {
+        p.writeError(w, err)
         return
     }
 
     w.WriteHeader(http.StatusOK)
 }
 
-func (p *Plugin) saveGroups() error {
-    p.groupMutex.RLock()
-    defer p.groupMutex.RUnlock()
-    
-    data, err := json.Marshal(p.groups)
-    if err != nil {
-        return err
+// createGroup creates a group with the given member IDs and persists it.
+// actorID is the creating user's ID.
+func (p *Plugin) createGroup(groupName string, members []string, description, actorID string) error {
+    groupName = canonicalGroupName(groupName)
+    if config.GetConfig().IsReservedGroupName(groupName) {
+        return groupError(ErrReservedName, groupName)
     }
-    
-    if err := p.API.KVSet(groupsKey, data); err != nil {
-        return err
+
+    p.groupMutex.Lock()
+    if p.groupNameTaken(groupName) {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupExists, groupName)
     }
-    
-    return nil
+
+    if members == nil {
+        members = []string{}
+    }
+    p.groups[groupName] = members
+    p.touchGroup(groupName)
+    metadata := p.metadataFor(groupName)
+    metadata.Description = description
+    metadata.CreatedBy = actorID
+    p.events.append(memberEvents(EventMemberAdded, groupName, actorID, members))
+    p.recordJoins(groupName, members)
+    p.enforceExclusive(groupName, members, actorID)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupState()
 }
 
-func (p *Plugin) UserAutocompleteInChannel(c *plugin.Context, channelID string, teamID string, term string, limit int) ([]*model.User, *model.AppError) {
-    if !strings.HasPrefix(term, "@") {
-        return nil, nil
+// deleteGroup moves a group to the trash, where it can be restored until it
+// is purged, and persists the change. deletedBy is the deleting user's ID.
+func (p *Plugin) deleteGroup(groupName, deletedBy string) error {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
     }
 
-    searchTerm := strings.TrimPrefix(term, "@")
-    var suggestions []*model.User
+    now := time.Now()
+    p.purgeTrash(now)
+    p.groupTrash[groupName] = &DeletedGroup{
+        Members:   members,
+        Metadata:  p.groupMetadata[groupName],
+        DeletedAt: model.GetMillisForTime(now),
+        DeletedBy: deletedBy,
+    }
 
-    p.groupMutex.RLock()
-    defer p.groupMutex.RUnlock()
+    delete(p.groups, groupName)
+    _, hadMetadata := p.groupMetadata[groupName]
+    delete(p.groupMetadata, groupName)
+    p.events.append(memberEvents(EventMemberRemoved, groupName, deletedBy, members))
+    p.groupMutex.Unlock()
 
-    for groupName, members := range p.groups {
-        if searchTerm == "" || strings.HasPrefix(strings.ToLower(groupName), strings.ToLower(searchTerm)) {
-            // Get member usernames for display
-            var memberNames []string
-            for _, memberID := range members {
-                if user, err := p.API.GetUser(memberID); err == nil {
-                    memberNames = append(memberNames, "@"+user.Username)
-                }
-            }
+    if err := p.saveGroupTrash(); err != nil {
+        return err
+    }
 
-            // Create a special user object for the group
-            suggestion := &model.User{
-                Username:    groupName,
-                Id:         fmt.Sprintf("group_%s", groupName),
+    if err := p.saveGroupEvents(); err != nil {
+        return err
+    }
+
+    if hadMetadata {
+        if err := p.saveGroupMetadata(); err != nil {
+            return err
+        }
+    }
+
+    // Save to persistent storage
+    return p.saveGroups()
+}
+
+// addGroupMember adds a user ID to a group and persists the change. actorID
+// is the ID of the user making the change.
+func (p *Plugin) addGroupMember(groupName, userID, actorID string) ([]ExclusiveRemoval, error) {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    if contains(members, userID) {
+        p.groupMutex.Unlock()
+        return nil, groupError(ErrAlreadyMember, groupName)
+    }
+
+    p.groups[groupName] = append(members, userID)
+    p.touchGroup(groupName)
+    p.events.append(memberEvents(EventMemberAdded, groupName, actorID, []string{userID}))
+    p.recordJoins(groupName, []string{userID})
+    removals := p.enforceExclusive(groupName, []string{userID}, actorID)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.resolveRemovals(removals), p.saveGroupState()
+}
+
+// removeGroupMember removes a user ID from a group and persists the change.
+// actorID is the ID of the user making the change.
+func (p *Plugin) removeGroupMember(groupName, userID, actorID string) error {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    newMembers := []string{}
+    for _, member := range members {
+        if member != userID {
+            newMembers = append(newMembers, member)
+        }
+    }
+
+    if len(newMembers) == len(members) {
+        p.groupMutex.Unlock()
+        return groupError(ErrNotMember, groupName)
+    }
+
+    p.groups[groupName] = newMembers
+    p.touchGroup(groupName)
+    p.events.append(memberEvents(EventMemberRemoved, groupName, actorID, []string{userID}))
+    p.forgetJoins(groupName, []string{userID})
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupState()
+}
+
+func (p *Plugin) UserAutocompleteInChannel(c *plugin.Context, channelID string, teamID string, term string, limit int) ([]*model.User, *model.AppError) {
+    if !config.GetConfig().AutocompleteEnabled || !strings.HasPrefix(term, "@") {
+        return nil, nil
+    }
+
+    viewer, ok := p.viewerFromContext(c, channelID, teamID)
+    if !ok {
+        return nil, nil
+    }
+
+    searchTerm := strings.TrimPrefix(term, "@")
+    var suggestions []*model.User
+
+    p.rlockGroups()
+    defer p.groupMutex.RUnlock()
+
+    for groupName, members := range p.groups {
+        if !p.canSeeGroup(viewer, groupName, members) {
+            continue
+        }
+
+        if searchTerm == "" || strings.HasPrefix(strings.ToLower(groupName), strings.ToLower(searchTerm)) {
+            // Get member usernames for display
+            var memberNames []string
+            for _, memberID := range members {
+                if user, err := p.API.GetUser(memberID); err == nil {
+                    memberNames = append(memberNames, "@"+user.Username)
+                }
+            }
+
+            // Create a special user object for the group
+            suggestion := &model.User{
+                Username:    groupName,
+                Id:         fmt.Sprintf("group_%s", groupName),
                 Email:      fmt.Sprintf("%s@groups.local", groupName),
                 FirstName:  "Group",
                 LastName:   fmt.Sprintf("(%d members)", len(members)),
@@ -300,380 +826,1467 @@ func (p *Plugin) UserAutocompleteInChannel(c *plugin.Context, channelID string,
         }
     }
 
-    if len(suggestions) > limit {
-        suggestions = suggestions[:limit]
+    // Pinned groups come first, then groups in name order
+    sort.SliceStable(suggestions, func(i, j int) bool {
+        iPinned, jPinned := p.isPinned(suggestions[i].Username), p.isPinned(suggestions[j].Username)
+        if iPinned != jPinned {
+            return iPinned
+        }
+        return suggestions[i].Username < suggestions[j].Username
+    })
+
+    // Leave room for the real users the server suggests alongside groups
+    if len(suggestions) > 0 {
+        groupLimit := groupSuggestionLimit(limit, config.GetConfig().AutocompleteUserShare, func() bool {
+            users, appErr := p.API.SearchUsers(&model.UserSearch{Term: searchTerm, TeamId: teamID, Limit: 1})
+            return appErr == nil && len(users) > 0
+        })
+        if len(suggestions) > groupLimit {
+            suggestions = suggestions[:groupLimit]
+        }
     }
 
+    p.metrics.autocomplete(len(suggestions))
+
     return suggestions, nil
 }
 
+// groupSuggestionLimit returns how many of the limit autocomplete suggestions
+// may be groups. userSharePercent of the limit, rounded up, is reserved for
+// real users, and at least one slot when usersMatch reports matching users.
+// At least one group is kept whenever limit allows any suggestion.
+func groupSuggestionLimit(limit, userSharePercent int, usersMatch func() bool) int {
+    if limit <= 1 {
+        if limit < 0 {
+            return 0
+        }
+        return limit
+    }
+
+    reserved := (limit*userSharePercent + 99) / 100
+    if reserved == 0 && usersMatch() {
+        reserved = 1
+    }
+    if reserved > limit-1 {
+        reserved = limit - 1
+    }
+
+    return limit - reserved
+}
+
 func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
-    p.groupMutex.RLock()
+    p.rlockGroups()
+    defer p.groupMutex.RUnlock()
+
+    // Group mention metadata is only set by this hook, so a new post carrying
+    // it is a repost, e.g. by a bot, whose members were already notified
+    delete(post.Props, "group_mentions")
+
+    p.expandGroupMentions(post, nil)
+
+    return post, ""
+}
+
+// MessageWillBeUpdated expands group mentions added by an edit. Groups that
+// were already expanded in the original post are left untouched so their
+// expanded text is not expanded a second time.
+func (p *Plugin) MessageWillBeUpdated(c *plugin.Context, newPost, oldPost *model.Post) (*model.Post, string) {
+    p.rlockGroups()
     defer p.groupMutex.RUnlock()
 
+    p.expandGroupMentions(newPost, mentionedGroups(oldPost))
+
+    return newPost, ""
+}
+
+// expandGroupMentions adds mention metadata for every group mentioned in the
+// post and rewrites the mention to show the group members. Groups in skip are
+// ignored. Callers must hold groupMutex.
+func (p *Plugin) expandGroupMentions(post *model.Post, skip map[string]bool) {
+    // Posts without a mention token are left untouched
+    if !strings.Contains(post.Message, "@") {
+        return
+    }
+
+    // Find the groups mentioned before touching the post. Mentions ignore
+    // case, so @Dev written before names were lowercased still mentions dev.
+    // Text that was already expanded, e.g. in a repost, is not expanded again.
+    lowerMessage := strings.ToLower(post.Message)
+    var matched []string
+    consider := func(groupName string) {
+        if skip[groupName] || !strings.Contains(lowerMessage, "@"+groupName) {
+            return
+        }
+        mentions := groupMentionIndexes(post.Message, groupName)
+        if len(mentions) == 0 || alreadyExpanded(post.Message, mentions) {
+            return
+        }
+        matched = append(matched, groupName)
+    }
+    for groupName := range p.groups {
+        consider(groupName)
+    }
+    for groupName := range p.dynamicGroups {
+        consider(groupName)
+    }
+    if len(matched) == 0 {
+        return
+    }
+
+    p.metrics.expansion(len(matched))
+
     if post.Props == nil {
         post.Props = make(model.StringInterface)
     }
 
+    maxLength := config.GetConfig().MaxMessageLength
+
     // Initialize mentions map
     mentions := map[string]interface{}{}
     if existingMentions, ok := post.Props["mentions"].(map[string]interface{}); ok {
         mentions = existingMentions
     }
 
-    // Check for group mentions
-    for groupName, members := range p.groups {
-        mention := fmt.Sprintf("@%s", groupName)
-        if strings.Contains(post.Message, mention) {
-            // Add all group members to mentions
-            for _, userID := range members {
-                mentions[userID] = map[string]interface{}{
-                    "type": "mention",
-                    "group": groupName,
-                    "group_mention": true,
-                }
+    // Check for group mentions, including only currently scheduled members
+    // and the members of included groups
+    now := time.Now()
+    for _, groupName := range matched {
+        members := p.resolveMembers(groupName, now)
+        if dynamic, ok := p.dynamicGroups[groupName]; ok {
+            resolved, err := p.dynamicMembers(dynamic, post.UserId)
+            if err != nil {
+                p.API.LogWarn("Dynamic group mention not expanded", "group", groupName, "error", err.Error())
+                continue
+            }
+            members = resolved
+        }
+        // Add all group members to mentions
+        for _, userID := range members {
+            mentions[userID] = map[string]interface{}{
+                "type": "mention",
+                "group": groupName,
+                "group_mention": true,
             }
+        }
 
-            // Add special mention metadata
-            post.Props["special_mention"] = true
-            post.Props["system_mention"] = true
-            post.Props["channel_mentions"] = true
+        // Add special mention metadata
+        post.Props["special_mention"] = true
+        post.Props["system_mention"] = true
+        post.Props["channel_mentions"] = true
 
-            // Add group mention metadata
-            if groupMentions, ok := post.Props["group_mentions"].([]interface{}); ok {
-                post.Props["group_mentions"] = append(groupMentions, map[string]interface{}{
-                    "group": groupName,
-                    "members": members,
-                })
-            } else {
-                post.Props["group_mentions"] = []interface{}{
-                    map[string]interface{}{
-                        "group": groupName,
-                        "members": members,
-                    },
+        // Add group mention metadata
+        groupMention := map[string]interface{}{
+            "group": groupName,
+            "members": members,
+        }
+        if metadata, ok := p.groupMetadata[groupName]; ok {
+            if metadata.Color != "" {
+                groupMention["color"] = metadata.Color
+            }
+            if metadata.Label != "" {
+                groupMention["label"] = metadata.Label
+            }
+            if metadata.Urgent {
+                groupMention["priority"] = postPriorityUrgent
+                post.Props["priority"] = postPriorityUrgent
+            }
+        }
+        if groupMentions, ok := post.Props["group_mentions"].([]interface{}); ok {
+            post.Props["group_mentions"] = append(groupMentions, groupMention)
+        } else {
+            post.Props["group_mentions"] = []interface{}{groupMention}
+        }
+
+        // Get member usernames for display
+        var memberNames []string
+        for _, memberID := range members {
+            if user, err := p.API.GetUser(memberID); err == nil {
+                memberNames = append(memberNames, "@"+user.Username)
+            }
+        }
+
+        // Update message with group indicator and members, sharing the
+        // room left under the length cap between all occurrences. Earlier
+        // expansions moved the text, so the mentions are found again.
+        occurrences := groupMentionIndexes(post.Message, groupName)
+        if len(occurrences) == 0 {
+            continue
+        }
+        rest := utf8.RuneCountInString(post.Message)
+        for _, occurrence := range occurrences {
+            rest -= utf8.RuneCountInString(post.Message[occurrence[0]:occurrence[1]])
+        }
+        budget := (maxLength - rest) / len(occurrences)
+        post.Message = replaceMentions(
+            post.Message,
+            occurrences,
+            expandedMention(groupName, len(members), memberNames, budget),
+        )
+
+        // Add special props for UI rendering
+        post.Props["group_mention_highlight"] = true
+        post.Props["override_icon_url"] = "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png"
+    }
+
+    // Update mentions in post props
+    if len(mentions) > 0 {
+        post.Props["mentions"] = mentions
+    }
+}
+
+// expandedMention renders a group mention with its member list, dropping
+// trailing members so the text fits in budget characters. Whole usernames are
+// dropped, never cut. When even the member count does not fit, the mention is
+// left as is.
+func expandedMention(groupName string, memberCount int, memberNames []string, budget int) string {
+    full := fmt.Sprintf("@%s (Group - %d members: %s)", groupName, memberCount, strings.Join(memberNames, ", "))
+    if utf8.RuneCountInString(full) <= budget {
+        return full
+    }
+
+    summary := fmt.Sprintf("@%s (Group - %d members)", groupName, memberCount)
+    if utf8.RuneCountInString(summary) > budget {
+        return "@" + groupName
+    }
+
+    // Keep as many leading members as fit alongside the "and N more" suffix,
+    // which is at most as long as its form for the whole list
+    prefix := fmt.Sprintf("@%s (Group - %d members: ", groupName, memberCount)
+    suffix := fmt.Sprintf(", and %d more)", len(memberNames))
+    length := utf8.RuneCountInString(prefix) + utf8.RuneCountInString(suffix)
+    shown := 0
+    for i, name := range memberNames {
+        nameLength := utf8.RuneCountInString(name)
+        if i > 0 {
+            nameLength += len(", ")
+        }
+        if length+nameLength > budget {
+            break
+        }
+        length += nameLength
+        shown++
+    }
+
+    if shown == 0 {
+        return summary
+    }
+
+    return fmt.Sprintf("%s%s, and %d more)", prefix, strings.Join(memberNames[:shown], ", "), len(memberNames)-shown)
+}
+
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+    p.rlockGroups()
+    snoozed := p.snoozeRemaining(post.ChannelId, time.Now()) > 0
+    p.trackMentions(post, nil)
+    p.hintUnknownGroups(post)
+    p.groupMutex.RUnlock()
+
+    // Snoozed channels keep the mention props but notify nobody
+    if !snoozed {
+        p.notifyGroupMentions(post, nil)
+    }
+}
+
+// MessageHasBeenUpdated notifies members of groups that were mentioned for
+// the first time by the edit.
+func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, newPost, oldPost *model.Post) {
+    skip := mentionedGroups(oldPost)
+
+    p.rlockGroups()
+    snoozed := p.snoozeRemaining(newPost.ChannelId, time.Now()) > 0
+    p.trackMentions(newPost, skip)
+    p.groupMutex.RUnlock()
+
+    if !snoozed {
+        p.notifyGroupMentions(newPost, skip)
+    }
+}
+
+// mentionedGroups returns the names of the groups recorded in the post's
+// group mention metadata.
+func mentionedGroups(post *model.Post) map[string]bool {
+    groups := make(map[string]bool)
+    if post == nil {
+        return groups
+    }
+
+    if groupMentions, ok := post.Props["group_mentions"].([]interface{}); ok {
+        for _, mention := range groupMentions {
+            if groupMention, ok := mention.(map[string]interface{}); ok {
+                if groupName, ok := groupMention["group"].(string); ok {
+                    groups[groupName] = true
                 }
             }
+        }
+    }
 
-            // Get member usernames for display
-            var memberNames []string
-            for _, memberID := range members {
-                if user, err := p.API.GetUser(memberID); err == nil {
-                    memberNames = append(memberNames, "@"+user.Username)
+    return groups
+}
+
+// mentionMembers reads the member IDs stored in group mention metadata, which
+// are a []string before the post is stored and a []interface{} after.
+func mentionMembers(value interface{}) ([]string, bool) {
+    switch members := value.(type) {
+    case []string:
+        return members, true
+    case []interface{}:
+        ids := make([]string, 0, len(members))
+        for _, member := range members {
+            if id, ok := member.(string); ok {
+                ids = append(ids, id)
+            }
+        }
+        return ids, true
+    default:
+        return nil, false
+    }
+}
+
+// notifyGroupMentions sends a notification to the members of every group
+// mentioned in the post, except the groups in skip and the members excluded
+// by recipientFilter. When the post would generate more notifications than
+// MaxNotificationsPerPost, a single channel notice is posted instead and the
+// author is warned. The members come from the post's mention metadata, so
+// groupMutex is only taken to copy the groups' templates; callers must not
+// hold it, as the mention policy and email sends can be slow.
+func (p *Plugin) notifyGroupMentions(post *model.Post, skip map[string]bool) {
+    // Get the post author's username
+    postAuthor, err := p.API.GetUser(post.UserId)
+    if err != nil {
+        return
+    }
+
+    // Check if post has group mentions
+    groupMentions, ok := post.Props["group_mentions"].([]interface{})
+    if !ok {
+        return
+    }
+
+    type groupMention struct {
+        name       string
+        members    []string
+        recipients []string
+    }
+
+    filter := p.newRecipientFilter(post)
+
+    // Members already notified in this thread are not pinged again within
+    // the window
+    now := time.Now()
+    thread := threadID(post)
+    window := time.Duration(config.GetConfig().ThreadNotificationWindow) * time.Minute
+    alreadyNotified := map[string]bool{}
+    if window > 0 {
+        alreadyNotified = p.threadNotifications.recentlyNotified(thread, window, now)
+    }
+
+    groupNames := []string{}
+    for _, mention := range groupMentions {
+        if mentionProps, ok := mention.(map[string]interface{}); ok {
+            if groupName, _ := mentionProps["group"].(string); !skip[groupName] {
+                groupNames = append(groupNames, groupName)
+            }
+        }
+    }
+    allowed := p.allowedMentions(groupNames, post)
+
+    var mentioned []groupMention
+    recipients := make(map[string]bool)
+    for _, mention := range groupMentions {
+        if mentionProps, ok := mention.(map[string]interface{}); ok {
+            groupName, _ := mentionProps["group"].(string)
+            if skip[groupName] || !allowed[groupName] {
+                continue
+            }
+            if members, ok := mentionMembers(mentionProps["members"]); ok {
+                groupRecipients := []string{}
+                for _, userID := range filter.filter(members) {
+                    if !alreadyNotified[userID] {
+                        groupRecipients = append(groupRecipients, userID)
+                    }
                 }
+                mentioned = append(mentioned, groupMention{name: groupName, members: members, recipients: groupRecipients})
+                for _, userID := range groupRecipients {
+                    recipients[userID] = true
+                }
+            }
+        }
+    }
+
+    if len(recipients) == 0 {
+        return
+    }
+
+    // Get the channel where the mention occurred
+    channel, appErr := p.API.GetChannel(post.ChannelId)
+    if appErr != nil {
+        return
+    }
+
+    if limit := config.GetConfig().MaxNotificationsPerPost; limit > 0 && len(recipients) > limit {
+        p.notifyOverLimit(post, channel, mentioned[0].name, len(mentioned), len(recipients), limit)
+        return
+    }
+
+    permalink := p.postPermalink(post, channel)
+
+    templates := make(map[string]string, len(mentioned))
+    emailGroups := make(map[string]bool)
+    p.rlockGroups()
+    for _, mention := range mentioned {
+        if metadata, ok := p.groupMetadata[mention.name]; ok {
+            templates[mention.name] = metadata.Template
+            emailGroups[mention.name] = metadata.EmailOffline
+        }
+    }
+    p.groupMutex.RUnlock()
+    if len(emailGroups) > 0 && !p.emailEnabled() {
+        emailGroups = nil
+    }
+
+    var emails []offlineEmail
+    for _, mention := range mentioned {
+        // Get member usernames for display
+        var memberNames []string
+        for _, memberID := range mention.members {
+            if user, err := p.API.GetUser(memberID); err == nil {
+                memberNames = append(memberNames, "@"+user.Username)
             }
+        }
 
-            // Update message with group indicator and members
-            post.Message = strings.ReplaceAll(
-                post.Message,
-                mention,
-                fmt.Sprintf("@%s (Group - %d members: %s)", 
-                    groupName, 
-                    len(members),
+        // Render the group's custom template once for all members
+        customMessage, hasCustomMessage := renderNotification(templates[mention.name], notificationData{
+            Author:  postAuthor.Username,
+            Channel: channel.Name,
+            Group:   mention.name,
+            Members: strings.Join(memberNames, ", "),
+        })
+
+        // Send notifications to each member except the excluded ones
+        for _, userID := range mention.recipients {
+            message := customMessage
+            if !hasCustomMessage {
+                message = translate(p.userLocale(userID), "notification.mention",
+                    mention.name,
+                    postAuthor.Username,
+                    channel.Name,
                     strings.Join(memberNames, ", "),
-                ),
-            )
+                )
+            }
+
+            if permalink != "" {
+                message += "\n" + translate(p.userLocale(userID), "notification.permalink", permalink)
+            }
 
-            // Add special props for UI rendering
-            post.Props["group_mention_highlight"] = true
-            post.Props["override_icon_url"] = "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png"
+            // Create mention notification
+            p.API.SendEphemeralPost(userID, &model.Post{
+                UserId:    post.UserId,
+                ChannelId: post.ChannelId,
+                RootId:    post.RootId,
+                Message:   message,
+                Props: model.StringInterface{
+                    "from_webhook": "true",
+                    "override_username": "Group Mention",
+                    "override_icon_url": "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
+                },
+            })
+
+            // Offline members of critical groups are emailed as well
+            if emailGroups[mention.name] {
+                emails = append(emails, offlineEmail{
+                    userID:  userID,
+                    subject: translate(p.userLocale(userID), "notification.email_subject", mention.name),
+                    message: message,
+                })
+            }
         }
     }
 
-    // Update mentions in post props
-    if len(mentions) > 0 {
-        post.Props["mentions"] = mentions
+    // One SMTP send per offline member is too slow to wait for
+    if len(emails) > 0 {
+        go p.emailIfOffline(emails)
+    }
+
+    if window > 0 {
+        notified := make([]string, 0, len(recipients))
+        for userID := range recipients {
+            notified = append(notified, userID)
+        }
+        p.threadNotifications.record(thread, notified, window, now)
+    }
+}
+
+// postPermalink returns the link to a post, or an empty string when the site
+// URL is not configured. Posts in DMs and group messages have no team, so
+// they are linked through the server's redirect.
+func (p *Plugin) postPermalink(post *model.Post, channel *model.Channel) string {
+    config := p.API.GetConfig()
+    if config == nil || config.ServiceSettings.SiteURL == nil || *config.ServiceSettings.SiteURL == "" {
+        return ""
+    }
+    siteURL := strings.TrimSuffix(*config.ServiceSettings.SiteURL, "/")
+
+    if channel.TeamId != "" {
+        if team, appErr := p.API.GetTeam(channel.TeamId); appErr == nil {
+            return fmt.Sprintf("%s/%s/pl/%s", siteURL, team.Name, post.Id)
+        }
+    }
+
+    return fmt.Sprintf("%s/_redirect/pl/%s", siteURL, post.Id)
+}
+
+// notifyOverLimit replaces individual notifications with a single notice in
+// the post's thread and warns the author that the cap was reached.
+func (p *Plugin) notifyOverLimit(post *model.Post, channel *model.Channel, firstGroup string, groupCount, recipientCount, limit int) {
+    locale := p.userLocale(post.UserId)
+
+    groupsText := fmt.Sprintf("@%s", firstGroup)
+    if groupCount > 1 {
+        groupsText = translate(locale, "notification.groups", groupCount)
+    }
+
+    rootID := post.RootId
+    if rootID == "" {
+        rootID = post.Id
+    }
+
+    if _, appErr := p.API.CreatePost(&model.Post{
+        UserId:    p.botID,
+        ChannelId: channel.Id,
+        RootId:    rootID,
+        Message:   translate(locale, "notification.limit_notice", groupsText, recipientCount, limit),
+    }); appErr != nil {
+        p.API.LogError("Failed to post group mention notice", "error", appErr.Error())
+    }
+
+    p.API.SendEphemeralPost(post.UserId, &model.Post{
+        UserId:    p.botID,
+        ChannelId: channel.Id,
+        Message:   translate(locale, "notification.limit_warning", recipientCount, limit),
+    })
+}
+
+// groupInfo renders one page of a group's members. Only the members on the
+// requested page are resolved to usernames.
+func (p *Plugin) groupInfo(groupName string, page int) (string, error) {
+    p.groupMutex.RLock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.RUnlock()
+        return "", groupError(ErrGroupNotFound, groupName)
+    }
+    members = append([]string(nil), members...)
+    joined := make(map[string]int64, len(members))
+    for _, userID := range members {
+        joined[userID] = p.joinedAt(groupName, userID)
+    }
+    var description, createdBy string
+    var subgroups []string
+    if metadata, ok := p.groupMetadata[groupName]; ok {
+        description = metadata.Description
+        createdBy = metadata.CreatedBy
+        subgroups = append(subgroups, metadata.Subgroups...)
+    }
+    p.groupMutex.RUnlock()
+
+    pageSize := config.GetConfig().MembersPageSize
+    totalPages := (len(members) + pageSize - 1) / pageSize
+    if totalPages == 0 {
+        totalPages = 1
+    }
+    if page > totalPages {
+        return "", fmt.Errorf("page %d does not exist, group %s has %d page(s)", page, groupName, totalPages)
     }
 
-    return post, ""
-}
+    start := (page - 1) * pageSize
+    end := start + pageSize
+    if end > len(members) {
+        end = len(members)
+    }
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("**%s** (%d members) - page %d of %d\n", groupName, len(members), page, totalPages))
+    if description != "" {
+        text.WriteString(fmt.Sprintf("_%s_\n", description))
+    }
+    if len(subgroups) > 0 {
+        text.WriteString(fmt.Sprintf("Includes the members of @%s\n", strings.Join(subgroups, ", @")))
+    }
+    if createdBy != "" {
+        if creator, err := p.API.GetUser(createdBy); err == nil {
+            text.WriteString(fmt.Sprintf("Created by @%s\n", creator.Username))
+        }
+    }
+    for _, userID := range members[start:end] {
+        if user, err := p.API.GetUser(userID); err == nil {
+            text.WriteString(fmt.Sprintf("- @%s (joined %s)\n", user.Username, joinDateText(joined[userID])))
+        }
+    }
+    if page < totalPages {
+        text.WriteString(fmt.Sprintf("\nUse `/%s info %s %d` for the next page.", config.GetConfig().CommandTrigger, groupName, page+1))
+    }
+
+    return text.String(), nil
+}
+
+// ExportResult describes the outcome of an export for programmatic use.
+type ExportResult struct {
+    Group   string   `json:"group"`
+    Count   int      `json:"count"`
+    Members []string `json:"members"`
+    Errors  []string `json:"errors"` // member IDs that could not be resolved
+    Tags    []string `json:"tags"`
+
+    // JoinedAt has the join date of each exported member, in milliseconds
+    // since epoch, or 0 when it is unknown
+    JoinedAt map[string]int64 `json:"joined_at"` // map[username]time
+}
+
+// ImportResult describes the outcome of an import for programmatic use.
+type ImportResult struct {
+    Group   string   `json:"group"`
+    Added   []string `json:"added"`
+    Skipped []string `json:"skipped"` // usernames already in the group
+    Errors  []string `json:"errors"`  // usernames that could not be found
+
+    // Exclusive lists memberships removed because an added user was in
+    // another group of a mutually exclusive set
+    Exclusive []ExclusiveRemoval `json:"exclusive_removals,omitempty"`
+}
+
+func (p *Plugin) exportGroup(groupName string) (*ExportResult, error) {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    members, exists := p.groups[groupName]
+    if !exists {
+        return nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    result := &ExportResult{
+        Group:    groupName,
+        Members:  make([]string, 0, len(members)),
+        Errors:   []string{},
+        Tags:     append([]string{}, p.groupTags(groupName)...),
+        JoinedAt: make(map[string]int64, len(members)),
+    }
+    for _, memberID := range members {
+        if user, err := p.API.GetUser(memberID); err == nil {
+            result.Members = append(result.Members, user.Username)
+            result.JoinedAt[user.Username] = p.joinedAt(groupName, memberID)
+        } else {
+            result.Errors = append(result.Errors, memberID)
+        }
+    }
+    result.Count = len(result.Members)
+
+    return result, nil
+}
+
+// planImport resolves the usernames to import into a group without changing
+// it, returning the outcome the import would have and the IDs it would add.
+func (p *Plugin) planImport(groupName string, usernames []string) (*ImportResult, []string, error) {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    members, exists := p.groups[groupName]
+    if !exists {
+        return nil, nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    result := &ImportResult{
+        Group:   groupName,
+        Added:   []string{},
+        Skipped: []string{},
+        Errors:  []string{},
+    }
+
+    existingMembers := make(map[string]bool)
+    for _, memberID := range members {
+        if user, err := p.API.GetUser(memberID); err == nil {
+            existingMembers[user.Username] = true
+        }
+    }
+
+    userIDs := []string{}
+    for _, username := range usernames {
+        if username == "" {
+            continue
+        }
+
+        // Skip if user is already in group
+        if existingMembers[username] {
+            result.Skipped = append(result.Skipped, username)
+            continue
+        }
+
+        // Get user by username
+        user, appErr := p.API.GetUserByUsername(username)
+        if appErr != nil {
+            result.Errors = append(result.Errors, username) // Skip invalid usernames
+            continue
+        }
+
+        userIDs = append(userIDs, user.Id)
+        existingMembers[username] = true
+        result.Added = append(result.Added, username)
+    }
+
+    return result, userIDs, nil
+}
+
+func (p *Plugin) importGroupMembers(groupName string, usernames []string, actorID string) (*ImportResult, error) {
+    result, userIDs, err := p.planImport(groupName, usernames)
+    if err != nil {
+        return nil, err
+    }
+
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    // Repeated IDs left by earlier imports are dropped as a safety net, and
+    // members added since the plan was made are not added twice
+    members, duplicates := dedupeMembers(members)
+    added := []string{}
+    for _, userID := range userIDs {
+        if !contains(members, userID) {
+            members = append(members, userID)
+            added = append(added, userID)
+        }
+    }
+    p.groups[groupName] = members
+    if len(added) > 0 || duplicates > 0 {
+        p.touchGroup(groupName)
+    }
+    if len(added) > 0 {
+        p.events.append(memberEvents(EventMemberAdded, groupName, actorID, added))
+        p.recordJoins(groupName, added)
+    }
+    removals := p.enforceExclusive(groupName, added, actorID)
+    p.groupMutex.Unlock()
+
+    result.Exclusive = p.resolveRemovals(removals)
+
+    // Save to persistent storage
+    return result, p.saveGroupState()
+}
+
+// jsonResponse renders a result object as the command response text.
+func jsonResponse(result interface{}) *model.CommandResponse {
+    data, err := json.MarshalIndent(result, "", "  ")
+    if err != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Failed to encode result: %v", err),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: string(data),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
+
+// commandData skips the first n words of the command and returns the rest
+// untouched, along with which of flags appeared among or right after the
+// skipped words. Flags further on are left in the data, so free text such
+// as CSV cells is never rewritten.
+func commandData(command string, n int, flags ...string) (map[string]bool, string) {
+    isFlag := func(word string) bool {
+        for _, flag := range flags {
+            if word == flag {
+                return true
+            }
+        }
+        return false
+    }
+
+    found := make(map[string]bool)
+    rest := strings.TrimSpace(command)
+    for rest != "" {
+        end := strings.IndexFunc(rest, unicode.IsSpace)
+        if end < 0 {
+            end = len(rest)
+        }
+        word := rest[:end]
+        switch {
+        case isFlag(word):
+            found[word] = true
+        case n > 0:
+            n--
+        default:
+            return found, rest
+        }
+        rest = strings.TrimSpace(rest[end:])
+    }
+    return found, ""
+}
+
+// trimTrailingFlags removes the flags that end the text, which ExecuteCommand
+// has already applied, keeping the spacing of the rest.
+func trimTrailingFlags(text string, flags ...string) string {
+    for {
+        end := strings.LastIndexFunc(text, unicode.IsSpace)
+        last := text[end+1:]
+        if !contains(flags, last) {
+            return text
+        }
+        text = strings.TrimRightFunc(text[:end+1], unicode.IsSpace)
+    }
+}
+
+// extractFlag removes every occurrence of flag from args and reports whether
+// it was present.
+func extractFlag(args []string, flag string) ([]string, bool) {
+    remaining := []string{}
+    found := false
+    for _, arg := range args {
+        if arg == flag {
+            found = true
+            continue
+        }
+        remaining = append(remaining, arg)
+    }
+    return remaining, found
+}
+
+// extractOption removes a "name value" or "name=value" option from args and
+// returns its value and whether it was present.
+func extractOption(args []string, name string) ([]string, string, bool) {
+    remaining := []string{}
+    value := ""
+    found := false
+    for i := 0; i < len(args); i++ {
+        switch {
+        case args[i] == name:
+            found = true
+            if i+1 < len(args) {
+                i++
+                value = args[i]
+            }
+        case strings.HasPrefix(args[i], name+"="):
+            found = true
+            value = strings.TrimPrefix(args[i], name+"=")
+        default:
+            remaining = append(remaining, args[i])
+        }
+    }
+    return remaining, value, found
+}
+
+// successText returns the confirmation of a successful change, shortened to
+// "OK" in quiet mode so scripts and bots are not flooded with text.
+func successText(quiet bool, text string) string {
+    if quiet {
+        return "OK"
+    }
+    return text
+}
+
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+    split, asJSON := extractFlag(strings.Fields(args.Command), "--json")
+    split, quiet := extractFlag(split, "--quiet")
+    trigger := config.GetConfig().CommandTrigger
+    if len(split) > 0 && split[0] != "/"+trigger {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Unknown command: %s", split[0]),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+    }
+
+    if ok, wait := p.commandLimiter.allow(args.UserId, config.GetConfig().CommandRateLimit, time.Now()); !ok {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("You are sending commands too quickly. Try again in %d seconds.", cooldownSeconds(wait)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+    }
+
+    if len(split) < 2 {
+        return &model.CommandResponse{
+            Text: translate(p.userLocale(args.UserId), "help"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+    }
+
+    command := split[1]
+    if groupNameCommands[command] && len(split) > 2 {
+        split[2] = canonicalGroupName(split[2])
+    }
+    if managementCommands[command] && !p.canManageGroups(args.UserId) {
+        return &model.CommandResponse{
+            Text: managementRestrictedText,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+    }
+
+    switch command {
+    case "create":
+        if len(split) < 3 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name: `/%s create group_name [description]`", trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        groupName := split[2]
+        refs, rest := splitGroupRefs(split[3:])
+        description := strings.Join(rest, " ")
+
+        if err := p.checkSubgroupRefs(groupName, refs); err != nil {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Cannot include the groups in %s: %v", groupName, err),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        if err := p.createGroup(groupName, nil, description, args.UserId); err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save group"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        if len(refs) > 0 {
+            if _, _, err := p.addSubgroups(groupName, refs); err != nil {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("Created group %s, but cannot include the groups: %v", groupName, err),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            return &model.CommandResponse{
+                Text: successText(quiet, fmt.Sprintf("Created group %s including the members of @%s", groupName, strings.Join(refs, ", @"))),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Created group %s", groupName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+        
+    case "add":
+        if len(split) < 4 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name and usernames: `/%s add group_name @username [@username...]` or `/%s add group_name group:other_group [group:other_group...]`", trigger, trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        groupName := split[2]
+        if refs, rest := splitGroupRefs(split[3:]); len(refs) > 0 {
+            if len(rest) > 0 {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("Please add groups and users in separate commands: `/%s add group_name group:other_group [group:other_group...]`", trigger),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            return p.subgroupsCommand(groupName, refs, false, quiet), nil
+        }
+        if len(split) > 4 {
+            return p.addMembersCommand(args.UserId, groupName, split[3:], asJSON, quiet), nil
+        }
+        username := strings.TrimPrefix(split[3], "@")
+        
+        // Get user by username
+        user, appErr := p.API.GetUserByUsername(username)
+        if appErr != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(fmt.Errorf("%w: %s", ErrUserNotFound, username), username, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        removals, err := p.addGroupMember(groupName, user.Id, args.UserId)
+        if err != nil {
+            if errors.Is(err, ErrAlreadyMember) {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("User %s is already in group %s", username, groupName),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        
+        text := fmt.Sprintf("Added %s to group %s", username, groupName)
+        if len(removals) > 0 {
+            text += "\n" + exclusiveRemovalText(removals)
+        }
+        return &model.CommandResponse{
+            Text: successText(quiet, text),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+        
+    case "remove":
+        if len(split) < 4 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name and username: `/%s remove group_name @username` or `/%s remove group_name group:other_group`", trigger, trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        groupName := split[2]
+        if refs, rest := splitGroupRefs(split[3:]); len(refs) > 0 {
+            if len(rest) > 0 {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("Please remove groups and users in separate commands: `/%s remove group_name group:other_group [group:other_group...]`", trigger),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            return p.subgroupsCommand(groupName, refs, true, quiet), nil
+        }
+        username := strings.TrimPrefix(split[3], "@")
+
+        user, appErr := p.API.GetUserByUsername(username)
+        if appErr != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(fmt.Errorf("%w: %s", ErrUserNotFound, username), username, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        if err := p.removeGroupMember(groupName, user.Id, args.UserId); err != nil {
+            if errors.Is(err, ErrNotMember) {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("User %s is not in group %s", username, groupName),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Removed %s from group %s", username, groupName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+
+    case "list":
+        listArgs, mine := extractFlag(split[2:], "--mine")
+        listArgs, tag, _ := extractOption(listArgs, "--tag")
+        _, order, _ := extractOption(listArgs, "--sort")
+
+        p.groupMutex.RLock()
+        defer p.groupMutex.RUnlock()
+        
+        if len(p.groups) == 0 && !asJSON {
+            return &model.CommandResponse{
+                Text: "No groups exist",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        groupNames, err := p.sortedGroupNames(order)
+        if err != nil {
+            return &model.CommandResponse{
+                Text: err.Error(),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        if asJSON {
+            listedNames := []string{}
+            for _, groupName := range groupNames {
+                if (!mine || contains(p.groups[groupName], args.UserId)) && p.hasTag(groupName, tag) {
+                    listedNames = append(listedNames, groupName)
+                }
+            }
+            return jsonResponse(p.groupReports(listedNames, p.mentionRates.snapshot())), nil
+        }
+        
+        var text strings.Builder
+        if mine {
+            text.WriteString("Your groups:\n")
+        } else {
+            text.WriteString("Available groups:\n")
+        }
+        
+        listed := 0
+        for _, groupName := range groupNames {
+            members := p.groups[groupName]
+            if mine && !contains(members, args.UserId) {
+                continue
+            }
+            if !p.hasTag(groupName, tag) {
+                continue
+            }
+            listed++
+
+            text.WriteString(fmt.Sprintf("\n**%s** (%d members):\n", groupName, len(members)))
+            if metadata, ok := p.groupMetadata[groupName]; ok && metadata.Description != "" {
+                text.WriteString(fmt.Sprintf("_%s_\n", metadata.Description))
+            }
+            if tags := p.groupTags(groupName); len(tags) > 0 {
+                text.WriteString(fmt.Sprintf("Tags: %s\n", strings.Join(tags, ", ")))
+            }
+            for _, userID := range members {
+                user, err := p.API.GetUser(userID)
+                if err == nil {
+                    text.WriteString(fmt.Sprintf("- @%s\n", user.Username))
+                }
+            }
+        }
+
+        if mine && listed == 0 {
+            return &model.CommandResponse{
+                Text: "You are not a member of any group",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        if listed == 0 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("No groups are tagged %s", tag),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        
+        return &model.CommandResponse{
+            Text: text.String(),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
+        
+    case "info":
+        if len(split) < 3 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name: `/%s info group_name [page]`", trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        groupName := split[2]
+
+        page := 1
+        if len(split) > 3 {
+            parsed, err := strconv.Atoi(split[3])
+            if err != nil || parsed < 1 {
+                return &model.CommandResponse{
+                    Text: fmt.Sprintf("Invalid page %s", split[3]),
+                    ResponseType: model.CommandResponseTypeEphemeral,
+                }, nil
+            }
+            page = parsed
+        }
+
+        text, err := p.groupInfo(groupName, page)
+        if err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, err.Error()),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
 
-func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
-    p.groupMutex.RLock()
-    defer p.groupMutex.RUnlock()
+        return &model.CommandResponse{
+            Text: text,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
 
-    // Get the post author's username
-    postAuthor, err := p.API.GetUser(post.UserId)
-    if err != nil {
-        return
-    }
+    case "color":
+        if len(split) < 4 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name and color: `/%s color group_name #hex [label]` or `/%s color group_name none`", trigger, trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        groupName := split[2]
+        color := split[3]
+        label := strings.Join(split[4:], " ")
 
-    // Check if post has group mentions
-    if groupMentions, ok := post.Props["group_mentions"].([]interface{}); ok {
-        for _, mention := range groupMentions {
-            if groupMention, ok := mention.(map[string]interface{}); ok {
-                groupName, _ := groupMention["group"].(string)
-                if members, ok := groupMention["members"].([]string); ok {
-                    // Get member usernames for display
-                    var memberNames []string
-                    for _, memberID := range members {
-                        if user, err := p.API.GetUser(memberID); err == nil {
-                            memberNames = append(memberNames, "@"+user.Username)
-                        }
-                    }
+        if strings.EqualFold(color, "none") {
+            color, label = "", ""
+        } else if !hexColorPattern.MatchString(color) {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Invalid color %s. Use a hex color such as #1e90ff", color),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
 
-                    // Send notifications to each member
-                    for _, userID := range members {
-                        // Skip if user is the post author
-                        if userID == post.UserId {
-                            continue
-                        }
-
-                        // Get the channel where the mention occurred
-                        channel, err := p.API.GetChannel(post.ChannelId)
-                        if err != nil {
-                            continue
-                        }
-
-                        // Create mention notification
-                        p.API.SendEphemeralPost(userID, &model.Post{
-                            UserId:    post.UserId,
-                            ChannelId: post.ChannelId,
-                            Message: fmt.Sprintf("You were mentioned in group @%s by @%s in ~%s\nGroup members: %s", 
-                                groupName,
-                                postAuthor.Username,
-                                channel.Name,
-                                strings.Join(memberNames, ", "),
-                            ),
-                            Props: model.StringInterface{
-                                "from_webhook": "true",
-                                "override_username": "Group Mention",
-                                "override_icon_url": "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
-                            },
-                        })
-                    }
-                }
-            }
+        if err := p.setGroupColor(groupName, color, label); err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
-    }
-}
 
-func (p *Plugin) exportGroup(groupName string) ([]string, error) {
-    p.groupMutex.RLock()
-    defer p.groupMutex.RUnlock()
+        if color == "" {
+            return &model.CommandResponse{
+                Text: successText(quiet, fmt.Sprintf("Cleared the color of group %s", groupName)),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
 
-    members, exists := p.groups[groupName]
-    if !exists {
-        return nil, fmt.Errorf("group not found")
-    }
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Set the color of group %s to %s", groupName, color)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
 
-    usernames := make([]string, 0, len(members))
-    for _, memberID := range members {
-        if user, err := p.API.GetUser(memberID); err == nil {
-            usernames = append(usernames, user.Username)
+    case "pin", "unpin":
+        if len(split) < 3 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name: `/%s %s group_name`", trigger, command),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
-    }
+        groupName := split[2]
+        pinned := command == "pin"
 
-    return usernames, nil
-}
+        if err := p.setGroupPinned(groupName, pinned); err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
 
-func (p *Plugin) importGroupMembers(groupName string, usernames []string) error {
-    p.groupMutex.Lock()
-    defer p.groupMutex.Unlock()
+        if !pinned {
+            return &model.CommandResponse{
+                Text: successText(quiet, fmt.Sprintf("Unpinned group %s", groupName)),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
 
-    members, exists := p.groups[groupName]
-    if !exists {
-        return fmt.Errorf("group not found")
-    }
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Pinned group %s to the top of autocomplete", groupName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }, nil
 
-    existingMembers := make(map[string]bool)
-    for _, memberID := range members {
-        if user, err := p.API.GetUser(memberID); err == nil {
-            existingMembers[user.Username] = true
+    case "priority":
+        if len(split) < 4 || (split[3] != postPriorityUrgent && split[3] != "normal") {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name and priority: `/%s priority group_name urgent|normal`", trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
-    }
+        groupName := split[2]
+        urgent := split[3] == postPriorityUrgent
 
-    for _, username := range usernames {
-        // Skip if user is already in group
-        if existingMembers[username] {
-            continue
+        if err := p.setGroupUrgent(groupName, urgent); err != nil {
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
 
-        // Get user by username
-        user, appErr := p.API.GetUserByUsername(username)
-        if appErr != nil {
-            continue // Skip invalid usernames
+        if !urgent {
+            return &model.CommandResponse{
+                Text: successText(quiet, fmt.Sprintf("Mentions of group %s now have normal priority", groupName)),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
 
-        members = append(members, user.Id)
-    }
-
-    p.groups[groupName] = members
-    return p.saveGroups()
-}
-
-func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
-    split := strings.Fields(args.Command)
-    if len(split) < 2 {
         return &model.CommandResponse{
-            Text: "Available commands: create, add, remove, list, delete, export, import",
+            Text: successText(quiet, fmt.Sprintf("Mentions of group %s now mark the post as urgent", groupName)),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
-    }
 
-    command := split[1]
-    switch command {
-    case "create":
-        if len(split) < 3 {
+    case "email":
+        if len(split) < 4 || (split[3] != "on" && split[3] != "off") {
             return &model.CommandResponse{
-                Text: "Please specify a group name: `/group create group_name`",
+                Text: fmt.Sprintf("Please specify a group name and setting: `/%s email group_name on|off`", trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
         groupName := split[2]
-        
-        p.groupMutex.Lock()
-        if _, exists := p.groups[groupName]; exists {
-            p.groupMutex.Unlock()
+        enabled := split[3] == "on"
+
+        if err := p.setGroupEmailOffline(groupName, enabled); err != nil {
             return &model.CommandResponse{
-                Text: fmt.Sprintf("Group %s already exists", groupName),
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        p.groups[groupName] = []string{}
-        p.groupMutex.Unlock()
-        
-        // Save to persistent storage
-        if err := p.saveGroups(); err != nil {
+
+        if !enabled {
             return &model.CommandResponse{
-                Text: "Failed to save group",
+                Text: successText(quiet, fmt.Sprintf("Offline members of group %s are no longer emailed", groupName)),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
+
         return &model.CommandResponse{
-            Text: fmt.Sprintf("Created group %s", groupName),
+            Text: successText(quiet, fmt.Sprintf("Offline members of group %s are now also emailed when it is mentioned", groupName)),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
-        
-    case "add":
+
+    case "tag":
+        return p.tagCommand(trigger, split[2:], quiet), nil
+
+    case "schedule":
+        return p.scheduleCommand(trigger, split[2:]), nil
+
+    case "status":
+        return p.statusCommand(trigger, split[2:]), nil
+
+    case "dynamic":
+        return p.dynamicCommand(args, trigger, split[2:]), nil
+
+    case "push":
+        return p.pushCommand(args.UserId, trigger, split[2:]), nil
+
+    case "from-post":
+        return p.fromPostCommand(args.UserId, trigger, split[2:]), nil
+
+    case "import-preview":
+        return p.importPreviewCommand(args.UserId, trigger, split[2:], asJSON), nil
+
+    case "doctor":
+        return p.doctorCommand(args.UserId, trigger, split[2:], asJSON), nil
+
+    case "blast":
+        return p.blastCommand(args, trigger, split[2:], asJSON), nil
+
+    case "dedupe":
+        return p.dedupeCommand(trigger, split[2:], quiet), nil
+
+    case "snooze":
+        return p.snoozeCommand(args, trigger, split[2:], quiet), nil
+
+    case "addchannel":
+        return p.addChannelCommand(args, trigger, split[2:], quiet), nil
+
+    case "audit-export":
+        return p.auditExportCommand(args.UserId, trigger, split[2:]), nil
+
+    case "exclusive":
+        return p.exclusiveCommand(args.UserId, trigger, split[2:]), nil
+
+    case "similar":
+        return p.similarCommand(args.UserId, trigger, asJSON), nil
+
+    case "merge":
+        return p.mergeCommand(args.UserId, trigger, split[2:], quiet), nil
+
+    case "template":
         if len(split) < 4 {
             return &model.CommandResponse{
-                Text: "Please specify a group name and username: `/group add group_name @username`",
+                Text: fmt.Sprintf("Please specify a group name and template: `/%s template group_name text` or `/%s template group_name none`. The template can use {{.Author}}, {{.Channel}}, {{.Group}} and {{.Members}}", trigger, trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
         groupName := split[2]
-        username := strings.TrimPrefix(split[3], "@")
-        
-        // Get user by username
-        user, appErr := p.API.GetUserByUsername(username)
-        if appErr != nil {
+
+        // Keep the template's spacing by cutting it from the raw command
+        _, text := commandData(args.Command, 3, "--json", "--quiet")
+        text = trimTrailingFlags(text, "--json", "--quiet")
+        if strings.EqualFold(text, "none") {
+            text = ""
+        } else if _, err := parseNotificationTemplate(text); err != nil {
             return &model.CommandResponse{
-                Text: fmt.Sprintf("User %s not found", username),
+                Text: fmt.Sprintf("Invalid template: %v", err),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
-        p.groupMutex.Lock()
-        members, exists := p.groups[groupName]
-        if !exists {
-            p.groupMutex.Unlock()
+
+        if err := p.setGroupTemplate(groupName, text); err != nil {
             return &model.CommandResponse{
-                Text: fmt.Sprintf("Group %s does not exist", groupName),
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
-        // Check if user is already in group
-        for _, member := range members {
-            if member == user.Id {
-                p.groupMutex.Unlock()
-                return &model.CommandResponse{
-                    Text: fmt.Sprintf("User %s is already in group %s", username, groupName),
-                    ResponseType: model.CommandResponseTypeEphemeral,
-                }, nil
-            }
-        }
-        
-        p.groups[groupName] = append(members, user.Id)
-        p.groupMutex.Unlock()
-        
-        // Save to persistent storage
-        if err := p.saveGroups(); err != nil {
+
+        if text == "" {
             return &model.CommandResponse{
-                Text: "Failed to save changes",
+                Text: successText(quiet, fmt.Sprintf("Group %s now uses the default notification", groupName)),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
+
         return &model.CommandResponse{
-            Text: fmt.Sprintf("Added %s to group %s", username, groupName),
+            Text: successText(quiet, fmt.Sprintf("Set the notification template of group %s", groupName)),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
-        
-    case "list":
-        p.groupMutex.RLock()
-        defer p.groupMutex.RUnlock()
-        
-        if len(p.groups) == 0 {
+
+    case "leave-all":
+        left, err := p.leaveAllGroups(args.UserId)
+        if err != nil {
             return &model.CommandResponse{
-                Text: "No groups exist",
+                Text: "Failed to save changes",
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
-        var text strings.Builder
-        text.WriteString("Available groups:\n")
-        
-        for groupName, members := range p.groups {
-            text.WriteString(fmt.Sprintf("\n**%s** (%d members):\n", groupName, len(members)))
-            for _, userID := range members {
-                user, err := p.API.GetUser(userID)
-                if err == nil {
-                    text.WriteString(fmt.Sprintf("- @%s\n", user.Username))
-                }
-            }
+
+        if len(left) == 0 {
+            return &model.CommandResponse{
+                Text: "You are not a member of any group",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
         }
-        
+
         return &model.CommandResponse{
-            Text: text.String(),
+            Text: successText(quiet, fmt.Sprintf("You left %d groups: %s", len(left), strings.Join(left, ", "))),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
-        
-    case "delete":
-        if len(split) < 3 {
+
+    case "rename-bulk":
+        rest, confirm := extractFlag(split[2:], "--confirm")
+        if len(rest) < 2 || rest[1] == "" {
             return &model.CommandResponse{
-                Text: "Please specify a group name: `/group delete group_name`",
+                Text: fmt.Sprintf("Please specify the old and new prefixes: `/%s rename-bulk old_prefix new_prefix [--confirm]`", trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        groupName := split[2]
-        
-        p.groupMutex.Lock()
-        if _, exists := p.groups[groupName]; !exists {
-            p.groupMutex.Unlock()
+        return p.renameBulkCommand(args.UserId, canonicalGroupName(rest[0]), canonicalGroupName(rest[1]), confirm), nil
+
+    case "delete":
+        if len(split) < 3 {
             return &model.CommandResponse{
-                Text: fmt.Sprintf("Group %s does not exist", groupName),
+                Text: fmt.Sprintf("Please specify a group name: `/%s delete group_name`", trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
-        
-        delete(p.groups, groupName)
-        p.groupMutex.Unlock()
-        
-        // Save to persistent storage
-        if err := p.saveGroups(); err != nil {
+        groupName := split[2]
+
+        if err := p.deleteGroup(groupName, args.UserId); err != nil {
             return &model.CommandResponse{
-                Text: "Failed to save changes",
+                Text: commandErrorText(err, groupName, "Failed to save changes"),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
         
         return &model.CommandResponse{
-            Text: fmt.Sprintf("Deleted group %s", groupName),
+            Text: successText(quiet, fmt.Sprintf("Deleted group %s. Administrators can restore it with `/%s restore %s`", groupName, trigger, groupName)),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
         
+    case "trash":
+        return p.trashCommand(args.UserId, command, ""), nil
+
+    case "restore":
+        if len(split) < 3 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Please specify a group name: `/%s restore group_name`", trigger),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+        return p.trashCommand(args.UserId, command, split[2]), nil
+
     case "export":
         if len(split) != 3 {
             return &model.CommandResponse{
-                Text: "Please specify a group name: /group export [group-name]",
+                Text: fmt.Sprintf("Please specify a group name: /%s export [group-name] [--json]", trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
 
         groupName := split[2]
-        usernames, err := p.exportGroup(groupName)
+        result, err := p.exportGroup(groupName)
+        if err != nil {
+            if asJSON {
+                return jsonResponse(&ExportResult{Group: groupName, Members: []string{}, Errors: []string{err.Error()}, Tags: []string{}, JoinedAt: map[string]int64{}}), nil
+            }
+            return &model.CommandResponse{
+                Text: commandErrorText(err, groupName, fmt.Sprintf("Error exporting group: %v", err)),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        if asJSON {
+            return jsonResponse(result), nil
+        }
+
+        csv, err := encodeMembersCSV(result.Members, result.JoinedAt)
         if err != nil {
             return &model.CommandResponse{
                 Text: fmt.Sprintf("Error exporting group: %v", err),
@@ -681,49 +2294,132 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
             }, nil
         }
 
-        csv := strings.Join(usernames, ",")
         return &model.CommandResponse{
-            Text: fmt.Sprintf("Group members for %s:\n```\n%s\n```\nCopy this list to import into another group.", groupName, csv),
+            Text: fmt.Sprintf("Group members for %s:\n```\n%s\n```\nCopy this CSV to import into another group.", groupName, csv),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
 
     case "import":
         if len(split) < 4 {
             return &model.CommandResponse{
-                Text: "Please specify a group name and CSV data: /group import [group-name] [username1,username2,...]",
+                Text: fmt.Sprintf("Please specify a group name and CSV data: /%s import [group-name] [--json] [--confirm] [username1,username2,...]", trigger),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
 
         groupName := split[2]
-        csvData := strings.Join(split[3:], " ")
-        usernames := strings.Split(csvData, ",")
 
-        // Trim spaces from usernames
-        for i, username := range usernames {
-            usernames[i] = strings.TrimSpace(username)
+        // Parse the raw command so quoted fields and line breaks survive.
+        // Flags come before the CSV data, which may itself contain them.
+        flags, csvData := commandData(args.Command, 3, "--json", "--quiet", "--confirm")
+        asJSON, quiet, confirmed := flags["--json"], flags["--quiet"], flags["--confirm"]
+        usernames, err := parseMembersCSV(csvData)
+        if err != nil {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Invalid CSV data: %v", err),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }, nil
+        }
+
+        // Large imports are previewed and wait for confirmation
+        threshold := config.GetConfig().ImportConfirmThreshold
+        if threshold > 0 && !confirmed {
+            preview, _, err := p.planImport(groupName, usernames)
+            if err == nil && len(preview.Added) > threshold {
+                if asJSON {
+                    preview.Errors = append(preview.Errors, fmt.Sprintf("import would add %d members, rerun with --confirm to apply it", len(preview.Added)))
+                    return jsonResponse(preview), nil
+                }
+                return p.importConfirmation(args.UserId, groupName, usernames, preview), nil
+            }
         }
 
-        if err := p.importGroupMembers(groupName, usernames); err != nil {
+        result, err := p.importGroupMembers(groupName, usernames, args.UserId)
+        if err != nil {
+            if asJSON {
+                if result == nil {
+                    result = &ImportResult{Group: groupName, Added: []string{}, Skipped: []string{}, Errors: []string{}}
+                }
+                result.Errors = append(result.Errors, err.Error())
+                return jsonResponse(result), nil
+            }
             return &model.CommandResponse{
-                Text: fmt.Sprintf("Error importing members: %v", err),
+                Text: commandErrorText(err, groupName, fmt.Sprintf("Error importing members: %v", err)),
                 ResponseType: model.CommandResponseTypeEphemeral,
             }, nil
         }
 
+        if asJSON {
+            return jsonResponse(result), nil
+        }
+
         return &model.CommandResponse{
-            Text: fmt.Sprintf("Successfully imported members into group %s", groupName),
+            Text: successText(quiet, strings.TrimSuffix(fmt.Sprintf("Successfully imported members into group %s (%d added, %d already members, %d not found)\n%s", groupName, len(result.Added), len(result.Skipped), len(result.Errors), exclusiveRemovalText(result.Exclusive)), "\n")),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
 
     default:
         return &model.CommandResponse{
-            Text: "Unknown command. Available commands: create, add, remove, list, delete, export, import",
+            Text: translate(p.userLocale(args.UserId), "unknown_command"),
             ResponseType: model.CommandResponseTypeEphemeral,
         }, nil
     }
 }
 
+// addMembersCommand adds several users to a group at once, persisting once,
+// and reports which were added, already members or not found.
+func (p *Plugin) addMembersCommand(userID, groupName string, tokens []string, asJSON, quiet bool) *model.CommandResponse {
+    usernames := make([]string, 0, len(tokens))
+    for _, token := range tokens {
+        if username := strings.ToLower(strings.TrimPrefix(token, "@")); username != "" && !contains(usernames, username) {
+            usernames = append(usernames, username)
+        }
+    }
+
+    result, err := p.importGroupMembers(groupName, usernames, userID)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if asJSON {
+        return jsonResponse(result)
+    }
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Group %s: %d added, %d already members, %d not found", groupName, len(result.Added), len(result.Skipped), len(result.Errors)))
+    for _, section := range []struct {
+        label     string
+        usernames []string
+    }{
+        {"Added", result.Added},
+        {"Already members", result.Skipped},
+        {"Not found", result.Errors},
+    } {
+        if len(section.usernames) > 0 {
+            text.WriteString(fmt.Sprintf("\n- %s: @%s", section.label, strings.Join(section.usernames, ", @")))
+        }
+    }
+    if len(result.Exclusive) > 0 {
+        text.WriteString("\n" + exclusiveRemovalText(result.Exclusive))
+    }
+
+    // Quiet mode only hides a fully successful summary
+    if len(result.Errors) == 0 {
+        return &model.CommandResponse{
+            Text: successText(quiet, text.String()),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: text.String(),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
+
 func contains(slice []string, item string) bool {
     for _, s := range slice {
         if s == item {
@@ -0,0 +1,506 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+    "unicode/utf8"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// getGroups requests the groups endpoint with the query and decodes the
+// response into v.
+func getGroups(t *testing.T, p *Plugin, query string, v interface{}) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(http.MethodGet, "/api/v4/groups"+query, nil)
+    r.Header.Set("Mattermost-User-Id", "reader")
+    w := httptest.NewRecorder()
+    p.ServeHTTP(&plugin.Context{}, w, r)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+    return w
+}
+
+func TestGetGroupsIncludesDescriptions(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("backend", []string{"u1", "u2"}, "Server engineers", "creator"))
+    require.NoError(t, p.createGroup("design", []string{"u3"}, "", "creator"))
+
+    var groups map[string]*GroupSummary
+    getGroups(t, p, "", &groups)
+
+    require.Len(t, groups, 2)
+    assert.Equal(t, []string{"u1", "u2"}, groups["backend"].Members)
+    assert.Equal(t, "Server engineers", groups["backend"].Description)
+    assert.Equal(t, "creator", groups["backend"].CreatedBy)
+    assert.NotZero(t, groups["backend"].CreatedAt)
+    assert.Equal(t, []string{"u3"}, groups["design"].Members)
+    assert.Empty(t, groups["design"].Description)
+}
+
+func TestGetGroupsPagesInNameOrder(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    names := []string{"ops", "backend", "qa", "design", "frontend", "mobile", "data"}
+    for _, groupName := range names {
+        require.NoError(t, p.createGroup(groupName, []string{"u1"}, "", "creator"))
+    }
+
+    for _, query := range []string{"?per_page=3&page=%d", "?details=true&per_page=3&page=%d"} {
+        var seen []string
+        for page := 0; page < 4; page++ {
+            var pageNames []string
+            var w *httptest.ResponseRecorder
+            if strings.Contains(query, "details") {
+                var groups []*GroupResponse
+                w = getGroups(t, p, fmt.Sprintf(query, page), &groups)
+                for _, group := range groups {
+                    pageNames = append(pageNames, group.Name)
+                }
+            } else {
+                var groups map[string]*GroupSummary
+                w = getGroups(t, p, fmt.Sprintf(query, page), &groups)
+                for groupName := range groups {
+                    pageNames = append(pageNames, groupName)
+                }
+                sort.Strings(pageNames)
+            }
+            assert.Equal(t, "7", w.Header().Get("X-Total-Count"))
+            assert.LessOrEqual(t, len(pageNames), 3)
+            if len(seen) > 0 && len(pageNames) > 0 {
+                assert.Less(t, seen[len(seen)-1], pageNames[0], "page %d starts before the previous page ends", page)
+            }
+            seen = append(seen, pageNames...)
+        }
+        assert.Equal(t, []string{"backend", "data", "design", "frontend", "mobile", "ops", "qa"}, seen, query)
+    }
+}
+
+func TestGetGroupsDefaultsToOnePage(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    for i := 0; i < defaultGroupsPerPage+5; i++ {
+        require.NoError(t, p.createGroup(fmt.Sprintf("group-%03d", i), nil, "", "creator"))
+    }
+
+    var groups map[string]*GroupSummary
+    w := getGroups(t, p, "", &groups)
+    assert.Len(t, groups, defaultGroupsPerPage)
+    assert.Contains(t, groups, "group-000")
+    assert.NotContains(t, groups, fmt.Sprintf("group-%03d", defaultGroupsPerPage))
+    assert.Equal(t, strconv.Itoa(defaultGroupsPerPage+5), w.Header().Get("X-Total-Count"))
+
+    groups = nil
+    w = getGroups(t, p, "?page=1", &groups)
+    assert.Len(t, groups, 5)
+
+    r := httptest.NewRequest(http.MethodGet, "/api/v4/groups?per_page=0", nil)
+    r.Header.Set("Mattermost-User-Id", "reader")
+    w = httptest.NewRecorder()
+    p.ServeHTTP(&plugin.Context{}, w, r)
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCommandDataKeepsFlagsInCSV(t *testing.T) {
+    command := "/group import team-a --json --confirm username,note\nalice,\"runs --quiet --json\"\nbob-json,--confirm"
+
+    flags, data := commandData(command, 3, "--json", "--quiet", "--confirm")
+
+    assert.Equal(t, map[string]bool{"--json": true, "--confirm": true}, flags)
+    assert.Equal(t, "username,note\nalice,\"runs --quiet --json\"\nbob-json,--confirm", data)
+    usernames, err := parseMembersCSV(data)
+    require.NoError(t, err)
+    assert.Equal(t, []string{"alice", "bob-json"}, usernames)
+
+    flags, data = commandData("/group --quiet import team-a alice,--json", 3, "--json", "--quiet", "--confirm")
+    assert.Equal(t, map[string]bool{"--quiet": true}, flags)
+    assert.Equal(t, "alice,--json", data)
+
+    flags, data = commandData("/group import team-a --json", 3, "--json")
+    assert.Equal(t, map[string]bool{"--json": true}, flags)
+    assert.Empty(t, data)
+}
+
+func TestEditNotifiesOnlyNewlyMentionedGroups(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "author"},
+        &model.User{Id: "u1", Username: "alice"},
+        &model.User{Id: "u2", Username: "bob"},
+        &model.User{Id: "u3", Username: "carol"},
+    )
+    expectNotifications(api, "channel")
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1", "u2"}, "", "creator"))
+    require.NoError(t, p.createGroup("qa", []string{"u3"}, "", "creator"))
+
+    original := &model.Post{Id: "post", UserId: "author", ChannelId: "channel", Message: "ready for review"}
+    edited := &model.Post{Id: "post", UserId: "author", ChannelId: "channel", Message: "ready for review @dev"}
+    edited, _ = p.MessageWillBeUpdated(&plugin.Context{}, edited, original)
+    assert.Equal(t, "ready for review @dev (Group - 2 members: @alice, @bob)", edited.Message)
+    assert.Equal(t, map[string]bool{"dev": true}, mentionedGroups(edited))
+
+    p.MessageHasBeenUpdated(&plugin.Context{}, edited, original)
+    assert.Equal(t, []string{"u1", "u2"}, ephemeralRecipients(api))
+
+    // A second edit adding @qa expands and notifies qa only
+    again := &model.Post{Id: "post", UserId: "author", ChannelId: "channel", Message: edited.Message + " and @qa", Props: model.StringInterface{}}
+    for key, value := range edited.Props {
+        again.Props[key] = value
+    }
+    again, _ = p.MessageWillBeUpdated(&plugin.Context{}, again, edited)
+    assert.Equal(t, "ready for review @dev (Group - 2 members: @alice, @bob) and @qa (Group - 1 members: @carol)", again.Message)
+    assert.Equal(t, map[string]bool{"dev": true, "qa": true}, mentionedGroups(again))
+
+    p.MessageHasBeenUpdated(&plugin.Context{}, again, edited)
+    assert.Equal(t, []string{"u1", "u2", "u3"}, ephemeralRecipients(api))
+}
+
+func TestPostsWithoutGroupMentionsAreUntouched(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    for _, message := range []string{
+        "no mentions here",
+        "email dev@example.com",
+        "hi @alice and @devops",
+        "the dev team",
+    } {
+        props := model.StringInterface{"attachments": "kept", "from_bot": "true"}
+        post := &model.Post{UserId: "author", ChannelId: "channel", Message: message, Props: props}
+
+        post, rejection := p.MessageWillBePosted(&plugin.Context{}, post)
+
+        assert.Empty(t, rejection)
+        assert.Equal(t, message, post.Message)
+        assert.Equal(t, model.StringInterface{"attachments": "kept", "from_bot": "true"}, post.Props, message)
+    }
+
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", Message: "plain"})
+    assert.Nil(t, post.Props)
+}
+
+func TestUrgentGroupMentionsSetPriority(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api, &model.User{Id: "u1", Username: "bob"})
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("incident", []string{"u1"}, "", "creator"))
+    require.NoError(t, p.createGroup("ops", []string{"u1"}, "", "creator"))
+    assert.Equal(t, "Mentions of group incident now mark the post as urgent", runCommand(t, p, "creator", "/group priority incident urgent"))
+
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", Message: "@ops @incident the site is down"})
+
+    assert.Equal(t, postPriorityUrgent, post.Props["priority"])
+    priorities := map[string]interface{}{}
+    for _, mention := range post.Props["group_mentions"].([]interface{}) {
+        mention := mention.(map[string]interface{})
+        priorities[mention["group"].(string)] = mention["priority"]
+    }
+    assert.Equal(t, map[string]interface{}{"incident": postPriorityUrgent, "ops": nil}, priorities)
+
+    post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", Message: "@ops deploy"})
+    assert.NotContains(t, post.Props, "priority")
+}
+
+func TestExpandedMentionsFitMaxMessageLength(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+    settings := config.DefaultConfiguration()
+    settings.MaxMessageLength = 300
+    config.SetConfig(settings)
+
+    members := []string{}
+    for i := 0; i < 200; i++ {
+        userID := fmt.Sprintf("u%d", i)
+        members = append(members, userID)
+        expectUsers(api, &model.User{Id: userID, Username: fmt.Sprintf("ユーザー%03d", i)})
+    }
+    require.NoError(t, p.createGroup("everyone-jp", members, "", "creator"))
+
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", Message: "こんにちは @everyone-jp と @everyone-jp"})
+
+    assert.True(t, utf8.ValidString(post.Message))
+    assert.LessOrEqual(t, utf8.RuneCountInString(post.Message), settings.MaxMessageLength)
+    assert.True(t, strings.HasPrefix(post.Message, "こんにちは @everyone-jp (Group - 200 members: @ユーザー000, "), post.Message)
+    assert.Contains(t, post.Message, " more) と @everyone-jp (Group - 200 members: @ユーザー000")
+
+    // Every member is still mentioned even when not shown
+    mentions := post.Props["mentions"].(map[string]interface{})
+    assert.Len(t, mentions, 200)
+}
+
+func TestExpandedMention(t *testing.T) {
+    names := []string{"@alice", "@bob", "@carol"}
+
+    assert.Equal(t, "@dev (Group - 3 members: @alice, @bob, @carol)", expandedMention("dev", 3, names, 100))
+    assert.Equal(t, "@dev (Group - 3 members: @alice, and 2 more)", expandedMention("dev", 3, names, 44))
+    assert.Equal(t, "@dev (Group - 3 members)", expandedMention("dev", 3, names, 30))
+    assert.Equal(t, "@dev", expandedMention("dev", 3, names, 10))
+}
+
+// expectAutocomplete sets up a channel member asking for suggestions.
+// SearchUsers reports whether real users match the term.
+func expectAutocomplete(api *testAPI, usersMatch bool) {
+    expectViewer(api, "session", "viewer", "channel")
+    api.On("GetUser", mock.Anything).Return(nil, model.NewAppError("GetUser", "not_found", nil, "", http.StatusNotFound)).Maybe()
+    users := []*model.User{}
+    if usersMatch {
+        users = append(users, &model.User{Id: "user", Username: "user"})
+    }
+    api.On("SearchUsers", mock.Anything).Return(users, nil).Maybe()
+}
+
+func autocomplete(t *testing.T, p *Plugin, term string, limit int) []string {
+    suggestions, appErr := p.UserAutocompleteInChannel(&plugin.Context{SessionId: "session"}, "channel", "team", term, limit)
+    require.Nil(t, appErr)
+    return suggestionNames(suggestions)
+}
+
+func TestAutocompletePutsPinnedGroupsFirst(t *testing.T) {
+    api := newTestAPI(t)
+    expectAutocomplete(api, false)
+    p := newTestPlugin(t, api)
+    for _, groupName := range []string{"alpha", "beta", "gamma", "ops", "zeta"} {
+        require.NoError(t, p.createGroup(groupName, nil, "", "creator"))
+    }
+
+    assert.Equal(t, []string{"alpha", "beta", "gamma", "ops", "zeta"}, autocomplete(t, p, "@", 10))
+    assert.Equal(t, []string{"alpha", "beta"}, autocomplete(t, p, "@", 2))
+
+    for _, command := range []string{"/group pin zeta", "/group pin ops"} {
+        response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "viewer", Command: command})
+        require.NotContains(t, response.Text, "rror")
+    }
+
+    assert.Equal(t, []string{"ops", "zeta", "alpha", "beta", "gamma"}, autocomplete(t, p, "@", 10))
+    assert.Equal(t, []string{"ops", "zeta"}, autocomplete(t, p, "@", 2))
+    assert.Equal(t, []string{"zeta"}, autocomplete(t, p, "@Z", 10))
+
+    _, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "viewer", Command: "/group unpin ops"})
+    assert.Equal(t, []string{"zeta", "alpha", "beta"}, autocomplete(t, p, "@", 3))
+}
+
+func TestReservedGroupNamesAreRejected(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+
+    for _, groupName := range []string{"add", "all", "here", "channel", "help"} {
+        assert.ErrorIs(t, p.createGroup(groupName, nil, "", "creator"), ErrReservedName, groupName)
+    }
+    assert.Empty(t, p.groups)
+
+    response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "creator", Command: "/group create ADD"})
+    assert.Equal(t, "add is a reserved name and cannot be used for a group", response.Text)
+    assert.NotContains(t, p.groups, "add")
+
+    require.NoError(t, p.createGroup("team-dev", nil, "", "creator"))
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+    _, err := p.planPrefixRename("team-", "")
+    assert.NoError(t, err)
+    _, err = p.planPrefixRename("team-dev", "add")
+    assert.ErrorIs(t, err, ErrReservedName)
+}
+
+func TestReservedGroupNamesAreConfigurable(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    settings := config.DefaultConfiguration()
+    settings.ReservedGroupNames = " ops, @OnCall ,"
+    require.NoError(t, settings.ProcessConfiguration())
+    config.SetConfig(settings)
+
+    assert.ErrorIs(t, p.createGroup("ops", nil, "", "creator"), ErrReservedName)
+    assert.ErrorIs(t, p.createGroup("oncall", nil, "", "creator"), ErrReservedName)
+    assert.NoError(t, p.createGroup("add", nil, "", "creator"))
+}
+
+func TestRepostedExpansionIsNotExpandedAgain(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "alice"},
+        &model.User{Id: "bot", Username: "relay", IsBot: true},
+        &model.User{Id: "u1", Username: "bob"},
+    )
+    expectNotifications(api, "channel")
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    original, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", ChannelId: "channel", Message: "@dev deploy is done"})
+    p.MessageHasBeenPosted(&plugin.Context{}, original)
+    require.Equal(t, "@dev (Group - 1 members: @bob) deploy is done", original.Message)
+    require.Equal(t, []string{"u1"}, ephemeralRecipients(api))
+
+    // A bot reposts the expanded text along with its props
+    props := model.StringInterface{}
+    for key, value := range original.Props {
+        props[key] = value
+    }
+    repost, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "bot", ChannelId: "channel", Message: original.Message, Props: props})
+    p.MessageHasBeenPosted(&plugin.Context{}, repost)
+
+    assert.Equal(t, original.Message, repost.Message)
+    assert.Empty(t, mentionedGroups(repost))
+    assert.Equal(t, []string{"u1"}, ephemeralRecipients(api), "the repost notified members again")
+
+}
+
+func TestGroupSuggestionLimit(t *testing.T) {
+    for _, tc := range []struct {
+        limit, share int
+        usersMatch   bool
+        expected     int
+    }{
+        {2, 0, true, 1},
+        {2, 0, false, 2},
+        {1, 0, true, 1},
+        {0, 0, true, 0},
+        {-1, 0, true, 0},
+        {10, 25, false, 7},
+        {10, 25, true, 7},
+        {4, 50, true, 2},
+        {2, 100, true, 1},
+        {2, 100, false, 1},
+    } {
+        usersMatch := func() bool { return tc.usersMatch }
+        assert.Equal(t, tc.expected, groupSuggestionLimit(tc.limit, tc.share, usersMatch), "%+v", tc)
+    }
+}
+
+func TestAutocompleteLeavesRoomForUsers(t *testing.T) {
+    for _, tc := range []struct {
+        name       string
+        usersMatch bool
+        share      int
+        limit      int
+        expected   []string
+    }{
+        {"users match", true, 0, 2, []string{"dev"}},
+        {"no users match", false, 0, 2, []string{"dev", "developers"}},
+        {"share reserves half", false, 50, 4, []string{"dev", "developers"}},
+        {"share never hides all groups", true, 100, 2, []string{"dev"}},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            api := newTestAPI(t)
+            expectAutocomplete(api, tc.usersMatch)
+            p := newTestPlugin(t, api)
+            settings := config.DefaultConfiguration()
+            settings.AutocompleteUserShare = tc.share
+            require.NoError(t, settings.ProcessConfiguration())
+            config.SetConfig(settings)
+            for _, groupName := range []string{"dev", "devops", "developers"} {
+                require.NoError(t, p.createGroup(groupName, nil, "", "creator"))
+            }
+
+            assert.Equal(t, tc.expected, autocomplete(t, p, "@dev", tc.limit))
+        })
+    }
+}
+
+func TestServeHTTPRejectsUnauthenticatedRequests(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    for _, tc := range []struct {
+        method string
+        path   string
+        body   string
+    }{
+        {http.MethodGet, "/api/v4/groups", ""},
+        {http.MethodPost, "/api/v4/groups", `{"name": "ops"}`},
+        {http.MethodDelete, "/api/v4/groups", `{"name": "dev"}`},
+        {http.MethodPost, "/api/v4/groups/members", `{"group_name": "dev", "user_id": "u2"}`},
+        {http.MethodDelete, "/api/v4/groups/members", `{"group_name": "dev", "user_id": "u1"}`},
+        {http.MethodGet, "/api/v4/groups/one?name=dev", ""},
+        {http.MethodGet, "/api/v4/groups/search?term=d", ""},
+        {http.MethodGet, "/api/v4/groups/events", ""},
+        {http.MethodGet, "/debug/vars", ""},
+    } {
+        t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+            w := serveRequest(p, tc.method, tc.path, "", tc.body)
+
+            assert.Equal(t, http.StatusUnauthorized, w.Code)
+            assert.NotContains(t, w.Body.String(), "u1")
+        })
+    }
+
+    assert.Equal(t, map[string][]string{"dev": {"u1"}}, p.groups)
+}
+
+func TestServeHTTPRejectsWritesByUnknownUsers(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("GetUser", "deleted").Return(nil, model.NewAppError("GetUser", "app.user.missing_account.const", nil, "", http.StatusNotFound))
+    expectUsers(api, &model.User{Id: "creator", Username: "creator"})
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    // Reads only need an authenticated session
+    w := serveRequest(p, http.MethodGet, "/api/v4/groups", "deleted", "")
+    assert.Equal(t, http.StatusOK, w.Code)
+
+    w = serveRequest(p, http.MethodPost, "/api/v4/groups", "deleted", `{"name": "ops"}`)
+    assert.Equal(t, http.StatusUnauthorized, w.Code)
+    w = serveRequest(p, http.MethodDelete, "/api/v4/groups", "deleted", `{"name": "dev"}`)
+    assert.Equal(t, http.StatusUnauthorized, w.Code)
+    assert.Equal(t, map[string][]string{"dev": {"u1"}}, p.groups)
+
+    w = serveRequest(p, http.MethodPost, "/api/v4/groups", "creator", `{"name": "ops"}`)
+    assert.Equal(t, http.StatusCreated, w.Code)
+    assert.Contains(t, p.groups, "ops")
+}
+
+func TestPageOfNamesBounds(t *testing.T) {
+    names := []string{"a", "b", "c", "d", "e"}
+    for _, tc := range []struct {
+        page     int
+        perPage  int
+        expected []string
+    }{
+        {0, 2, []string{"a", "b"}},
+        {2, 2, []string{"e"}},
+        {3, 2, []string{}},
+        {0, 5, names},
+        {1, 5, []string{}},
+        {0, maxGroupsPerPage, names},
+        {math.MaxInt64, 2, []string{}},
+        {math.MaxInt64 / 2, 3, []string{}},
+    } {
+        assert.Equal(t, tc.expected, pageOfNames(names, tc.page, tc.perPage), "page %d of %d", tc.page, tc.perPage)
+    }
+    assert.Equal(t, []string{}, pageOfNames(nil, 0, 10))
+}
+
+func TestGetGroupsPageBounds(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    for i := 0; i < maxGroupsPerPage+5; i++ {
+        p.groups[fmt.Sprintf("group-%04d", i)] = []string{}
+    }
+
+    // per_page is capped at maxGroupsPerPage
+    var groups map[string]*GroupSummary
+    w := getGroups(t, p, fmt.Sprintf("?per_page=%d", maxGroupsPerPage*10), &groups)
+    assert.Len(t, groups, maxGroupsPerPage)
+    assert.Equal(t, strconv.Itoa(maxGroupsPerPage+5), w.Header().Get("X-Total-Count"))
+
+    // Pages past the end are empty but still report the total
+    for _, query := range []string{"?page=2&per_page=1000", "?page=9223372036854775807", "?details=true&page=9223372036854775807&per_page=7"} {
+        var raw json.RawMessage
+        w = getGroups(t, p, query, &raw)
+        assert.Contains(t, []string{"{}", "[]"}, strings.TrimSpace(string(raw)), query)
+        assert.Equal(t, strconv.Itoa(maxGroupsPerPage+5), w.Header().Get("X-Total-Count"), query)
+    }
+
+    for _, query := range []string{"?page=-1", "?page=x", "?per_page=-3", "?per_page=x", "?page=99999999999999999999"} {
+        w = serveRequest(p, http.MethodGet, "/api/v4/groups"+query, "reader", "")
+        assert.Equal(t, http.StatusBadRequest, w.Code, query)
+    }
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "context"
+    "crypto/subtle"
+    "net/http"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+const (
+    // Header carrying the read-only token for dashboards and other readers
+    readTokenHeader = "X-Read-Token"
+)
+
+// readTokenRoutes are the routes the read-only token may GET.
+var readTokenRoutes = map[string]bool{
+    "/api/v4/groups":        true,
+    "/api/v4/groups/one":    true,
+    "/api/v4/groups/search": true,
+    "/api/v4/groups/stats":  true,
+    "/api/v4/groups/events": true,
+}
+
+type readTokenContextKey struct{}
+
+// authenticateReadToken checks a request made with the read-only token
+// instead of a Mattermost session. It only lets GET requests to the read
+// routes through and returns the request marked as read-only.
+func (p *Plugin) authenticateReadToken(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+    token := config.GetConfig().ReadOnlyToken
+    if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(readTokenHeader)), []byte(token)) != 1 {
+        http.Error(w, "Invalid read token", http.StatusUnauthorized)
+        return r, false
+    }
+
+    if r.Method != http.MethodGet || !readTokenRoutes[r.URL.Path] {
+        http.Error(w, "The read token only allows reading groups", http.StatusForbidden)
+        return r, false
+    }
+
+    return r.WithContext(context.WithValue(r.Context(), readTokenContextKey{}, true)), true
+}
+
+// hasReadToken reports whether the request was authenticated with the
+// read-only token, which grants the read access of a system admin.
+func hasReadToken(r *http.Request) bool {
+    readOnly, _ := r.Context().Value(readTokenContextKey{}).(bool)
+    return readOnly
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "sync/atomic"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// recipientFilter decides which group members should be notified about a
+// post. Every notification path goes through it so the author, bots,
+// deactivated users and users who muted the channel are excluded
+// consistently. Decisions are cached for the lifetime of the filter.
+type recipientFilter struct {
+    p         *Plugin
+    authorID  string
+    channelID string
+    decided   map[string]bool
+}
+
+func (p *Plugin) newRecipientFilter(post *model.Post) *recipientFilter {
+    return &recipientFilter{
+        p:         p,
+        authorID:  post.UserId,
+        channelID: post.ChannelId,
+        decided:   make(map[string]bool),
+    }
+}
+
+// allowed reports whether the user should receive a notification.
+func (f *recipientFilter) allowed(userID string) bool {
+    if ok, seen := f.decided[userID]; seen {
+        atomic.AddInt64(&f.p.metrics.recipientHits, 1)
+        return ok
+    }
+    atomic.AddInt64(&f.p.metrics.recipientMisses, 1)
+
+    ok := f.check(userID)
+    f.decided[userID] = ok
+    return ok
+}
+
+func (f *recipientFilter) check(userID string) bool {
+    if userID == f.authorID {
+        return false
+    }
+
+    user, appErr := f.p.API.GetUser(userID)
+    if appErr != nil {
+        return false
+    }
+
+    if user.IsBot || user.DeleteAt != 0 {
+        return false
+    }
+
+    if member, appErr := f.p.API.GetChannelMember(f.channelID, userID); appErr == nil && member.IsChannelMuted() {
+        return false
+    }
+
+    return true
+}
+
+// filter returns the members that should be notified, in order.
+func (f *recipientFilter) filter(userIDs []string) []string {
+    recipients := make([]string, 0, len(userIDs))
+    for _, userID := range userIDs {
+        if f.allowed(userID) {
+            recipients = append(recipients, userID)
+        }
+    }
+    return recipients
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+)
+
+// expectRecipients sets up one member of each kind the recipient filter
+// tells apart: the author, a bot, a deactivated user, a user who muted the
+// channel, a user who no longer exists and a regular member.
+func expectRecipients(api *testAPI) {
+    notFound := model.NewAppError("test", "not_found", nil, "", http.StatusNotFound)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "author"},
+        &model.User{Id: "bot", Username: "bot", IsBot: true},
+        &model.User{Id: "deactivated", Username: "deactivated", DeleteAt: 1},
+        &model.User{Id: "muted", Username: "muted"},
+        &model.User{Id: "member", Username: "member"},
+    )
+    api.On("GetUser", "missing").Return(nil, notFound).Maybe()
+    api.On("GetChannelMember", "channel", "muted").Return(&model.ChannelMember{
+        ChannelId:   "channel",
+        UserId:      "muted",
+        NotifyProps: model.StringMap{model.MarkUnreadNotifyProp: model.ChannelMarkUnreadMention},
+    }, nil).Maybe()
+    api.On("GetChannelMember", "channel", mock.Anything).Return(&model.ChannelMember{
+        ChannelId:   "channel",
+        NotifyProps: model.GetDefaultChannelNotifyProps(),
+    }, nil).Maybe()
+}
+
+var filteredMembers = []string{"author", "bot", "deactivated", "muted", "missing", "member"}
+
+func TestRecipientFilterExcludesEachKind(t *testing.T) {
+    api := newTestAPI(t)
+    expectRecipients(api)
+    p := newTestPlugin(t, api)
+
+    filter := p.newRecipientFilter(&model.Post{UserId: "author", ChannelId: "channel"})
+
+    for _, userID := range filteredMembers {
+        assert.Equal(t, userID == "member", filter.allowed(userID), userID)
+    }
+    assert.Equal(t, []string{"member"}, filter.filter(filteredMembers))
+
+    // Decisions are cached for the lifetime of the filter
+    api.AssertNumberOfCalls(t, "GetUser", len(filteredMembers)-1)
+}
+
+func TestMentionNotifiesOnlyAllowedRecipients(t *testing.T) {
+    api := newTestAPI(t)
+    expectRecipients(api)
+    expectNotifications(api, "channel")
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", filteredMembers, "", "creator"))
+
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", ChannelId: "channel", Message: "@dev ping"})
+    p.MessageHasBeenPosted(&plugin.Context{}, post)
+
+    assert.Equal(t, []string{"member"}, ephemeralRecipients(api))
+}
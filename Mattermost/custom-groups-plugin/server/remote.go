@@ -0,0 +1,122 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// Timeout for requests to the remote server
+const remoteRequestTimeout = 30 * time.Second
+
+// pushGroup sends a group's members by username to the bulk import endpoint
+// of the configured remote server and returns the remote outcome.
+func (p *Plugin) pushGroup(groupName string) (*BulkGroupOutcome, error) {
+    configuration := config.GetConfig()
+    if configuration.RemoteServerURL == "" || configuration.RemoteServerToken == "" {
+        return nil, fmt.Errorf("no remote server is configured")
+    }
+
+    export, err := p.exportGroup(groupName)
+    if err != nil {
+        return nil, err
+    }
+
+    body, err := json.Marshal([]BulkImportGroup{{Name: groupName, Members: export.Members, Tags: export.Tags}})
+    if err != nil {
+        return nil, err
+    }
+
+    url := configuration.RemoteServerURL + "/plugins/" + pluginID + "/api/v4/groups/import"
+    req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+configuration.RemoteServerToken)
+    req.Header.Set("X-Requested-With", "XMLHttpRequest")
+
+    client := &http.Client{Timeout: remoteRequestTimeout}
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("remote server unreachable: %v", err)
+    }
+    defer resp.Body.Close()
+
+    data, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return nil, err
+    }
+
+    switch {
+    case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+        return nil, fmt.Errorf("the remote server rejected the token (HTTP %d), it must belong to a system admin", resp.StatusCode)
+    case resp.StatusCode == http.StatusNotFound:
+        return nil, fmt.Errorf("the remote server does not have a compatible custom groups plugin")
+    case resp.StatusCode != http.StatusOK:
+        return nil, fmt.Errorf("the remote server returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+    }
+
+    var result BulkImportResult
+    if err := json.Unmarshal(data, &result); err != nil || len(result.Groups) != 1 {
+        return nil, fmt.Errorf("unexpected response from the remote server")
+    }
+
+    return result.Groups[0], nil
+}
+
+// pushCommand exports a group to the remote server. Only system admins can
+// use it.
+func (p *Plugin) pushCommand(userID, trigger string, args []string) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can push groups to another server",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(args) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name: `/%s push group_name`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := args[0]
+
+    outcome, err := p.pushGroup(groupName)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, fmt.Sprintf("Failed to push group %s: %v", groupName, err)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if outcome.Error != "" {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("The remote server did not import group %s: %s", groupName, outcome.Error),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    action := "Updated"
+    if outcome.Created {
+        action = "Created"
+    }
+    text := fmt.Sprintf("%s group %s on the remote server (%d added, %d already members, %d not found)", action, groupName, len(outcome.Added), len(outcome.Skipped), len(outcome.Errors))
+    if len(outcome.Errors) > 0 {
+        text += fmt.Sprintf("\nUsers not found on the remote server: %s", strings.Join(outcome.Errors, ", "))
+    }
+
+    return &model.CommandResponse{
+        Text: text,
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
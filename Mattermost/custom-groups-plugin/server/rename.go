@@ -0,0 +1,161 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// groupRename is a planned rename of one group.
+type groupRename struct {
+    From string
+    To   string
+}
+
+// planPrefixRename lists the renames that replace oldPrefix with newPrefix.
+// It fails with ErrReservedName when a target name is reserved, and with
+// ErrGroupExists when a target name is taken by a group that is not itself
+// being renamed. Callers must hold groupMutex.
+func (p *Plugin) planPrefixRename(oldPrefix, newPrefix string) ([]groupRename, error) {
+    var renames []groupRename
+    sources := make(map[string]bool)
+    for groupName := range p.groups {
+        if strings.HasPrefix(groupName, oldPrefix) {
+            renames = append(renames, groupRename{From: groupName, To: newPrefix + strings.TrimPrefix(groupName, oldPrefix)})
+            sources[groupName] = true
+        }
+    }
+
+    sort.Slice(renames, func(i, j int) bool { return renames[i].From < renames[j].From })
+
+    configuration := config.GetConfig()
+    for _, rename := range renames {
+        if configuration.IsReservedGroupName(rename.To) {
+            return nil, groupError(ErrReservedName, rename.To)
+        }
+        if p.groupNameTaken(rename.To) && !sources[rename.To] {
+            return nil, groupError(ErrGroupExists, rename.To)
+        }
+    }
+
+    return renames, nil
+}
+
+// renameGroupsByPrefix applies a prefix rename to every matching group. The
+// plan is recomputed under the write lock so nothing is renamed if a
+// collision appeared since it was shown. Members are logged as leaving the
+// old groups and joining the new ones. actorID is the renaming user's ID.
+func (p *Plugin) renameGroupsByPrefix(oldPrefix, newPrefix, actorID string) ([]groupRename, error) {
+    p.groupMutex.Lock()
+    renames, err := p.planPrefixRename(oldPrefix, newPrefix)
+    if err != nil {
+        p.groupMutex.Unlock()
+        return nil, err
+    }
+
+    members := make(map[string][]string, len(renames))
+    metadata := make(map[string]*GroupMetadata)
+    for _, rename := range renames {
+        members[rename.To] = p.groups[rename.From]
+        p.events.append(memberEvents(EventMemberRemoved, rename.From, actorID, p.groups[rename.From]))
+        if m, ok := p.groupMetadata[rename.From]; ok {
+            metadata[rename.To] = m
+        }
+        delete(p.groups, rename.From)
+        delete(p.groupMetadata, rename.From)
+    }
+    for _, rename := range renames {
+        p.groups[rename.To] = members[rename.To]
+        p.events.append(memberEvents(EventMemberAdded, rename.To, actorID, members[rename.To]))
+    }
+    for groupName, m := range metadata {
+        p.groupMetadata[groupName] = m
+    }
+    renamed := make(map[string]string, len(renames))
+    for _, rename := range renames {
+        renamed[rename.From] = rename.To
+    }
+    p.renameSubgroupRefs(renamed)
+    p.groupMutex.Unlock()
+
+    if err := p.saveGroupMetadata(); err != nil {
+        return nil, err
+    }
+
+    if err := p.saveGroupEvents(); err != nil {
+        return nil, err
+    }
+
+    // Save to persistent storage
+    return renames, p.saveGroups()
+}
+
+// renameBulkCommand shows the planned renames, or applies them when confirmed.
+// Only system admins can rename groups in bulk.
+func (p *Plugin) renameBulkCommand(userID, oldPrefix, newPrefix string, confirm bool) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can rename groups in bulk",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if oldPrefix == newPrefix {
+        return &model.CommandResponse{
+            Text: "The old and new prefixes are the same",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var renames []groupRename
+    var err error
+    if confirm {
+        renames, err = p.renameGroupsByPrefix(oldPrefix, newPrefix, userID)
+    } else {
+        p.groupMutex.RLock()
+        renames, err = p.planPrefixRename(oldPrefix, newPrefix)
+        p.groupMutex.RUnlock()
+    }
+
+    if errors.Is(err, ErrGroupExists) || errors.Is(err, ErrReservedName) {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Cannot rename groups, %v. No groups were renamed", err),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    } else if err != nil {
+        return &model.CommandResponse{
+            Text: "Failed to save changes",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(renames) == 0 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("No groups start with %s", oldPrefix),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var text strings.Builder
+    if confirm {
+        text.WriteString(fmt.Sprintf("Renamed %d groups:\n", len(renames)))
+    } else {
+        text.WriteString(fmt.Sprintf("The following %d groups will be renamed:\n", len(renames)))
+    }
+    for _, rename := range renames {
+        text.WriteString(fmt.Sprintf("- %s -> %s\n", rename.From, rename.To))
+    }
+    if !confirm {
+        text.WriteString(fmt.Sprintf("\nRun `/%s rename-bulk %s %s --confirm` to apply.", config.GetConfig().CommandTrigger, oldPrefix, newPrefix))
+    }
+
+    return &model.CommandResponse{
+        Text: text.String(),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
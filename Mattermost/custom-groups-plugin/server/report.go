@@ -0,0 +1,39 @@
+package main
+
+// GroupReport is the full data of one group for dashboards, returned by
+// `/group list --json` and the stats endpoint with details=true.
+type GroupReport struct {
+    Name        string   `json:"name"`
+    Description string   `json:"description,omitempty"`
+    Tags        []string `json:"tags,omitempty"`
+    CreatedBy   string   `json:"created_by,omitempty"` // user ID
+    CreatedAt   int64    `json:"created_at,omitempty"` // milliseconds since epoch
+    UpdatedAt   int64    `json:"updated_at,omitempty"` // last membership change
+    MemberCount int      `json:"member_count"`
+    Members     []string `json:"members"`  // user IDs
+    Mentions    int64    `json:"mentions"` // posts mentioning the group since activation
+}
+
+// groupReports returns the reports of the named groups in order. Callers must
+// hold groupMutex.
+func (p *Plugin) groupReports(groupNames []string, mentions map[string]int64) []*GroupReport {
+    reports := make([]*GroupReport, 0, len(groupNames))
+    for _, groupName := range groupNames {
+        members := p.groups[groupName]
+        report := &GroupReport{
+            Name:        groupName,
+            MemberCount: len(members),
+            Members:     append([]string{}, members...),
+            Mentions:    mentions[groupName],
+        }
+        if metadata, ok := p.groupMetadata[groupName]; ok {
+            report.Description = metadata.Description
+            report.Tags = metadata.Tags
+            report.CreatedBy = metadata.CreatedBy
+            report.CreatedAt = metadata.CreatedAt
+            report.UpdatedAt = metadata.UpdatedAt
+        }
+        reports = append(reports, report)
+    }
+    return reports
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// How often schedules are checked for members that left their group
+const scheduleCheckInterval = time.Hour
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// MemberSchedule limits when a member is included in group mentions, e.g. for
+// on-call rotations. Members without a schedule are always included.
+type MemberSchedule struct {
+    Days     []time.Weekday `json:"days"`
+    Start    int            `json:"start,omitempty"` // minutes after midnight; Start == End means all day
+    End      int            `json:"end,omitempty"`   // minutes after midnight; before Start for overnight shifts
+    Timezone string         `json:"timezone"`        // IANA time zone name
+}
+
+// activeAt reports whether the schedule includes the given time. Overnight
+// shifts belong to the day they start on.
+func (s *MemberSchedule) activeAt(now time.Time) bool {
+    location, err := time.LoadLocation(s.Timezone)
+    if err != nil {
+        location = time.UTC
+    }
+    now = now.In(location)
+    minute := now.Hour()*60 + now.Minute()
+    today := s.hasDay(now.Weekday())
+
+    switch {
+    case s.Start == s.End:
+        return today
+    case s.Start < s.End:
+        return today && minute >= s.Start && minute < s.End
+    default:
+        yesterday := s.hasDay((now.Weekday() + 6) % 7)
+        return (today && minute >= s.Start) || (yesterday && minute < s.End)
+    }
+}
+
+func (s *MemberSchedule) hasDay(day time.Weekday) bool {
+    for _, scheduled := range s.Days {
+        if scheduled == day {
+            return true
+        }
+    }
+    return false
+}
+
+func (s *MemberSchedule) String() string {
+    days := make([]string, 0, len(s.Days))
+    for _, day := range s.Days {
+        days = append(days, weekdayNames[day])
+    }
+
+    text := strings.Join(days, ",")
+    if s.Start != s.End {
+        text += fmt.Sprintf(" %02d:%02d-%02d:%02d", s.Start/60, s.Start%60, s.End/60, s.End%60)
+    }
+    return text + " " + s.Timezone
+}
+
+// parseSchedule parses "days [HH:MM-HH:MM] [timezone]" where days is a comma
+// separated list of days or day ranges, e.g. "mon-wed" or "thu,fri".
+func parseSchedule(args []string) (*MemberSchedule, error) {
+    if len(args) == 0 {
+        return nil, fmt.Errorf("days are required, e.g. mon-wed")
+    }
+
+    schedule := &MemberSchedule{Timezone: "UTC"}
+    seen := make(map[time.Weekday]bool)
+    for _, part := range strings.Split(strings.ToLower(args[0]), ",") {
+        bounds := strings.SplitN(part, "-", 2)
+        first, ok := parseWeekday(bounds[0])
+        if !ok {
+            return nil, fmt.Errorf("invalid day %q", bounds[0])
+        }
+        last := first
+        if len(bounds) == 2 {
+            if last, ok = parseWeekday(bounds[1]); !ok {
+                return nil, fmt.Errorf("invalid day %q", bounds[1])
+            }
+        }
+
+        // Ranges may wrap around the week, e.g. fri-mon
+        for day := first; ; day = (day + 1) % 7 {
+            if !seen[day] {
+                seen[day] = true
+                schedule.Days = append(schedule.Days, day)
+            }
+            if day == last {
+                break
+            }
+        }
+    }
+    sort.Slice(schedule.Days, func(i, j int) bool { return schedule.Days[i] < schedule.Days[j] })
+
+    rest := args[1:]
+    if len(rest) > 0 && strings.Contains(rest[0], ":") {
+        bounds := strings.SplitN(rest[0], "-", 2)
+        if len(bounds) != 2 {
+            return nil, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", rest[0])
+        }
+        var err error
+        if schedule.Start, err = parseClock(bounds[0]); err != nil {
+            return nil, err
+        }
+        if schedule.End, err = parseClock(bounds[1]); err != nil {
+            return nil, err
+        }
+        rest = rest[1:]
+    }
+
+    if len(rest) > 0 {
+        if _, err := time.LoadLocation(rest[0]); err != nil {
+            return nil, fmt.Errorf("unknown time zone %q", rest[0])
+        }
+        schedule.Timezone = rest[0]
+        rest = rest[1:]
+    }
+
+    if len(rest) > 0 {
+        return nil, fmt.Errorf("unexpected %q", strings.Join(rest, " "))
+    }
+
+    return schedule, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+    for i, weekday := range weekdayNames {
+        if strings.HasPrefix(strings.TrimSpace(name), weekday) {
+            return time.Weekday(i), true
+        }
+    }
+    return 0, false
+}
+
+// parseClock parses HH:MM into minutes after midnight.
+func parseClock(value string) (int, error) {
+    parts := strings.SplitN(value, ":", 2)
+    if len(parts) != 2 {
+        return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+    }
+    hours, err := strconv.Atoi(parts[0])
+    if err != nil || hours < 0 || hours > 23 {
+        return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+    }
+    minutes, err := strconv.Atoi(parts[1])
+    if err != nil || minutes < 0 || minutes > 59 {
+        return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+    }
+    return hours*60 + minutes, nil
+}
+
+// activeMembers returns the members included in a mention of the group at
+// the given time. Callers must hold groupMutex.
+func (p *Plugin) activeMembers(groupName string, members []string, now time.Time) []string {
+    metadata, ok := p.groupMetadata[groupName]
+    if !ok || len(metadata.Schedules) == 0 {
+        return members
+    }
+
+    active := []string{}
+    for _, userID := range members {
+        if schedule, ok := metadata.Schedules[userID]; !ok || schedule.activeAt(now) {
+            active = append(active, userID)
+        }
+    }
+    return active
+}
+
+// setMemberSchedule sets or clears (nil schedule) the schedule of a member.
+func (p *Plugin) setMemberSchedule(groupName, userID string, schedule *MemberSchedule) error {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    if !contains(members, userID) {
+        p.groupMutex.Unlock()
+        return groupError(ErrNotMember, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    if schedule == nil {
+        delete(metadata.Schedules, userID)
+    } else {
+        if metadata.Schedules == nil {
+            metadata.Schedules = make(map[string]*MemberSchedule)
+        }
+        metadata.Schedules[userID] = schedule
+    }
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// scheduleText lists the schedules of a group and who is currently active.
+func (p *Plugin) scheduleText(groupName string) (string, error) {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    members, exists := p.groups[groupName]
+    if !exists {
+        return "", groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata, ok := p.groupMetadata[groupName]
+    if !ok || len(metadata.Schedules) == 0 {
+        return fmt.Sprintf("Group %s has no schedules, all members are always mentioned", groupName), nil
+    }
+
+    now := time.Now()
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Schedules of group %s (%d of %d members active now):\n", groupName, len(p.activeMembers(groupName, members, now)), len(members)))
+    for _, userID := range members {
+        schedule, ok := metadata.Schedules[userID]
+        if !ok {
+            continue
+        }
+        username := userID
+        if user, err := p.API.GetUser(userID); err == nil {
+            username = user.Username
+        }
+        state := "off"
+        if schedule.activeAt(now) {
+            state = "active"
+        }
+        text.WriteString(fmt.Sprintf("- @%s: %s (%s)\n", username, schedule.String(), state))
+    }
+
+    return text.String(), nil
+}
+
+// scheduleCommand shows the schedules of a group or sets or clears the
+// schedule of one member.
+func (p *Plugin) scheduleCommand(trigger string, args []string) *model.CommandResponse {
+    if len(args) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name: `/%s schedule group_name [@user days [HH:MM-HH:MM] [timezone]]` or `/%s schedule group_name @user none`", trigger, trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := args[0]
+
+    if len(args) == 1 {
+        text, err := p.scheduleText(groupName)
+        if err != nil {
+            text = commandErrorText(err, groupName, err.Error())
+        }
+        return &model.CommandResponse{
+            Text: text,
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    username := strings.TrimPrefix(args[1], "@")
+    user, appErr := p.API.GetUserByUsername(username)
+    if appErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("User %s not found", username),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    var schedule *MemberSchedule
+    if len(args) != 3 || !strings.EqualFold(args[2], "none") {
+        var err error
+        if schedule, err = parseSchedule(args[2:]); err != nil {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Invalid schedule: %v", err),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+    }
+
+    if err := p.setMemberSchedule(groupName, user.Id, schedule); err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if schedule == nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("@%s is now always mentioned with group %s", username, groupName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("@%s is now mentioned with group %s on %s", username, groupName, schedule.String()),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
+
+// checkSchedules drops schedules of users who are no longer members and
+// warns about groups whose scheduled members leave nobody to mention.
+func (p *Plugin) checkSchedules(now time.Time) {
+    p.groupMutex.Lock()
+    pruned := 0
+    var uncovered []string
+    for groupName, metadata := range p.groupMetadata {
+        if len(metadata.Schedules) == 0 {
+            continue
+        }
+
+        members := p.groups[groupName]
+        for userID := range metadata.Schedules {
+            if !contains(members, userID) {
+                delete(metadata.Schedules, userID)
+                pruned++
+            }
+        }
+
+        if len(members) > 0 && len(p.activeMembers(groupName, members, now)) == 0 {
+            uncovered = append(uncovered, groupName)
+        }
+    }
+    p.groupMutex.Unlock()
+
+    for _, groupName := range uncovered {
+        p.API.LogWarn("No member of the group is currently scheduled", "group", groupName)
+    }
+
+    if pruned > 0 {
+        p.API.LogInfo("Removed schedules of former group members", "count", pruned)
+        if err := p.saveGroupMetadata(); err != nil {
+            p.API.LogError("Failed to save group metadata", "error", err.Error())
+        }
+    }
+}
+
+// runScheduleChecks checks schedules periodically until stop is closed.
+func (p *Plugin) runScheduleChecks(stop <-chan struct{}) {
+    ticker := time.NewTicker(scheduleCheckInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case now := <-ticker.C:
+            p.checkSchedules(now)
+        }
+    }
+}
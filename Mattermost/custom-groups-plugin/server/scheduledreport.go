@@ -0,0 +1,157 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+const (
+    // Key of the time the last membership report was posted, in milliseconds
+    lastReportKey = "custom_groups_last_report"
+
+    // How often the report schedule is checked
+    reportCheckInterval = 10 * time.Minute
+
+    // Groups listed at most in a membership report
+    maxReportGroups = 50
+)
+
+// reportRow is one group in a membership report.
+type reportRow struct {
+    name    string
+    members int
+    added   int
+    removed int
+}
+
+// runReports posts the membership report whenever it is due, until stop is
+// closed.
+func (p *Plugin) runReports(stop <-chan struct{}) {
+    ticker := time.NewTicker(reportCheckInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case now := <-ticker.C:
+            p.checkReport(now)
+        }
+    }
+}
+
+// checkReport posts the membership report to the report channel when the
+// interval passed since the last one. The first check after the channel is
+// configured only starts the schedule. The time of the report is claimed with
+// compare-and-set so only one server of a cluster posts it.
+func (p *Plugin) checkReport(now time.Time) {
+    configuration := config.GetConfig()
+    if configuration.ReportChannel == "" {
+        return
+    }
+
+    stored, appErr := p.API.KVGet(lastReportKey)
+    if appErr != nil {
+        p.API.LogError("Failed to load the time of the last membership report", "error", appErr.Error())
+        return
+    }
+
+    var lastReport int64
+    if stored != nil {
+        parsed, err := strconv.ParseInt(string(stored), 10, 64)
+        if err != nil {
+            p.API.LogWarn("Ignoring invalid time of the last membership report", "value", string(stored))
+        }
+        lastReport = parsed
+
+        interval := time.Duration(configuration.ReportIntervalHours) * time.Hour
+        if now.Sub(model.GetTimeForMillis(lastReport)) < interval {
+            return
+        }
+    }
+
+    claimed, appErr := p.API.KVCompareAndSet(lastReportKey, stored, []byte(strconv.FormatInt(model.GetMillisForTime(now), 10)))
+    if appErr != nil {
+        p.API.LogError("Failed to record the time of the membership report", "error", appErr.Error())
+        return
+    }
+    if !claimed || stored == nil {
+        return
+    }
+
+    if _, appErr := p.API.CreatePost(&model.Post{
+        UserId:    p.botID,
+        ChannelId: configuration.ReportChannel,
+        Message:   p.membershipReport(lastReport),
+    }); appErr != nil {
+        p.API.LogError("Failed to post the membership report", "channel_id", configuration.ReportChannel, "error", appErr.Error())
+    }
+}
+
+// membershipReport describes the size of every group and the members added
+// and removed since the given time in milliseconds, largest groups first.
+func (p *Plugin) membershipReport(since int64) string {
+    events, complete := p.events.after(since)
+
+    p.groupMutex.RLock()
+    rows := make([]*reportRow, 0, len(p.groups))
+    byName := make(map[string]*reportRow, len(p.groups))
+    memberships := 0
+    for groupName, members := range p.groups {
+        row := &reportRow{name: groupName, members: len(members)}
+        rows = append(rows, row)
+        byName[groupName] = row
+        memberships += len(members)
+    }
+    p.groupMutex.RUnlock()
+
+    added, removed := 0, 0
+    for _, event := range events {
+        switch event.Type {
+        case EventMemberAdded:
+            added++
+            if row := byName[event.Group]; row != nil {
+                row.added++
+            }
+        case EventMemberRemoved:
+            removed++
+            if row := byName[event.Group]; row != nil {
+                row.removed++
+            }
+        }
+    }
+
+    sort.Slice(rows, func(i, j int) bool {
+        if rows[i].members != rows[j].members {
+            return rows[i].members > rows[j].members
+        }
+        return rows[i].name < rows[j].name
+    })
+
+    var text strings.Builder
+    text.WriteString("#### Group membership report\n")
+    fmt.Fprintf(&text, "%d groups with %d memberships. Since the last report, %d members were added and %d removed.\n", len(rows), memberships, added, removed)
+    if !complete {
+        text.WriteString("Earlier changes are no longer in the event log, so the counts are incomplete.\n")
+    }
+    if len(rows) == 0 {
+        return text.String()
+    }
+
+    text.WriteString("\n| Group | Members | Added | Removed |\n|:--|--:|--:|--:|\n")
+    for i, row := range rows {
+        if i == maxReportGroups {
+            fmt.Fprintf(&text, "\n...and %d more groups", len(rows)-maxReportGroups)
+            break
+        }
+        fmt.Fprintf(&text, "| @%s | %d | %d | %d |\n", row.name, row.members, row.added, row.removed)
+    }
+    return text.String()
+}
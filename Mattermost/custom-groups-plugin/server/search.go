@@ -0,0 +1,61 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// GroupSearchResult is a group matching a search, with its member count
+// instead of the member list to keep responses small.
+type GroupSearchResult struct {
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    MemberCount int    `json:"member_count"`
+}
+
+// searchGroups returns the groups whose name contains the canonical term,
+// sorted by name. Callers must hold groupMutex.
+func (p *Plugin) searchGroups(term string) []*GroupSearchResult {
+    term = canonicalGroupName(term)
+
+    results := []*GroupSearchResult{}
+    for groupName, members := range p.groups {
+        if !strings.Contains(canonicalGroupName(groupName), term) {
+            continue
+        }
+
+        result := &GroupSearchResult{Name: groupName, MemberCount: len(members)}
+        if metadata := p.groupMetadata[groupName]; metadata != nil {
+            result.Description = metadata.Description
+        }
+        results = append(results, result)
+    }
+    sort.Slice(results, func(i, j int) bool {
+        return results[i].Name < results[j].Name
+    })
+    return results
+}
+
+// handleSearchGroups returns the groups whose name contains the term query
+// parameter, for typeahead in the webapp.
+func (p *Plugin) handleSearchGroups(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    term := r.URL.Query().Get("term")
+    if strings.TrimSpace(term) == "" {
+        http.Error(w, "Search term is required", http.StatusBadRequest)
+        return
+    }
+
+    p.groupMutex.RLock()
+    results := p.searchGroups(term)
+    p.groupMutex.RUnlock()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(results)
+}
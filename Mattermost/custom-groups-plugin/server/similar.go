@@ -0,0 +1,281 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// Shortest name stem compared by prefix, so "qa" is not matched with "qa-leads"
+const minSimilarStem = 3
+
+// SimilarPair is two groups that may be redundant.
+type SimilarPair struct {
+    Group        string `json:"group"`
+    Other        string `json:"other"`
+    SimilarNames bool   `json:"similar_names"`
+    Overlap      int    `json:"overlap"` // percent of the combined members that are in both groups
+}
+
+// SimilarCluster is a set of groups linked by similar pairs, e.g. "dev",
+// "devs" and "developers".
+type SimilarCluster struct {
+    Groups []string       `json:"groups"`
+    Pairs  []*SimilarPair `json:"pairs"`
+}
+
+// similarNames reports whether two group names look like variants of one
+// name: a likely typo, or one name, without a plural "s", starting the other.
+func similarNames(a, b string) bool {
+    maxDistance := 2
+    if len(a) <= 4 || len(b) <= 4 {
+        maxDistance = 1
+    }
+    if editDistance(a, b) <= maxDistance {
+        return true
+    }
+
+    shorter, longer := strings.TrimSuffix(a, "s"), strings.TrimSuffix(b, "s")
+    if len(shorter) > len(longer) {
+        shorter, longer = longer, shorter
+    }
+    return len(shorter) >= minSimilarStem && strings.HasPrefix(longer, shorter)
+}
+
+// memberOverlap returns the Jaccard similarity of two member lists as a
+// percentage: the members in both over the members in either.
+func memberOverlap(a, b []string) int {
+    set := make(map[string]bool, len(a))
+    for _, userID := range a {
+        set[userID] = true
+    }
+
+    shared := 0
+    union := len(set)
+    counted := make(map[string]bool, len(b))
+    for _, userID := range b {
+        if counted[userID] {
+            continue
+        }
+        counted[userID] = true
+        if set[userID] {
+            shared++
+        } else {
+            union++
+        }
+    }
+
+    if union == 0 {
+        return 0
+    }
+    return shared * 100 / union
+}
+
+// similarGroups pairs the groups with similar names or with at least
+// minOverlap percent of their members in common, and joins the pairs into
+// clusters. Clusters are sorted by their first group name.
+func (p *Plugin) similarGroups(minOverlap int) []*SimilarCluster {
+    p.groupMutex.RLock()
+    names := make([]string, 0, len(p.groups))
+    for groupName := range p.groups {
+        names = append(names, groupName)
+    }
+    sort.Strings(names)
+
+    var pairs []*SimilarPair
+    for i, groupName := range names {
+        for _, other := range names[i+1:] {
+            pair := &SimilarPair{
+                Group:        groupName,
+                Other:        other,
+                SimilarNames: similarNames(groupName, other),
+                Overlap:      memberOverlap(p.groups[groupName], p.groups[other]),
+            }
+            if pair.SimilarNames || pair.Overlap >= minOverlap {
+                pairs = append(pairs, pair)
+            }
+        }
+    }
+    p.groupMutex.RUnlock()
+
+    // Link the groups of each pair, pointing every group at its cluster root
+    root := make(map[string]string)
+    var find func(string) string
+    find = func(groupName string) string {
+        parent, ok := root[groupName]
+        if !ok || parent == groupName {
+            return groupName
+        }
+        root[groupName] = find(parent)
+        return root[groupName]
+    }
+    for _, pair := range pairs {
+        a, b := find(pair.Group), find(pair.Other)
+        if a != b {
+            if b < a {
+                a, b = b, a
+            }
+            root[a] = a
+            root[b] = a
+        }
+    }
+
+    byRoot := make(map[string]*SimilarCluster)
+    var clusters []*SimilarCluster
+    for _, pair := range pairs {
+        groupRoot := find(pair.Group)
+        cluster, ok := byRoot[groupRoot]
+        if !ok {
+            cluster = &SimilarCluster{Groups: []string{}, Pairs: []*SimilarPair{}}
+            byRoot[groupRoot] = cluster
+            clusters = append(clusters, cluster)
+        }
+        for _, groupName := range []string{pair.Group, pair.Other} {
+            if !contains(cluster.Groups, groupName) {
+                cluster.Groups = append(cluster.Groups, groupName)
+            }
+        }
+        cluster.Pairs = append(cluster.Pairs, pair)
+    }
+
+    for _, cluster := range clusters {
+        sort.Strings(cluster.Groups)
+    }
+    sort.Slice(clusters, func(i, j int) bool { return clusters[i].Groups[0] < clusters[j].Groups[0] })
+
+    return clusters
+}
+
+// similarCommand reports clusters of groups that may be redundant and
+// suggests merges. It changes nothing. Only system admins can use it.
+func (p *Plugin) similarCommand(userID, trigger string, asJSON bool) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can look for similar groups",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    minOverlap := config.GetConfig().SimilarGroupOverlap
+    clusters := p.similarGroups(minOverlap)
+
+    if asJSON {
+        if clusters == nil {
+            clusters = []*SimilarCluster{}
+        }
+        return jsonResponse(clusters)
+    }
+
+    if len(clusters) == 0 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("No groups have similar names or share at least %d%% of their members", minOverlap),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    p.groupMutex.RLock()
+    sizes := make(map[string]int)
+    for _, cluster := range clusters {
+        for _, groupName := range cluster.Groups {
+            sizes[groupName] = len(p.groups[groupName])
+        }
+    }
+    p.groupMutex.RUnlock()
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Found %d clusters of possibly redundant groups:\n", len(clusters)))
+    for _, cluster := range clusters {
+        text.WriteString(fmt.Sprintf("\n**%s**\n", strings.Join(cluster.Groups, ", ")))
+        for _, pair := range cluster.Pairs {
+            var reasons []string
+            if pair.SimilarNames {
+                reasons = append(reasons, "similar names")
+            }
+            reasons = append(reasons, fmt.Sprintf("%d%% shared members", pair.Overlap))
+
+            // Suggest merging the smaller group into the larger one
+            source, target := pair.Group, pair.Other
+            if sizes[source] > sizes[target] {
+                source, target = target, source
+            }
+            text.WriteString(fmt.Sprintf("- %s (%d members) and %s (%d members): %s. Merge with `/%s merge %s %s`\n",
+                pair.Group, sizes[pair.Group], pair.Other, sizes[pair.Other], strings.Join(reasons, ", "), trigger, source, target))
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: text.String(),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
+
+// mergeGroups adds the members of source to target and moves source to the
+// trash, so the merge can be undone with a restore. actorID is the merging
+// user's ID. It returns how many members were added to target.
+func (p *Plugin) mergeGroups(source, target, actorID string) (int, []ExclusiveRemoval, error) {
+    p.groupMutex.RLock()
+    members, exists := p.groups[source]
+    members = append([]string{}, members...)
+    p.groupMutex.RUnlock()
+    if !exists {
+        return 0, nil, groupError(ErrGroupNotFound, source)
+    }
+
+    added, removals, err := p.addGroupMembers(target, members, actorID)
+    if err != nil {
+        return 0, nil, err
+    }
+
+    return added, removals, p.deleteGroup(source, actorID)
+}
+
+// mergeCommand merges one group into another.
+func (p *Plugin) mergeCommand(userID, trigger string, args []string, quiet bool) *model.CommandResponse {
+    if len(args) != 2 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify the group to merge and the group to merge it into: `/%s merge source_group target_group`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    source, target := canonicalGroupName(args[0]), canonicalGroupName(args[1])
+
+    if source == target {
+        return &model.CommandResponse{
+            Text: "Cannot merge a group into itself",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    p.groupMutex.RLock()
+    _, exists := p.groups[source]
+    p.groupMutex.RUnlock()
+    if !exists {
+        return &model.CommandResponse{
+            Text: commandErrorText(ErrGroupNotFound, source, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    added, removals, err := p.mergeGroups(source, target, userID)
+    if err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, target, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    text := fmt.Sprintf("Merged group %s into %s, adding %d members. Administrators can restore %s with `/%s restore %s`",
+        source, target, added, source, trigger, source)
+    if len(removals) > 0 {
+        text += "\n" + exclusiveRemovalText(removals)
+    }
+
+    return &model.CommandResponse{
+        Text: successText(quiet, text),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
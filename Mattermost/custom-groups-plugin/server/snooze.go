@@ -0,0 +1,161 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing channel snoozes in KV store
+    channelSnoozesKey = "custom_groups_snoozes"
+
+    // Longest a channel can be snoozed at once
+    maxSnoozeDuration = 7 * 24 * time.Hour
+)
+
+func (p *Plugin) loadChannelSnoozes() error {
+    p.groupMutex.Lock()
+    defer p.groupMutex.Unlock()
+
+    p.channelSnoozes = make(map[string]int64)
+
+    data, appErr := p.API.KVGet(channelSnoozesKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    if data != nil {
+        if err := json.Unmarshal(data, &p.channelSnoozes); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (p *Plugin) saveChannelSnoozes() error {
+    return p.saveBlob(channelSnoozesKey, func() interface{} { return p.channelSnoozes })
+}
+
+// snoozeRemaining returns how long group mention notifications in the channel
+// stay snoozed, or zero when they are not. Callers must hold groupMutex.
+func (p *Plugin) snoozeRemaining(channelID string, now time.Time) time.Duration {
+    expiresAt, ok := p.channelSnoozes[channelID]
+    if !ok {
+        return 0
+    }
+
+    remaining := time.Unix(0, expiresAt*int64(time.Millisecond)).Sub(now)
+    if remaining <= 0 {
+        return 0
+    }
+    return remaining
+}
+
+// setChannelSnooze snoozes group mention notifications in the channel for the
+// duration, or ends the snooze when the duration is zero. Expired snoozes of
+// other channels are dropped on the way.
+func (p *Plugin) setChannelSnooze(channelID string, duration time.Duration) error {
+    now := time.Now()
+
+    p.groupMutex.Lock()
+    for id := range p.channelSnoozes {
+        if p.snoozeRemaining(id, now) == 0 {
+            delete(p.channelSnoozes, id)
+        }
+    }
+    if duration > 0 {
+        p.channelSnoozes[channelID] = model.GetMillisForTime(now.Add(duration))
+    } else {
+        delete(p.channelSnoozes, channelID)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveChannelSnoozes()
+}
+
+// snoozeCommand snoozes group mention notifications in a channel, reports
+// the remaining time of an active snooze, or ends it. Only channel admins can
+// use it.
+func (p *Plugin) snoozeCommand(args *model.CommandArgs, trigger string, params []string, quiet bool) *model.CommandResponse {
+    if len(params) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a channel and duration: `/%s snooze ~channel 30m` or `/%s snooze ~channel off`", trigger, trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    channelName := strings.TrimPrefix(params[0], "~")
+    channel, appErr := p.API.GetChannelByName(args.TeamId, channelName, false)
+    if appErr != nil {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Channel ~%s not found", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if !p.API.HasPermissionToChannel(args.UserId, channel.Id, model.PermissionManageChannelRoles) {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Only admins of ~%s can snooze its group mentions", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    p.groupMutex.RLock()
+    remaining := p.snoozeRemaining(channel.Id, time.Now())
+    p.groupMutex.RUnlock()
+
+    if len(params) > 1 && params[1] == "off" {
+        if err := p.setChannelSnooze(channel.Id, 0); err != nil {
+            return &model.CommandResponse{
+                Text: "Failed to save changes",
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Group mentions in ~%s notify members again", channelName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    // A repeated command reports the active snooze instead of extending it
+    if remaining > 0 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Group mentions in ~%s are snoozed for another %s. Use `/%s snooze ~%s off` to end the snooze.", channelName, remaining.Round(time.Second), trigger, channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(params) < 2 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Group mentions in ~%s are not snoozed", channelName),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    duration, err := time.ParseDuration(params[1])
+    if err != nil || duration <= 0 || duration > maxSnoozeDuration {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a duration up to %s, e.g. `30m` or `2h`", maxSnoozeDuration),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if err := p.setChannelSnooze(channel.Id, duration); err != nil {
+        return &model.CommandResponse{
+            Text: "Failed to save changes",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: successText(quiet, fmt.Sprintf("Group mentions in ~%s won't notify members for %s", channelName, duration)),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// Sort orders accepted by /group list --sort.
+const (
+    sortByName   = "name"
+    sortBySize   = "size"
+    sortByRecent = "recent"
+)
+
+// sortedGroupNames returns the group names in the given order. Size and recent
+// orders put the largest and most recently changed groups first, with ties
+// kept in name order. Callers must hold groupMutex.
+func (p *Plugin) sortedGroupNames(order string) ([]string, error) {
+    names := make([]string, 0, len(p.groups))
+    for groupName := range p.groups {
+        names = append(names, groupName)
+    }
+    sort.Strings(names)
+
+    switch order {
+    case "", sortByName:
+    case sortBySize:
+        sort.SliceStable(names, func(i, j int) bool {
+            return len(p.groups[names[i]]) > len(p.groups[names[j]])
+        })
+    case sortByRecent:
+        sort.SliceStable(names, func(i, j int) bool {
+            return p.updatedAt(names[i]) > p.updatedAt(names[j])
+        })
+    default:
+        return nil, fmt.Errorf("unknown sort order %q, use %s, %s or %s", order, sortByName, sortBySize, sortByRecent)
+    }
+
+    return names, nil
+}
+
+// updatedAt returns when the membership of a group last changed, or zero if
+// it is unknown.
+func (p *Plugin) updatedAt(groupName string) int64 {
+    if metadata, ok := p.groupMetadata[groupName]; ok {
+        return metadata.UpdatedAt
+    }
+    return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+    "net/http"
+    "regexp"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+)
+
+// sortTestPlugin returns a plugin whose groups differ in size and last
+// change, with ties to check that name order breaks them.
+func sortTestPlugin(t *testing.T, api *testAPI) *Plugin {
+    p := newTestPlugin(t, api)
+    p.groups = map[string][]string{
+        "qa":      {"u1"},
+        "backend": {"u1", "u2"},
+        "design":  {"u1", "u2", "u3"},
+        "ops":     {"u1", "u2"},
+        "docs":    {},
+    }
+    p.groupMetadata = map[string]*GroupMetadata{
+        "qa":      {UpdatedAt: 300},
+        "backend": {UpdatedAt: 100},
+        "design":  {UpdatedAt: 200},
+        "ops":     {UpdatedAt: 300},
+    }
+    return p
+}
+
+func TestSortedGroupNames(t *testing.T) {
+    p := sortTestPlugin(t, newTestAPI(t))
+
+    for order, expected := range map[string][]string{
+        "":           {"backend", "design", "docs", "ops", "qa"},
+        sortByName:   {"backend", "design", "docs", "ops", "qa"},
+        sortBySize:   {"design", "backend", "ops", "qa", "docs"},
+        sortByRecent: {"ops", "qa", "design", "backend", "docs"},
+    } {
+        names, err := p.sortedGroupNames(order)
+        require.NoError(t, err)
+        assert.Equal(t, expected, names, order)
+    }
+
+    _, err := p.sortedGroupNames("members")
+    assert.Error(t, err)
+}
+
+func TestListCommandSorts(t *testing.T) {
+    api := newTestAPI(t)
+    api.On("GetUser", mock.Anything).Return(nil, model.NewAppError("GetUser", "not_found", nil, "", http.StatusNotFound)).Maybe()
+    p := sortTestPlugin(t, api)
+    listed := regexp.MustCompile(`\*\*([a-z]+)\*\*`)
+
+    for _, tc := range []struct {
+        command  string
+        expected []string
+    }{
+        {"/group list", []string{"backend", "design", "docs", "ops", "qa"}},
+        {"/group list --sort size", []string{"design", "backend", "ops", "qa", "docs"}},
+        {"/group list --sort=recent", []string{"ops", "qa", "design", "backend", "docs"}},
+    } {
+        response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "u1", Command: tc.command})
+        var names []string
+        for _, match := range listed.FindAllStringSubmatch(response.Text, -1) {
+            names = append(names, match[1])
+        }
+        assert.Equal(t, tc.expected, names, tc.command)
+    }
+
+    response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "u1", Command: "/group list --sort members"})
+    assert.Contains(t, response.Text, "unknown sort order")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Members whose statuses are loaded per API call
+    statusBatchSize = 100
+
+    // Members whose statuses are loaded at most; larger groups are cut off
+    maxStatusMembers = 500
+
+    // Members listed at most in the status command output
+    maxStatusLines = 50
+)
+
+// statusOrder ranks presence states, most reachable first.
+var statusOrder = map[string]int{
+    model.StatusOnline:  0,
+    model.StatusAway:    1,
+    model.StatusDnd:     2,
+    model.StatusOffline: 3,
+}
+
+// memberStatuses loads the presence of the members in batches. Members
+// without a known status are reported as offline.
+func (p *Plugin) memberStatuses(userIDs []string) map[string]string {
+    statuses := make(map[string]string, len(userIDs))
+    for start := 0; start < len(userIDs); start += statusBatchSize {
+        end := start + statusBatchSize
+        if end > len(userIDs) {
+            end = len(userIDs)
+        }
+
+        loaded, appErr := p.API.GetUserStatusesByIds(userIDs[start:end])
+        if appErr != nil {
+            p.API.LogWarn("Failed to load user statuses", "error", appErr.Error())
+            continue
+        }
+        for _, status := range loaded {
+            statuses[status.UserId] = status.Status
+        }
+    }
+
+    for _, userID := range userIDs {
+        if _, ok := statusOrder[statuses[userID]]; !ok {
+            statuses[userID] = model.StatusOffline
+        }
+    }
+    return statuses
+}
+
+// groupStatusText lists the members of a group with their presence, online
+// members first.
+func (p *Plugin) groupStatusText(groupName string) (string, error) {
+    p.groupMutex.RLock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.RUnlock()
+        return "", groupError(ErrGroupNotFound, groupName)
+    }
+    members = append([]string(nil), members...)
+    p.groupMutex.RUnlock()
+
+    if len(members) == 0 {
+        return fmt.Sprintf("Group %s has no members", groupName), nil
+    }
+
+    checked := members
+    if len(checked) > maxStatusMembers {
+        checked = checked[:maxStatusMembers]
+    }
+
+    statuses := p.memberStatuses(checked)
+    counts := make(map[string]int)
+    for _, userID := range checked {
+        counts[statuses[userID]]++
+    }
+
+    // Stable so members with the same status keep the group's order
+    sorted := append([]string(nil), checked...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return statusOrder[statuses[sorted[i]]] < statusOrder[statuses[sorted[j]]]
+    })
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("**%s**: %d online, %d away, %d do not disturb, %d offline\n",
+        groupName, counts[model.StatusOnline], counts[model.StatusAway], counts[model.StatusDnd], counts[model.StatusOffline]))
+
+    shown := sorted
+    if len(shown) > maxStatusLines {
+        shown = shown[:maxStatusLines]
+    }
+    for _, userID := range shown {
+        username := userID
+        if user, err := p.API.GetUser(userID); err == nil {
+            username = user.Username
+        }
+        text.WriteString(fmt.Sprintf("- @%s: %s\n", username, statuses[userID]))
+    }
+
+    if len(sorted) > len(shown) {
+        text.WriteString(fmt.Sprintf("...and %d more\n", len(sorted)-len(shown)))
+    }
+    if len(members) > len(checked) {
+        text.WriteString(fmt.Sprintf("Only the first %d of %d members were checked.\n", len(checked), len(members)))
+    }
+
+    return text.String(), nil
+}
+
+// statusCommand shows who in a group is currently reachable.
+func (p *Plugin) statusCommand(trigger string, args []string) *model.CommandResponse {
+    if len(args) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name: `/%s status group_name`", trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := args[0]
+
+    text, err := p.groupStatusText(groupName)
+    if err != nil {
+        text = commandErrorText(err, groupName, err.Error())
+    }
+
+    return &model.CommandResponse{
+        Text: text,
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "unicode/utf8"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Prefix of the KV key holding one group's members
+    groupKeyPrefix = "custom_group_"
+
+    // Key of the sorted list of group names, updated with compare-and-set
+    groupIndexKey = "custom_groups_index"
+
+    // Attempts at updating the index or another shared blob before giving up
+    // when other writers keep changing it
+    indexUpdateAttempts = 5
+)
+
+// groupKey returns the KV key of a group's members. Names too long for a KV
+// key are replaced by their hash.
+func groupKey(groupName string) string {
+    key := groupKeyPrefix + groupName
+    if utf8.RuneCountInString(key) <= model.KeyValueKeyMaxRunes {
+        return key
+    }
+    sum := sha256.Sum256([]byte(groupName))
+    return groupKeyPrefix + hex.EncodeToString(sum[:])[:model.KeyValueKeyMaxRunes-len(groupKeyPrefix)]
+}
+
+// loadGroups reads every group listed in the index. Groups stored in the
+// single blob used by earlier versions are split into per-group keys first.
+func (p *Plugin) loadGroups() error {
+    index, appErr := p.API.KVGet(groupIndexKey)
+    if appErr != nil {
+        return appErr
+    }
+    if index == nil {
+        return p.migrateGroupBlob()
+    }
+
+    var names []string
+    if err := json.Unmarshal(index, &names); err != nil {
+        return err
+    }
+
+    groups := make(map[string][]string, len(names))
+    for _, groupName := range names {
+        data, appErr := p.API.KVGet(groupKey(groupName))
+        if appErr != nil {
+            return appErr
+        }
+        if data == nil {
+            p.API.LogWarn("Group listed in the index has no stored members", "group", groupName)
+            continue
+        }
+
+        var members []string
+        if err := json.Unmarshal(data, &members); err != nil {
+            return err
+        }
+        groups[groupName] = members
+    }
+
+    p.groupMutex.Lock()
+    p.groups = groups
+    p.groupMutex.Unlock()
+
+    p.storeMutex.Lock()
+    p.storedGroups = copyGroups(groups)
+    p.storeMutex.Unlock()
+
+    return nil
+}
+
+// migrateGroupBlob moves the groups of the single blob used by earlier
+// versions to one key per group and an index, then deletes the blob. The
+// index is written last, so an interrupted migration runs again on the next
+// activation.
+func (p *Plugin) migrateGroupBlob() error {
+    data, appErr := p.API.KVGet(groupsKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    groups := make(map[string][]string)
+    if data != nil {
+        if err := json.Unmarshal(data, &groups); err != nil {
+            return err
+        }
+    }
+
+    p.groupMutex.Lock()
+    p.groups = groups
+    p.groupMutex.Unlock()
+
+    if err := p.saveGroups(); err != nil {
+        return err
+    }
+
+    if data != nil {
+        if appErr := p.API.KVDelete(groupsKey); appErr != nil {
+            return appErr
+        }
+        p.API.LogInfo("Moved groups to per-group storage", "count", len(groups))
+    }
+
+    return nil
+}
+
+// saveGroups persists the groups that changed since they were last stored,
+// one key per group, and updates the index when groups were created or
+// deleted. The map is snapshotted under the read lock while holding
+// storeMutex, so saves are written in the order their snapshots were taken.
+// It must not be called while holding groupMutex.
+func (p *Plugin) saveGroups() error {
+    p.storeMutex.Lock()
+    defer p.storeMutex.Unlock()
+
+    p.groupMutex.RLock()
+    snapshot := copyGroups(p.groups)
+    p.groupMutex.RUnlock()
+
+    return p.storeGroups(snapshot)
+}
+
+// storeGroups writes the difference between the snapshot and the groups
+// last stored. Callers must hold storeMutex.
+func (p *Plugin) storeGroups(snapshot map[string][]string) error {
+    if p.storedGroups == nil {
+        p.storedGroups = make(map[string][]string)
+    }
+
+    var added, removed []string
+    for groupName, members := range snapshot {
+        stored, exists := p.storedGroups[groupName]
+        if exists && sameMembers(stored, members) {
+            continue
+        }
+
+        data, err := json.Marshal(members)
+        if err != nil {
+            return err
+        }
+        if appErr := p.API.KVSet(groupKey(groupName), data); appErr != nil {
+            return appErr
+        }
+        p.storedGroups[groupName] = members
+        if !exists {
+            added = append(added, groupName)
+        }
+    }
+    for groupName := range p.storedGroups {
+        if _, exists := snapshot[groupName]; !exists {
+            removed = append(removed, groupName)
+        }
+    }
+
+    // Groups are listed in the index only once their members are stored,
+    // and their members are deleted only once they are unlisted
+    if len(added) > 0 || len(removed) > 0 {
+        if err := p.updateGroupIndex(added, removed); err != nil {
+            return err
+        }
+    }
+    for _, groupName := range removed {
+        if appErr := p.API.KVDelete(groupKey(groupName)); appErr != nil {
+            return appErr
+        }
+        delete(p.storedGroups, groupName)
+    }
+
+    return nil
+}
+
+// updateGroupIndex adds and removes names from the stored index with
+// compare-and-set, retrying when another writer changed it in between, so
+// concurrent changes to different groups are all kept.
+func (p *Plugin) updateGroupIndex(added, removed []string) error {
+    for attempt := 0; attempt < indexUpdateAttempts; attempt++ {
+        current, appErr := p.API.KVGet(groupIndexKey)
+        if appErr != nil {
+            return appErr
+        }
+
+        names := []string{}
+        if current != nil {
+            if err := json.Unmarshal(current, &names); err != nil {
+                return err
+            }
+        }
+
+        updated := []string{}
+        for _, groupName := range names {
+            if !contains(removed, groupName) && !contains(updated, groupName) {
+                updated = append(updated, groupName)
+            }
+        }
+        for _, groupName := range added {
+            if !contains(updated, groupName) {
+                updated = append(updated, groupName)
+            }
+        }
+        sort.Strings(updated)
+
+        data, err := json.Marshal(updated)
+        if err != nil {
+            return err
+        }
+
+        ok, appErr := p.API.KVCompareAndSet(groupIndexKey, current, data)
+        if appErr != nil {
+            return appErr
+        }
+        if ok {
+            return nil
+        }
+    }
+
+    return fmt.Errorf("group index changed %d times while being updated", indexUpdateAttempts)
+}
+
+// storeEntries writes the entries of snapshot that differ from stored, the
+// entries as this server last wrote them, into the JSON object at key. The
+// object is updated with compare-and-set and retried when another writer
+// changed it in between, so entries other servers changed are kept. Callers
+// must hold storeMutex.
+func (p *Plugin) storeEntries(key string, stored, snapshot map[string]json.RawMessage) error {
+    for attempt := 0; attempt < indexUpdateAttempts; attempt++ {
+        current, appErr := p.API.KVGet(key)
+        if appErr != nil {
+            return appErr
+        }
+
+        merged := make(map[string]json.RawMessage)
+        if current != nil {
+            if err := json.Unmarshal(current, &merged); err != nil {
+                return err
+            }
+        }
+
+        for name, entry := range snapshot {
+            if previous, ok := stored[name]; !ok || !bytes.Equal(previous, entry) {
+                merged[name] = entry
+            }
+        }
+        for name := range stored {
+            if _, ok := snapshot[name]; !ok {
+                delete(merged, name)
+            }
+        }
+
+        data, err := json.Marshal(merged)
+        if err != nil {
+            return err
+        }
+
+        ok, appErr := p.API.KVCompareAndSet(key, current, data)
+        if appErr != nil {
+            return appErr
+        }
+        if ok {
+            return nil
+        }
+    }
+
+    return fmt.Errorf("%s changed %d times while being updated", key, indexUpdateAttempts)
+}
+
+// saveBlob writes the JSON of the value returned by snapshot to key.
+// snapshot runs under the groupMutex read lock, and storeMutex is held until
+// the write finishes, so a snapshot taken before a newer one is never written
+// after it. It must not be called while holding groupMutex.
+func (p *Plugin) saveBlob(key string, snapshot func() interface{}) error {
+    p.storeMutex.Lock()
+    defer p.storeMutex.Unlock()
+
+    p.groupMutex.RLock()
+    data, err := json.Marshal(snapshot())
+    p.groupMutex.RUnlock()
+
+    if err != nil {
+        return err
+    }
+
+    if appErr := p.API.KVSet(key, data); appErr != nil {
+        return appErr
+    }
+
+    return nil
+}
+
+// copyGroups returns a copy of a membership map that does not share member
+// slices with it.
+func copyGroups(groups map[string][]string) map[string][]string {
+    snapshot := make(map[string][]string, len(groups))
+    for groupName, members := range groups {
+        snapshot[groupName] = append([]string{}, members...)
+    }
+    return snapshot
+}
+
+// sameMembers reports whether two member lists are equal, in order.
+func sameMembers(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
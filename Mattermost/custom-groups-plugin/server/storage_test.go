@@ -0,0 +1,124 @@
+package main
+
+import (
+    "fmt"
+    "sync"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestConcurrentSavesKeepLatestState(t *testing.T) {
+    api := newTestAPI(t)
+    p := newTestPlugin(t, api)
+
+    for _, groupName := range []string{"team-0", "team-1", "team-2"} {
+        require.NoError(t, p.createGroup(groupName, nil, "", "creator"))
+    }
+
+    const workers = 30
+    var wg sync.WaitGroup
+    for i := 0; i < workers; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+
+            groupName := fmt.Sprintf("team-%d", i%3)
+            userID := fmt.Sprintf("user-%d", i)
+            _, err := p.addGroupMember(groupName, userID, "actor")
+            assert.NoError(t, err)
+            if i%2 == 0 {
+                assert.NoError(t, p.removeGroupMember(groupName, userID, "actor"))
+            }
+
+            scratch := fmt.Sprintf("scratch-%d", i)
+            assert.NoError(t, p.createGroup(scratch, []string{userID}, "", "actor"))
+            assert.NoError(t, p.deleteGroup(scratch, "actor"))
+        }(i)
+    }
+    wg.Wait()
+
+    expected := map[string][]string{"team-0": {}, "team-1": {}, "team-2": {}}
+    for i := 1; i < workers; i += 2 {
+        groupName := fmt.Sprintf("team-%d", i%3)
+        expected[groupName] = append(expected[groupName], fmt.Sprintf("user-%d", i))
+    }
+
+    reloaded := newTestPlugin(t, api)
+    require.NoError(t, reloaded.loadGroups())
+    require.NoError(t, reloaded.loadGroupMetadata())
+    require.NoError(t, reloaded.loadGroupTrash())
+
+    assert.Len(t, reloaded.groups, len(expected))
+    for groupName, members := range expected {
+        assert.ElementsMatch(t, members, reloaded.groups[groupName], groupName)
+        assert.Contains(t, reloaded.groupMetadata, groupName)
+    }
+    assert.Len(t, reloaded.groupTrash, workers)
+}
+func TestLoadGroupsMigratesBlob(t *testing.T) {
+    api := newTestAPI(t)
+    api.kv[groupsKey] = []byte(`{"dev":["a","b"],"ops":[]}`)
+    p := newTestPlugin(t, api)
+
+    require.NoError(t, p.loadGroups())
+
+    assert.Equal(t, map[string][]string{"dev": {"a", "b"}, "ops": {}}, p.groups)
+    assert.NotContains(t, api.kv, groupsKey)
+    assert.JSONEq(t, `["dev","ops"]`, string(api.kv[groupIndexKey]))
+    assert.JSONEq(t, `["a","b"]`, string(api.kv[groupKey("dev")]))
+}
+
+// loadTestServer returns a plugin that loaded the state stored through api,
+// like another server of a cluster.
+func loadTestServer(t *testing.T, api *testAPI) *Plugin {
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.loadGroups())
+    require.NoError(t, p.loadGroupMetadata())
+    require.NoError(t, p.loadGroupTrash())
+    require.NoError(t, p.loadGroupEvents())
+    return p
+}
+
+func TestServersKeepEachOthersChanges(t *testing.T) {
+    api := newTestAPI(t)
+    setup := newTestPlugin(t, api)
+    for _, groupName := range []string{"dev", "ops", "qa", "sales"} {
+        require.NoError(t, setup.createGroup(groupName, nil, "", "creator"))
+    }
+
+    first := loadTestServer(t, api)
+    second := loadTestServer(t, api)
+
+    require.NoError(t, first.setGroupTags("dev", []string{"backend"}))
+    require.NoError(t, second.setGroupTags("ops", []string{"infra"}))
+
+    _, err := first.addGroupMember("dev", "alice", "actor")
+    require.NoError(t, err)
+    _, err = second.addGroupMember("ops", "bob", "actor")
+    require.NoError(t, err)
+
+    require.NoError(t, first.deleteGroup("qa", "actor"))
+    require.NoError(t, second.deleteGroup("sales", "actor"))
+
+    reloaded := loadTestServer(t, api)
+
+    assert.Equal(t, []string{"backend"}, reloaded.groupTags("dev"))
+    assert.Equal(t, []string{"infra"}, reloaded.groupTags("ops"))
+    assert.Contains(t, reloaded.groupTrash, "qa")
+    assert.Contains(t, reloaded.groupTrash, "sales")
+
+    added := map[string]int64{}
+    seqs := map[int64]bool{}
+    for _, event := range reloaded.events.events {
+        assert.False(t, seqs[event.Seq], "sequence number %d is used twice", event.Seq)
+        seqs[event.Seq] = true
+        if event.Type == EventMemberAdded {
+            added[event.User] = event.Seq
+        }
+    }
+    assert.Contains(t, added, "alice")
+    assert.Contains(t, added, "bob")
+    assert.Equal(t, int64(len(reloaded.events.events)), reloaded.events.lastSeq)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "net/http"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+const (
+    // Header carrying the shared secret for the sync endpoint
+    syncSecretHeader = "X-Sync-Secret"
+)
+
+// SyncMember identifies a user by username or email.
+type SyncMember struct {
+    Username string `json:"username"`
+    Email    string `json:"email"`
+}
+
+// SyncRequest is the body accepted by the sync endpoint.
+type SyncRequest struct {
+    GroupName string       `json:"group_name"`
+    Members   []SyncMember `json:"members"`
+}
+
+// SyncResult is the diff returned by the sync endpoint.
+type SyncResult struct {
+    Group      string   `json:"group"`
+    Added      []string `json:"added"`      // usernames added to the group
+    Removed    []string `json:"removed"`    // usernames removed from the group
+    Unchanged  int      `json:"unchanged"`  // members present before and after
+    Unresolved []string `json:"unresolved"` // identifiers that matched no user
+}
+
+// handleSyncGroup reconciles a group to the provided member set. The caller
+// must be a system admin and present the configured shared secret.
+func (p *Plugin) handleSyncGroup(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    secret := config.GetConfig().SyncSecret
+    if secret == "" {
+        http.Error(w, "Membership sync is disabled", http.StatusForbidden)
+        return
+    }
+
+    if subtle.ConstantTimeCompare([]byte(r.Header.Get(syncSecretHeader)), []byte(secret)) != 1 {
+        http.Error(w, "Invalid sync secret", http.StatusUnauthorized)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can sync groups", http.StatusForbidden)
+        return
+    }
+
+    var req SyncRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    req.GroupName = canonicalGroupName(req.GroupName)
+    if req.GroupName == "" {
+        http.Error(w, "Group name is required", http.StatusBadRequest)
+        return
+    }
+
+    result := &SyncResult{
+        Group:      req.GroupName,
+        Added:      []string{},
+        Removed:    []string{},
+        Unresolved: []string{},
+    }
+
+    memberIDs := []string{}
+    for _, member := range req.Members {
+        user, ok := p.resolveSyncMember(member)
+        if !ok {
+            identifier := member.Username
+            if identifier == "" {
+                identifier = member.Email
+            }
+            result.Unresolved = append(result.Unresolved, identifier)
+            continue
+        }
+        if !contains(memberIDs, user.Id) {
+            memberIDs = append(memberIDs, user.Id)
+        }
+    }
+
+    added, removed, err := p.setGroupMembers(req.GroupName, memberIDs, userID)
+    if err != nil {
+        p.writeError(w, err)
+        return
+    }
+
+    result.Added = p.usernames(added)
+    result.Removed = p.usernames(removed)
+    result.Unchanged = len(memberIDs) - len(added)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(result)
+}
+
+func (p *Plugin) resolveSyncMember(member SyncMember) (*model.User, bool) {
+    if member.Username != "" {
+        if user, appErr := p.API.GetUserByUsername(member.Username); appErr == nil {
+            return user, true
+        }
+        return nil, false
+    }
+
+    if member.Email != "" {
+        if user, appErr := p.API.GetUserByEmail(member.Email); appErr == nil {
+            return user, true
+        }
+    }
+
+    return nil, false
+}
+
+// setGroupMembers replaces the members of a group, keeping the order of
+// existing members, and returns the IDs that were added and removed. actorID
+// is the ID of the user making the change.
+func (p *Plugin) setGroupMembers(groupName string, memberIDs []string, actorID string) ([]string, []string, error) {
+    p.groupMutex.Lock()
+    members, exists := p.groups[groupName]
+    if !exists {
+        p.groupMutex.Unlock()
+        return nil, nil, groupError(ErrGroupNotFound, groupName)
+    }
+
+    wanted := make(map[string]bool, len(memberIDs))
+    for _, id := range memberIDs {
+        wanted[id] = true
+    }
+
+    newMembers := []string{}
+    removed := []string{}
+    for _, id := range members {
+        if wanted[id] {
+            newMembers = append(newMembers, id)
+        } else {
+            removed = append(removed, id)
+        }
+    }
+
+    added := []string{}
+    for _, id := range memberIDs {
+        if !contains(members, id) {
+            newMembers = append(newMembers, id)
+            added = append(added, id)
+        }
+    }
+
+    p.groups[groupName] = newMembers
+    if len(added) > 0 || len(removed) > 0 {
+        p.touchGroup(groupName)
+    }
+    p.events.append(memberEvents(EventMemberRemoved, groupName, actorID, removed))
+    p.events.append(memberEvents(EventMemberAdded, groupName, actorID, added))
+    p.forgetJoins(groupName, removed)
+    p.recordJoins(groupName, added)
+    p.enforceExclusive(groupName, added, actorID)
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return added, removed, p.saveGroupState()
+}
+
+// usernames resolves user IDs to usernames, falling back to the ID for users
+// that cannot be loaded.
+func (p *Plugin) usernames(userIDs []string) []string {
+    names := make([]string, 0, len(userIDs))
+    for _, id := range userIDs {
+        if user, appErr := p.API.GetUser(id); appErr == nil {
+            names = append(names, user.Username)
+        } else {
+            names = append(names, id)
+        }
+    }
+    return names
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Tags a group can have at most
+    maxGroupTags = 20
+)
+
+// tagPattern matches a valid tag: lowercase letters, digits, dashes and
+// underscores.
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// normalizeTags lowercases the tags, drops a leading # and repeated tags, and
+// sorts them. It rejects invalid tags and more than maxGroupTags.
+func normalizeTags(tags []string) ([]string, error) {
+    normalized := []string{}
+    for _, tag := range tags {
+        tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+        if tag == "" || contains(normalized, tag) {
+            continue
+        }
+        if !tagPattern.MatchString(tag) {
+            return nil, fmt.Errorf("invalid tag %s, use up to 32 letters, digits, dashes and underscores", tag)
+        }
+        normalized = append(normalized, tag)
+    }
+    if len(normalized) > maxGroupTags {
+        return nil, fmt.Errorf("a group can have at most %d tags", maxGroupTags)
+    }
+    sort.Strings(normalized)
+    return normalized, nil
+}
+
+// groupTags returns the tags of a group. Callers must hold groupMutex.
+func (p *Plugin) groupTags(groupName string) []string {
+    if metadata, ok := p.groupMetadata[groupName]; ok {
+        return metadata.Tags
+    }
+    return nil
+}
+
+// hasTag reports whether a group has the tag. An empty tag matches every
+// group. Callers must hold groupMutex.
+func (p *Plugin) hasTag(groupName, tag string) bool {
+    if tag == "" {
+        return true
+    }
+    return contains(p.groupTags(groupName), strings.ToLower(strings.TrimPrefix(tag, "#")))
+}
+
+// setGroupTags replaces the tags of a group, or clears them when tags is
+// empty. The tags must already be normalized.
+func (p *Plugin) setGroupTags(groupName string, tags []string) error {
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    metadata := p.metadataFor(groupName)
+    metadata.Tags = tags
+    if metadata.isEmpty() {
+        delete(p.groupMetadata, groupName)
+    }
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// addGroupTags adds the tags a group does not have yet, e.g. from an import.
+func (p *Plugin) addGroupTags(groupName string, tags []string) error {
+    if len(tags) == 0 {
+        return nil
+    }
+
+    p.groupMutex.Lock()
+    if _, exists := p.groups[groupName]; !exists {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupNotFound, groupName)
+    }
+
+    merged, err := normalizeTags(append(append([]string{}, p.groupTags(groupName)...), tags...))
+    if err != nil {
+        p.groupMutex.Unlock()
+        return err
+    }
+    p.metadataFor(groupName).Tags = merged
+    p.groupMutex.Unlock()
+
+    // Save to persistent storage
+    return p.saveGroupMetadata()
+}
+
+// tagCommand sets or clears the tags of a group, or shows them when none are
+// given.
+func (p *Plugin) tagCommand(trigger string, params []string, quiet bool) *model.CommandResponse {
+    if len(params) < 1 {
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Please specify a group name and tags: `/%s tag group_name tag [tag...]` or `/%s tag group_name none`", trigger, trigger),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+    groupName := params[0]
+
+    if len(params) == 1 {
+        p.groupMutex.RLock()
+        _, exists := p.groups[groupName]
+        tags := p.groupTags(groupName)
+        p.groupMutex.RUnlock()
+
+        if !exists {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Group %s does not exist", groupName),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+        if len(tags) == 0 {
+            return &model.CommandResponse{
+                Text: fmt.Sprintf("Group %s has no tags", groupName),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+        return &model.CommandResponse{
+            Text: fmt.Sprintf("Tags of group %s: %s", groupName, strings.Join(tags, ", ")),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    tags := []string{}
+    if len(params) > 2 || !strings.EqualFold(params[1], "none") {
+        var err error
+        if tags, err = normalizeTags(params[1:]); err != nil {
+            return &model.CommandResponse{
+                Text: err.Error(),
+                ResponseType: model.CommandResponseTypeEphemeral,
+            }
+        }
+    }
+
+    if err := p.setGroupTags(groupName, tags); err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if len(tags) == 0 {
+        return &model.CommandResponse{
+            Text: successText(quiet, fmt.Sprintf("Cleared the tags of group %s", groupName)),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: successText(quiet, fmt.Sprintf("Set the tags of group %s to %s", groupName, strings.Join(tags, ", "))),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sort"
+    "strings"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+    for _, tc := range []struct {
+        tags     []string
+        expected []string
+    }{
+        {nil, []string{}},
+        {[]string{"Frontend"}, []string{"frontend"}},
+        {[]string{" #urgent ", "frontend", "URGENT", ""}, []string{"frontend", "urgent"}},
+        {[]string{"q3_2024", "on-call"}, []string{"on-call", "q3_2024"}},
+    } {
+        normalized, err := normalizeTags(tc.tags)
+        require.NoError(t, err, tc.tags)
+        assert.Equal(t, tc.expected, normalized, tc.tags)
+    }
+
+    for _, tags := range [][]string{
+        {"two words"},
+        {"emoji✨"},
+        {strings.Repeat("a", 33)},
+    } {
+        _, err := normalizeTags(tags)
+        assert.Error(t, err, tags)
+    }
+
+    tooMany := []string{}
+    for i := 0; i <= maxGroupTags; i++ {
+        tooMany = append(tooMany, strings.Repeat("t", i+1))
+    }
+    _, err := normalizeTags(tooMany)
+    assert.Error(t, err)
+    _, err = normalizeTags(tooMany[:maxGroupTags])
+    assert.NoError(t, err)
+}
+
+// runCommand executes a slash command as the user and returns the response
+// text.
+func runCommand(t *testing.T, p *Plugin, userID, command string) string {
+    response, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: userID, Command: command})
+    require.Nil(t, appErr)
+    return response.Text
+}
+
+func TestTagCommand(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("web", nil, "", "creator"))
+
+    assert.Equal(t, "Group web has no tags", runCommand(t, p, "creator", "/group tag web"))
+    assert.Equal(t, "Set the tags of group web to frontend, urgent", runCommand(t, p, "creator", "/group tag web Urgent #frontend urgent"))
+    assert.Equal(t, "Tags of group web: frontend, urgent", runCommand(t, p, "creator", "/group tag web"))
+    assert.Equal(t, []string{"frontend", "urgent"}, loadTestServer(t, p.API.(*testAPI)).groupTags("web"), "the tags should be saved")
+
+    assert.Contains(t, runCommand(t, p, "creator", "/group tag web bad/tag"), "invalid tag bad/tag")
+    assert.Equal(t, []string{"frontend", "urgent"}, p.groupTags("web"))
+
+    assert.Equal(t, "Cleared the tags of group web", runCommand(t, p, "creator", "/group tag web none"))
+    assert.Empty(t, p.groupTags("web"))
+
+    assert.Equal(t, "Group missing does not exist", runCommand(t, p, "creator", "/group tag missing"))
+}
+
+// newTaggedGroups returns a plugin with groups tagged for filtering.
+func newTaggedGroups(t *testing.T) *Plugin {
+    p := newTestPlugin(t, newTestAPI(t))
+    for groupName, tags := range map[string][]string{
+        "web":     {"frontend", "urgent"},
+        "mobile":  {"frontend"},
+        "backend": {"urgent"},
+        "design":  nil,
+    } {
+        require.NoError(t, p.createGroup(groupName, nil, "", "creator"))
+        require.NoError(t, p.setGroupTags(groupName, tags))
+    }
+    return p
+}
+
+func TestListFiltersByTag(t *testing.T) {
+    p := newTaggedGroups(t)
+
+    listed := func(command string) []string {
+        var reports []*GroupReport
+        require.NoError(t, json.Unmarshal([]byte(runCommand(t, p, "creator", command)), &reports))
+        names := []string{}
+        for _, report := range reports {
+            names = append(names, report.Name)
+        }
+        return names
+    }
+
+    assert.Equal(t, []string{"mobile", "web"}, listed("/group list --tag frontend --json"))
+    assert.Equal(t, []string{"mobile", "web"}, listed("/group list --tag #Frontend --json"))
+    assert.Equal(t, []string{"backend", "web"}, listed("/group list --json --tag urgent"))
+    assert.Equal(t, []string{}, listed("/group list --tag none-such --json"))
+    assert.Equal(t, []string{"backend", "design", "mobile", "web"}, listed("/group list --json"))
+
+    text := runCommand(t, p, "creator", "/group list --tag frontend")
+    assert.Contains(t, text, "**mobile**")
+    assert.Contains(t, text, "**web**")
+    assert.Contains(t, text, "Tags: frontend, urgent")
+    assert.NotContains(t, text, "**backend**")
+    assert.NotContains(t, text, "**design**")
+}
+
+func TestGetGroupsFiltersByTag(t *testing.T) {
+    p := newTaggedGroups(t)
+
+    for query, expected := range map[string][]string{
+        "?tag=frontend":            {"mobile", "web"},
+        "?tag=URGENT":              {"backend", "web"},
+        "?tag=%23urgent":           {"backend", "web"},
+        "?tag=none-such":           {},
+        "":                         {"backend", "design", "mobile", "web"},
+        "?tag=frontend&per_page=1": {"mobile"},
+    } {
+        var groups map[string]*GroupSummary
+        w := getGroups(t, p, query, &groups)
+        names := []string{}
+        for groupName := range groups {
+            names = append(names, groupName)
+        }
+        sort.Strings(names)
+        assert.Equal(t, expected, names, query)
+        if query == "?tag=frontend&per_page=1" {
+            assert.Equal(t, "2", w.Header().Get("X-Total-Count"), "the total should count only tagged groups")
+        }
+    }
+}
+
+func TestTagsSurviveExportAndBulkImport(t *testing.T) {
+    alice := &model.User{Id: model.NewId(), Username: "alice"}
+    api := newTestAPI(t)
+    expectUsers(api, alice, &model.User{Id: "admin", Username: "admin"})
+    api.On("GetUserByUsername", "alice").Return(alice, nil)
+    api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("web", []string{alice.Id}, "Web team", "creator"))
+    require.NoError(t, p.setGroupTags("web", []string{"frontend", "urgent"}))
+
+    exported, err := p.exportGroup("web")
+    require.NoError(t, err)
+    assert.Equal(t, []string{"frontend", "urgent"}, exported.Tags)
+
+    // Import the export as a new group, and merge extra tags into an existing one
+    require.NoError(t, p.createGroup("app", nil, "", "creator"))
+    require.NoError(t, p.setGroupTags("app", []string{"mobile"}))
+    body, err := json.Marshal([]BulkImportGroup{
+        {Name: "web-copy", Members: exported.Members, Tags: exported.Tags},
+        {Name: "app", Members: exported.Members, Tags: []string{"#Frontend", "mobile"}},
+    })
+    require.NoError(t, err)
+
+    w := serveRequest(p, http.MethodPost, "/api/v4/groups/import", "admin", string(body))
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+    assert.Equal(t, []string{alice.Id}, p.groups["web-copy"])
+    assert.Equal(t, []string{"frontend", "urgent"}, p.groupTags("web-copy"))
+    assert.Equal(t, []string{"frontend", "mobile"}, p.groupTags("app"))
+
+    // Invalid tags fail the group instead of being dropped
+    body, err = json.Marshal([]BulkImportGroup{{Name: "bad", Tags: []string{"not valid"}}})
+    require.NoError(t, err)
+    w = serveRequest(p, http.MethodPost, "/api/v4/groups/import", "admin", string(body))
+    require.Equal(t, http.StatusOK, w.Code)
+    var result BulkImportResult
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+    require.Len(t, result.Groups, 1)
+    assert.Contains(t, result.Groups[0].Error, "invalid tag")
+    assert.NotContains(t, p.groups, "bad")
+}
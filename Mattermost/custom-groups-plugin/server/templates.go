@@ -0,0 +1,57 @@
+package main
+
+import (
+    "strings"
+    "text/template"
+)
+
+// notificationData is the data available to group notification templates:
+// {{.Author}}, {{.Channel}}, {{.Group}} and {{.Members}}.
+type notificationData struct {
+    Author  string // username of the post author
+    Channel string // name of the channel the mention was posted in
+    Group   string // name of the mentioned group
+    Members string // comma-separated @usernames of the group members
+}
+
+// parseNotificationTemplate parses a template and renders it once with
+// sample data so that references to unknown fields are rejected when the
+// template is set rather than when a notification is sent.
+func parseNotificationTemplate(text string) (*template.Template, error) {
+    tmpl, err := template.New("notification").Option("missingkey=error").Parse(text)
+    if err != nil {
+        return nil, err
+    }
+
+    var sample strings.Builder
+    if err := tmpl.Execute(&sample, notificationData{
+        Author:  "author",
+        Channel: "town-square",
+        Group:   "group",
+        Members: "@member",
+    }); err != nil {
+        return nil, err
+    }
+
+    return tmpl, nil
+}
+
+// renderNotification renders the group's notification template, returning
+// false when the group has no template or it fails to render.
+func renderNotification(text string, data notificationData) (string, bool) {
+    if text == "" {
+        return "", false
+    }
+
+    tmpl, err := parseNotificationTemplate(text)
+    if err != nil {
+        return "", false
+    }
+
+    var message strings.Builder
+    if err := tmpl.Execute(&message, data); err != nil {
+        return "", false
+    }
+
+    return message.String(), true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestRenderNotification(t *testing.T) {
+    data := notificationData{Author: "alice", Channel: "town-square", Group: "dev", Members: "@bob, @carol"}
+
+    message, ok := renderNotification("{{.Author}} needs @{{.Group}} ({{.Members}}) in ~{{.Channel}}", data)
+    assert.True(t, ok)
+    assert.Equal(t, "alice needs @dev (@bob, @carol) in ~town-square", message)
+
+    for _, text := range []string{"", "{{.Author", "{{.Unknown}}", "{{template \"missing\"}}"} {
+        _, ok := renderNotification(text, data)
+        assert.False(t, ok, text)
+    }
+}
+
+func TestParseNotificationTemplateRejectsUnknownFields(t *testing.T) {
+    _, err := parseNotificationTemplate("Hello {{.Group}}")
+    assert.NoError(t, err)
+
+    for _, text := range []string{"{{.Author", "{{.Password}}", "{{.Group.Name}}", "{{end}}"} {
+        _, err := parseNotificationTemplate(text)
+        assert.Error(t, err, text)
+    }
+}
+
+func TestTemplateCommandSetsNotification(t *testing.T) {
+    api := newTestAPI(t)
+    expectUsers(api,
+        &model.User{Id: "author", Username: "alice"},
+        &model.User{Id: "u1", Username: "bob"},
+    )
+    expectNotifications(api, "channel")
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "author", Command: "/group template dev {{.Unknown}}"})
+    assert.Contains(t, response.Text, "Invalid template")
+    assert.Empty(t, p.groupMetadata["dev"].Template)
+
+    response, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "author", Command: "/group template dev {{.Author}}  paged  @{{.Group}}"})
+    assert.Equal(t, "Set the notification template of group dev", response.Text)
+    assert.Equal(t, "{{.Author}}  paged  @{{.Group}}", p.groupMetadata["dev"].Template)
+
+    post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "author", ChannelId: "channel", Message: "@dev deploy"})
+    p.MessageHasBeenPosted(&plugin.Context{}, post)
+
+    require.Equal(t, []string{"u1"}, ephemeralRecipients(api))
+    sent := api.Calls[len(api.Calls)-1].Arguments.Get(1).(*model.Post)
+    assert.Equal(t, "alice  paged  @dev", sent.Message)
+
+    response, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "author", Command: "/group template dev none"})
+    assert.Equal(t, "Group dev now uses the default notification", response.Text)
+    assert.Empty(t, p.groupMetadata["dev"].Template)
+}
+
+func TestTemplateCommandIgnoresFlags(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+    require.NoError(t, p.createGroup("dev", nil, "", "creator"))
+
+    for command, expected := range map[string]string{
+        "/group --json template dev Hi":                           "Hi",
+        "/group --quiet template dev Hi  {{.Author}}":             "Hi  {{.Author}}",
+        "/group template --json dev Hi":                           "Hi",
+        "/group template dev --quiet Hi {{.Group}}":               "Hi {{.Group}}",
+        "/group template dev Hi {{.Author}} --json":               "Hi {{.Author}}",
+        "/group template dev Hi  {{.Author}} --json --quiet":      "Hi  {{.Author}}",
+        "/group template dev Use --json for scripts, {{.Author}}": "Use --json for scripts, {{.Author}}",
+    } {
+        response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "author", Command: command})
+        require.NotContains(t, response.Text, "Invalid", command)
+        assert.Equal(t, expected, p.groupMetadata["dev"].Template, command)
+    }
+
+    response, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "author", Command: "/group template dev none --quiet"})
+    assert.NotContains(t, response.Text, "Invalid")
+    assert.Empty(t, p.groupMetadata["dev"].Template)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "sync"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// threadNotifications remembers which users were notified about group
+// mentions in each thread, so repeated mentions in a busy thread ping them at
+// most once per window. The zero value is ready to use.
+type threadNotifications struct {
+    mu          sync.Mutex
+    threads     map[string]*threadState // map[rootPostID]state
+    lastCleanup time.Time
+}
+
+type threadState struct {
+    lastActive time.Time
+    notified   map[string]time.Time // map[userID]time of the last notification
+}
+
+// threadID returns the ID of the thread a post belongs to.
+func threadID(post *model.Post) string {
+    if post.RootId != "" {
+        return post.RootId
+    }
+    return post.Id
+}
+
+// recentlyNotified returns the users notified in the thread within window.
+func (t *threadNotifications) recentlyNotified(thread string, window time.Duration, now time.Time) map[string]bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    notified := make(map[string]bool)
+    state, ok := t.threads[thread]
+    if !ok {
+        return notified
+    }
+
+    for userID, at := range state.notified {
+        if now.Sub(at) < window {
+            notified[userID] = true
+        }
+    }
+    return notified
+}
+
+// record marks the users as notified in the thread. Threads idle for longer
+// than window are dropped at most once per window.
+func (t *threadNotifications) record(thread string, userIDs []string, window time.Duration, now time.Time) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if t.threads == nil {
+        t.threads = make(map[string]*threadState)
+    }
+
+    if now.Sub(t.lastCleanup) >= window {
+        for id, state := range t.threads {
+            if now.Sub(state.lastActive) >= window {
+                delete(t.threads, id)
+            }
+        }
+        t.lastCleanup = now
+    }
+
+    state, ok := t.threads[thread]
+    if !ok {
+        state = &threadState{notified: make(map[string]time.Time)}
+        t.threads[thread] = state
+    }
+
+    state.lastActive = now
+    for _, userID := range userIDs {
+        state.notified[userID] = now
+    }
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+    // Key for storing deleted groups in KV store
+    groupTrashKey = "custom_groups_trash"
+
+    // How long deleted groups can be restored before they are purged
+    trashRetention = 30 * 24 * time.Hour
+)
+
+// DeletedGroup is a group kept in the trash after deletion so it can be
+// restored.
+type DeletedGroup struct {
+    Members   []string       `json:"members"`
+    Metadata  *GroupMetadata `json:"metadata,omitempty"`
+    DeletedAt int64          `json:"deleted_at"`           // milliseconds since epoch
+    DeletedBy string         `json:"deleted_by,omitempty"` // user ID; empty for unauthenticated REST deletions
+}
+
+func (p *Plugin) loadGroupTrash() error {
+    data, appErr := p.API.KVGet(groupTrashKey)
+    if appErr != nil {
+        return appErr
+    }
+
+    trash := make(map[string]*DeletedGroup)
+    if data != nil {
+        if err := json.Unmarshal(data, &trash); err != nil {
+            return err
+        }
+    }
+
+    p.groupMutex.Lock()
+    p.groupTrash = trash
+    stored, err := p.trashEntries()
+    p.groupMutex.Unlock()
+
+    if err != nil {
+        return err
+    }
+
+    p.storeMutex.Lock()
+    p.storedTrash = stored
+    p.storeMutex.Unlock()
+
+    return nil
+}
+
+// saveGroupTrash persists the deleted groups that changed since they were
+// last stored, merging them into the stored blob like saveGroupMetadata.
+func (p *Plugin) saveGroupTrash() error {
+    p.storeMutex.Lock()
+    defer p.storeMutex.Unlock()
+
+    p.groupMutex.RLock()
+    snapshot, err := p.trashEntries()
+    p.groupMutex.RUnlock()
+
+    if err != nil {
+        return err
+    }
+
+    if err := p.storeEntries(groupTrashKey, p.storedTrash, snapshot); err != nil {
+        return err
+    }
+    p.storedTrash = snapshot
+
+    return nil
+}
+
+// trashEntries returns the JSON of each deleted group. Callers must hold
+// groupMutex.
+func (p *Plugin) trashEntries() (map[string]json.RawMessage, error) {
+    entries := make(map[string]json.RawMessage, len(p.groupTrash))
+    for groupName, deleted := range p.groupTrash {
+        data, err := json.Marshal(deleted)
+        if err != nil {
+            return nil, err
+        }
+        entries[groupName] = data
+    }
+    return entries, nil
+}
+
+// purgeTrash drops deleted groups older than the retention period. Callers
+// must hold the groupMutex write lock.
+func (p *Plugin) purgeTrash(now time.Time) {
+    cutoff := model.GetMillisForTime(now.Add(-trashRetention))
+    for groupName, deleted := range p.groupTrash {
+        if deleted.DeletedAt < cutoff {
+            delete(p.groupTrash, groupName)
+        }
+    }
+}
+
+// restoreGroup moves a deleted group back from the trash with its members and
+// metadata. actorID is the restoring user's ID.
+func (p *Plugin) restoreGroup(groupName, actorID string) error {
+    p.groupMutex.Lock()
+    p.purgeTrash(time.Now())
+    deleted, ok := p.groupTrash[groupName]
+    if !ok {
+        p.groupMutex.Unlock()
+        return groupError(ErrNotInTrash, groupName)
+    }
+
+    if p.groupNameTaken(groupName) {
+        p.groupMutex.Unlock()
+        return groupError(ErrGroupExists, groupName)
+    }
+
+    p.groups[groupName] = deleted.Members
+    if deleted.Metadata != nil {
+        p.groupMetadata[groupName] = deleted.Metadata
+    }
+    delete(p.groupTrash, groupName)
+    p.events.append(memberEvents(EventMemberAdded, groupName, actorID, deleted.Members))
+    p.groupMutex.Unlock()
+
+    if err := p.saveGroupTrash(); err != nil {
+        return err
+    }
+
+    // Save to persistent storage
+    return p.saveGroupState()
+}
+
+// trashText lists the deleted groups that can still be restored, most
+// recently deleted first.
+func (p *Plugin) trashText() string {
+    p.groupMutex.RLock()
+    defer p.groupMutex.RUnlock()
+
+    cutoff := model.GetMillisForTime(time.Now().Add(-trashRetention))
+    names := []string{}
+    for groupName, deleted := range p.groupTrash {
+        if deleted.DeletedAt >= cutoff {
+            names = append(names, groupName)
+        }
+    }
+
+    if len(names) == 0 {
+        return "No deleted groups"
+    }
+
+    sort.Slice(names, func(i, j int) bool {
+        return p.groupTrash[names[i]].DeletedAt > p.groupTrash[names[j]].DeletedAt
+    })
+
+    var text strings.Builder
+    text.WriteString(fmt.Sprintf("Deleted groups (kept for %d days):\n", int(trashRetention.Hours()/24)))
+    for _, groupName := range names {
+        deleted := p.groupTrash[groupName]
+        deletedBy := "the REST API"
+        if deleted.DeletedBy != "" {
+            deletedBy = deleted.DeletedBy
+            if user, err := p.API.GetUser(deleted.DeletedBy); err == nil {
+                deletedBy = "@" + user.Username
+            }
+        }
+        deletedAt := time.Unix(0, deleted.DeletedAt*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+        text.WriteString(fmt.Sprintf("- **%s** (%d members) deleted %s by %s\n", groupName, len(deleted.Members), deletedAt, deletedBy))
+    }
+
+    return text.String()
+}
+
+// trashCommand lists deleted groups or restores one. Only system admins can
+// use it.
+func (p *Plugin) trashCommand(userID, command, groupName string) *model.CommandResponse {
+    if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        return &model.CommandResponse{
+            Text: "Only system administrators can view or restore deleted groups",
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if command == "trash" {
+        return &model.CommandResponse{
+            Text: p.trashText(),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    if err := p.restoreGroup(groupName, userID); err != nil {
+        return &model.CommandResponse{
+            Text: commandErrorText(err, groupName, "Failed to save changes"),
+            ResponseType: model.CommandResponseTypeEphemeral,
+        }
+    }
+
+    return &model.CommandResponse{
+        Text: fmt.Sprintf("Restored group %s", groupName),
+        ResponseType: model.CommandResponseTypeEphemeral,
+    }
+}
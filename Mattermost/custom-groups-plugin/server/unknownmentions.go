@@ -0,0 +1,109 @@
+package main
+
+import (
+    "strings"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+
+    "github.com/mattermost/mattermost-plugin-custom-groups/server/config"
+)
+
+// Mentions Mattermost handles itself, never hinted about
+var specialMentions = map[string]bool{
+    "all":      true,
+    "here":     true,
+    "channel":  true,
+    "everyone": true,
+}
+
+// closestGroupName returns the group name most similar to the token, if one
+// is close enough to be a likely typo. Callers must hold groupMutex.
+func (p *Plugin) closestGroupName(token string) (string, bool) {
+    maxDistance := 2
+    if len(token) <= 4 {
+        maxDistance = 1
+    }
+
+    closest := ""
+    closestDistance := maxDistance + 1
+    consider := func(groupName string) {
+        distance := editDistance(strings.ToLower(token), strings.ToLower(groupName))
+        if distance < closestDistance || (distance == closestDistance && groupName < closest) {
+            closest = groupName
+            closestDistance = distance
+        }
+    }
+    for groupName := range p.groups {
+        consider(groupName)
+    }
+    for groupName := range p.dynamicGroups {
+        consider(groupName)
+    }
+
+    return closest, closest != ""
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    previous := make([]int, len(rb)+1)
+    current := make([]int, len(rb)+1)
+    for j := range previous {
+        previous[j] = j
+    }
+
+    for i := 1; i <= len(ra); i++ {
+        current[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+        }
+        previous, current = current, previous
+    }
+
+    return previous[len(rb)]
+}
+
+func minInt(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// hintUnknownGroups tells the author about mentions that look like a typo of
+// a group name, e.g. @devs when only @dev exists. Tokens naming a group, a
+// user or a special mention are left alone. Callers must hold groupMutex.
+func (p *Plugin) hintUnknownGroups(post *model.Post) {
+    if !config.GetConfig().UnknownGroupHints || post.IsSystemMessage() || !strings.Contains(post.Message, "@") {
+        return
+    }
+
+    locale := p.userLocale(post.UserId)
+    hinted := map[string]bool{}
+    for _, match := range atMentionPattern.FindAllStringSubmatch(post.Message, -1) {
+        token := strings.TrimRight(match[1], "._-")
+        if token == "" || hinted[token] || specialMentions[strings.ToLower(token)] || p.groupNameTaken(token) {
+            continue
+        }
+        hinted[token] = true
+
+        suggestion, ok := p.closestGroupName(token)
+        if !ok {
+            continue
+        }
+        if _, appErr := p.API.GetUserByUsername(strings.ToLower(token)); appErr == nil {
+            continue
+        }
+
+        p.API.SendEphemeralPost(post.UserId, &model.Post{
+            UserId:    p.botID,
+            ChannelId: post.ChannelId,
+            RootId:    post.RootId,
+            Message:   translate(locale, "notification.unknown_group", token, suggestion),
+        })
+    }
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sync/atomic"
+    "time"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// CacheVars reports how often a cache answered without doing the work.
+type CacheVars struct {
+    Hits    int64   `json:"hits"`
+    Misses  int64   `json:"misses"`
+    HitRate float64 `json:"hit_rate"` // hits / (hits + misses), 0 when unused
+}
+
+// LockVars reports how long callers waited for a lock.
+type LockVars struct {
+    Acquired   int64 `json:"acquired"`
+    Contended  int64 `json:"contended"`   // acquisitions that had to wait
+    WaitMicros int64 `json:"wait_micros"` // total time spent waiting
+}
+
+// DebugVars is the response of the debug vars endpoint.
+type DebugVars struct {
+    Counters map[string]int64     `json:"counters"`
+    Caches   map[string]CacheVars `json:"caches"`
+    Locks    map[string]LockVars  `json:"locks"`
+}
+
+func newCacheVars(hits, misses int64) CacheVars {
+    vars := CacheVars{Hits: hits, Misses: misses}
+    if hits+misses > 0 {
+        vars.HitRate = float64(hits) / float64(hits+misses)
+    }
+    return vars
+}
+
+// rlockGroups read-locks groupMutex for the post hooks and autocomplete and
+// records whether and how long they waited. An uncontended lock costs one
+// TryRLock.
+func (p *Plugin) rlockGroups() {
+    atomic.AddInt64(&p.metrics.groupLockAcquired, 1)
+    if p.groupMutex.TryRLock() {
+        return
+    }
+
+    start := time.Now()
+    p.groupMutex.RLock()
+    atomic.AddInt64(&p.metrics.groupLockContended, 1)
+    atomic.AddInt64(&p.metrics.groupLockWaitMicros, time.Since(start).Microseconds())
+}
+
+// debugVars reads the counters without locking.
+func (m *metrics) debugVars() *DebugVars {
+    load := func(counter *int64) int64 {
+        return atomic.LoadInt64(counter)
+    }
+
+    return &DebugVars{
+        Counters: map[string]int64{
+            "autocomplete_requests":    load(&m.autocompleteRequests),
+            "autocomplete_suggestions": load(&m.autocompleteSuggestions),
+            "posts_expanded":           load(&m.postsExpanded),
+            "groups_matched":           load(&m.groupsMatched),
+        },
+        Caches: map[string]CacheVars{
+            "idempotency": newCacheVars(load(&m.idempotencyHits), load(&m.idempotencyMisses)),
+            "recipients":  newCacheVars(load(&m.recipientHits), load(&m.recipientMisses)),
+        },
+        Locks: map[string]LockVars{
+            "groups": {
+                Acquired:   load(&m.groupLockAcquired),
+                Contended:  load(&m.groupLockContended),
+                WaitMicros: load(&m.groupLockWaitMicros),
+            },
+        },
+    }
+}
+
+// handleDebugVars returns the internal counters. Only system admins can read
+// them.
+func (p *Plugin) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID := r.Header.Get("Mattermost-User-Id")
+    if userID == "" || !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+        http.Error(w, "Only system administrators can read plugin vars", http.StatusForbidden)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(p.metrics.debugVars())
+}
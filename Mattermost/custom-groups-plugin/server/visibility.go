@@ -0,0 +1,48 @@
+package main
+
+import (
+    "github.com/mattermost/mattermost-server/v6/plugin"
+)
+
+// groupViewer identifies the user asking for groups and where they are
+// asking from.
+type groupViewer struct {
+    UserID    string
+    ChannelID string
+    TeamID    string
+}
+
+// viewerFromContext derives the requesting user from the hook context's
+// session. It returns false when the user cannot be determined or is not a
+// member of the channel, in which case no groups should be revealed.
+func (p *Plugin) viewerFromContext(c *plugin.Context, channelID, teamID string) (*groupViewer, bool) {
+    if c == nil || c.SessionId == "" {
+        return nil, false
+    }
+
+    session, appErr := p.API.GetSession(c.SessionId)
+    if appErr != nil {
+        p.API.LogWarn("Failed to get session for autocomplete", "error", appErr.Error())
+        return nil, false
+    }
+
+    if channelID != "" {
+        if _, appErr := p.API.GetChannelMember(channelID, session.UserId); appErr != nil {
+            return nil, false
+        }
+    }
+
+    return &groupViewer{
+        UserID:    session.UserId,
+        ChannelID: channelID,
+        TeamID:    teamID,
+    }, true
+}
+
+// canSeeGroup reports whether the viewer may see and use the group in their
+// channel and team. Groups are currently global, so every group is visible to
+// a channel member; scope and privacy rules are enforced here as they are
+// added to groups.
+func (p *Plugin) canSeeGroup(viewer *groupViewer, groupName string, members []string) bool {
+    return viewer != nil
+}
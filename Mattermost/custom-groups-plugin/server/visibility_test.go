@@ -0,0 +1,42 @@
+package main
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/mattermost/mattermost-server/v6/model"
+    "github.com/mattermost/mattermost-server/v6/plugin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+    "github.com/stretchr/testify/require"
+)
+
+func TestAutocompleteHidesGroupsFromOutsiders(t *testing.T) {
+    api := newTestAPI(t)
+    notFound := model.NewAppError("test", "not_found", nil, "", http.StatusNotFound)
+    expectViewer(api, "member-session", "member", "channel")
+    api.On("GetSession", "outsider-session").Return(&model.Session{Id: "outsider-session", UserId: "outsider"}, nil)
+    api.On("GetChannelMember", "channel", "outsider").Return(nil, notFound)
+    api.On("GetSession", "expired-session").Return(nil, notFound)
+    api.On("GetUser", mock.Anything).Return(nil, notFound).Maybe()
+    api.On("SearchUsers", mock.Anything).Return([]*model.User{}, nil).Maybe()
+    p := newTestPlugin(t, api)
+    require.NoError(t, p.createGroup("dev", []string{"u1"}, "", "creator"))
+
+    suggestions, appErr := p.UserAutocompleteInChannel(&plugin.Context{SessionId: "member-session"}, "channel", "team", "@d", 10)
+    require.Nil(t, appErr)
+    assert.Equal(t, []string{"dev"}, suggestionNames(suggestions))
+
+    for _, c := range []*plugin.Context{nil, {}, {SessionId: "outsider-session"}, {SessionId: "expired-session"}} {
+        suggestions, appErr = p.UserAutocompleteInChannel(c, "channel", "team", "@d", 10)
+        require.Nil(t, appErr)
+        assert.Empty(t, suggestions)
+    }
+}
+
+func TestCanSeeGroupRequiresViewer(t *testing.T) {
+    p := newTestPlugin(t, newTestAPI(t))
+
+    assert.False(t, p.canSeeGroup(nil, "dev", []string{"u1"}))
+    assert.True(t, p.canSeeGroup(&groupViewer{UserID: "member", ChannelID: "channel"}, "dev", []string{"u1"}))
+}